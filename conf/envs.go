@@ -3,6 +3,7 @@ package conf
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -10,6 +11,10 @@ import (
 // Config Variables //
 var ServerConfig = InitApiServerConfig()
 var DatabaseConfig = InitPostgresSqlConfig()
+var StorageConfig = InitStorageConfig()
+var MailConfig = InitMailConfig()
+var AccountConfig = InitAccountConfig()
+var LoginGuardConfig = InitLoginGuardConfig()
 
 // Config structs //
 type PostgreSqlConfig struct {
@@ -24,10 +29,49 @@ type PostgreSqlConfig struct {
 type ApiServerConfig struct {
 	PublicHost                    string
 	Port                          string
-	JWTSecret                     string
+	JWTKeysDir                    string // directory of PEM private keys named <kid>.pem
+	JWTActiveKeyId                string // kid of the key that signs new tokens; the others only verify
+	JWTIssuer                     string
+	JWTAudience                   string
 	JWTExpirationInSeconds        int64
-	RefreshTokenSecret            string
 	RefreshTokenExpirationInHours int64
+	RoleExpirySweepInMinutes      int64 // how often lapsed role assignments are archived
+}
+
+type BlobStorageConfig struct {
+	PhotoStorageDir          string
+	PhotoMaxSizeInBytes      int64
+	PhotoThumbnailMaxInPixel int64
+	PhotoMaxPixels           int64 // width × height; decoding allocates for every pixel whatever the file size
+}
+
+type MailerConfig struct {
+	Driver       string // "smtp" or "file"
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	FileDir      string
+}
+
+type UserAccountConfig struct {
+	AppBaseURL                       string // frontend URL used to build the links sent by email
+	PasswordResetTokenTTLInMinutes   int64
+	EmailVerificationTokenTTLInHours int64
+	RequireVerifiedEmail             bool
+	TwoFactorRequiredPermissions     []string // permissions that can only be used from a session that passed 2FA
+}
+
+type LoginProtectionConfig struct {
+	Store                  string // "postgres" or "memory"; memory state is per instance and lost on restart
+	AccountFreeFailures    int64  // failures allowed per account before backing off
+	AccountMaxFailures     int64  // failures per account that lock it
+	IPFreeFailures         int64
+	IPMaxFailures          int64
+	LockoutInMinutes       int64
+	FailureWindowInMinutes int64 // failures older than this are forgotten
+	TrustProxyHeaders      bool  // read the client address from X-Forwarded-For; only behind a proxy that sets it
 }
 
 // Configs Functions //
@@ -50,10 +94,65 @@ func InitApiServerConfig() ApiServerConfig {
 	return ApiServerConfig{
 		PublicHost:                    getEnv("PUBLIC_HOST", "0.0.0.0"),
 		Port:                          getEnv("PORT", "8080"),
-		JWTSecret:                     getEnv("JWT_SECRET", "not-so-secret-now-is-it?"),
+		JWTKeysDir:                    getEnv("JWT_KEYS_DIR", "./keys"),
+		JWTActiveKeyId:                getEnv("JWT_ACTIVE_KEY_ID", ""),
+		JWTIssuer:                     getEnv("JWT_ISSUER", "treesense"),
+		JWTAudience:                   getEnv("JWT_AUDIENCE", "treesense-api"),
 		JWTExpirationInSeconds:        getEnvAsInt("JWT_EXPIRATION_IN_SECONDS", 3600*1),
-		RefreshTokenSecret:            getEnv("REFRESH_TOKEN_SECRET", "not-so-longsecret-now-is-it?"),
 		RefreshTokenExpirationInHours: getEnvAsInt("REFRESH_TOKEN_EXPIRATION_IN_HOURS", 30*24),
+		RoleExpirySweepInMinutes:      getEnvAsPositiveInt("ROLE_EXPIRY_SWEEP_IN_MINUTES", 60),
+	}
+}
+
+func InitStorageConfig() BlobStorageConfig {
+	godotenv.Load()
+
+	return BlobStorageConfig{
+		PhotoStorageDir:          getEnv("PHOTO_STORAGE_DIR", "./storage/photos"),
+		PhotoMaxSizeInBytes:      getEnvAsInt("PHOTO_MAX_SIZE_IN_BYTES", 5<<20),
+		PhotoThumbnailMaxInPixel: getEnvAsInt("PHOTO_THUMBNAIL_MAX_IN_PIXEL", 256),
+		PhotoMaxPixels:           getEnvAsInt("PHOTO_MAX_PIXELS", 40_000_000),
+	}
+}
+
+func InitMailConfig() MailerConfig {
+	godotenv.Load()
+
+	return MailerConfig{
+		Driver:       getEnv("MAIL_DRIVER", "file"),
+		From:         getEnv("MAIL_FROM", "TreeSense <no-reply@treesense.local>"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		FileDir:      getEnv("MAIL_FILE_DIR", "./storage/mail"),
+	}
+}
+
+func InitAccountConfig() UserAccountConfig {
+	godotenv.Load()
+
+	return UserAccountConfig{
+		AppBaseURL:                       getEnv("APP_BASE_URL", "http://localhost:3000"),
+		PasswordResetTokenTTLInMinutes:   getEnvAsInt("PASSWORD_RESET_TOKEN_TTL_IN_MINUTES", 60),
+		EmailVerificationTokenTTLInHours: getEnvAsInt("EMAIL_VERIFICATION_TOKEN_TTL_IN_HOURS", 48),
+		RequireVerifiedEmail:             getEnvAsBool("REQUIRE_VERIFIED_EMAIL", false),
+		TwoFactorRequiredPermissions:     getEnvAsList("TWO_FACTOR_REQUIRED_PERMISSIONS", []string{}),
+	}
+}
+
+func InitLoginGuardConfig() LoginProtectionConfig {
+	godotenv.Load()
+
+	return LoginProtectionConfig{
+		Store:                  getEnv("LOGIN_GUARD_STORE", "postgres"),
+		AccountFreeFailures:    getEnvAsInt("LOGIN_ACCOUNT_FREE_FAILURES", 3),
+		AccountMaxFailures:     getEnvAsInt("LOGIN_ACCOUNT_MAX_FAILURES", 10),
+		IPFreeFailures:         getEnvAsInt("LOGIN_IP_FREE_FAILURES", 10),
+		IPMaxFailures:          getEnvAsInt("LOGIN_IP_MAX_FAILURES", 50),
+		LockoutInMinutes:       getEnvAsInt("LOGIN_LOCKOUT_IN_MINUTES", 15),
+		FailureWindowInMinutes: getEnvAsInt("LOGIN_FAILURE_WINDOW_IN_MINUTES", 60),
+		TrustProxyHeaders:      getEnvAsBool("TRUST_PROXY_HEADERS", false),
 	}
 }
 
@@ -77,3 +176,41 @@ func getEnvAsInt(key string, fallback int64) int64 {
 
 	return fallback
 }
+
+// getEnvAsPositiveInt is getEnvAsInt for values that must be above zero, e.g. ticker intervals
+func getEnvAsPositiveInt(key string, fallback int64) int64 {
+	if i := getEnvAsInt(key, fallback); i > 0 {
+		return i
+	}
+
+	return fallback
+}
+
+func getEnvAsBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fallback
+		}
+
+		return b
+	}
+
+	return fallback
+}
+
+// getEnvAsList reads a comma separated list, e.g. MANAGE,CONFIG
+func getEnvAsList(key string, fallback []string) []string {
+	if value, ok := os.LookupEnv(key); ok {
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+
+		return items
+	}
+
+	return fallback
+}
@@ -33,6 +33,7 @@ CREATE TABLE auth."user" (
     language_code VARCHAR(10) DEFAULT 'es',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    email_verified_at TIMESTAMP,
     CONSTRAINT fk_auth_user_language FOREIGN KEY (language_code) REFERENCES conf.language(code)
 );
 
@@ -41,6 +42,99 @@ COMMENT ON COLUMN auth."user".user_id IS 'Unique identifier for the user';
 COMMENT ON COLUMN auth."user".user_name IS 'Name of the user';
 COMMENT ON COLUMN auth."user".photo IS 'User''s photo';
 COMMENT ON COLUMN auth."user".language_code IS 'Identifier of the user''s preferred language';
+COMMENT ON COLUMN auth."user".email_verified_at IS 'Timestamp of when the user proved ownership of the email; NULL if not verified';
+
+CREATE TABLE auth.user_token (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    purpose VARCHAR(30) NOT NULL CHECK (purpose IN ('PASSWORD_RESET', 'EMAIL_VERIFICATION', 'TWO_FACTOR_LOGIN')),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    attempts INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user_token_user FOREIGN KEY (user_id) REFERENCES auth."user"(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_token_user_purpose ON auth.user_token (user_id, purpose);
+
+COMMENT ON TABLE auth.user_token IS 'Single-use tokens for password resets, email verification and the second step of two-factor logins';
+COMMENT ON COLUMN auth.user_token.token_hash IS 'SHA-256 hex digest of the token; the token itself is only sent to the user';
+COMMENT ON COLUMN auth.user_token.user_id IS 'Reference to the user the token was issued for';
+COMMENT ON COLUMN auth.user_token.purpose IS 'What the token can be redeemed for: PASSWORD_RESET, EMAIL_VERIFICATION or TWO_FACTOR_LOGIN';
+COMMENT ON COLUMN auth.user_token.expires_at IS 'Timestamp after which the token can no longer be redeemed';
+COMMENT ON COLUMN auth.user_token.used_at IS 'Timestamp of when the token was redeemed or superseded; NULL while usable';
+COMMENT ON COLUMN auth.user_token.attempts IS 'Failed attempts to redeem the token; it is invalidated after too many';
+COMMENT ON COLUMN auth.user_token.created_at IS 'Timestamp of when the token was issued';
+
+CREATE TABLE auth.session (
+    jti UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    session_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    rotated_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    two_factor BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_session_user FOREIGN KEY (user_id) REFERENCES auth."user"(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_session_session_id ON auth.session (session_id);
+CREATE INDEX idx_session_user_id ON auth.session (user_id);
+
+COMMENT ON TABLE auth.session IS 'Refresh tokens issued to users; every rotation adds a row to the same session';
+COMMENT ON COLUMN auth.session.jti IS 'Identifier of the refresh token, sent as its jti claim';
+COMMENT ON COLUMN auth.session.session_id IS 'Login session the token belongs to; the jti of its first token, sent as the sid claim of every token';
+COMMENT ON COLUMN auth.session.user_id IS 'Reference to the user the session belongs to';
+COMMENT ON COLUMN auth.session.expires_at IS 'Timestamp after which the refresh token can no longer be used';
+COMMENT ON COLUMN auth.session.rotated_at IS 'Timestamp of when the refresh token was exchanged for a new one; using it again revokes the session';
+COMMENT ON COLUMN auth.session.revoked_at IS 'Timestamp of when the session was logged out or revoked; NULL while active';
+COMMENT ON COLUMN auth.session.two_factor IS 'Whether the login passed a two-factor check; permissions under the 2FA policy require it';
+COMMENT ON COLUMN auth.session.created_at IS 'Timestamp of when the refresh token was issued';
+
+CREATE TABLE auth.user_totp (
+    user_id UUID PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    confirmed_at TIMESTAMP,
+    last_used_step BIGINT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user_totp_user FOREIGN KEY (user_id) REFERENCES auth."user"(user_id) ON DELETE CASCADE
+);
+
+COMMENT ON TABLE auth.user_totp IS 'TOTP authenticators of users with two-factor authentication';
+COMMENT ON COLUMN auth.user_totp.user_id IS 'Reference to the user the authenticator belongs to';
+COMMENT ON COLUMN auth.user_totp.secret IS 'Base32 TOTP secret shared with the authenticator app';
+COMMENT ON COLUMN auth.user_totp.confirmed_at IS 'Timestamp of when the user proved the app works; two-factor login is enforced from then on, NULL while enrolling';
+COMMENT ON COLUMN auth.user_totp.last_used_step IS 'Time step of the last accepted code, so a code can''t be replayed';
+COMMENT ON COLUMN auth.user_totp.created_at IS 'Timestamp of when the secret was generated';
+
+CREATE TABLE auth.user_recovery_code (
+    code_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user_recovery_code_user FOREIGN KEY (user_id) REFERENCES auth."user"(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_recovery_code_user_id ON auth.user_recovery_code (user_id);
+
+COMMENT ON TABLE auth.user_recovery_code IS 'Single-use codes that replace a TOTP code when the authenticator is lost';
+COMMENT ON COLUMN auth.user_recovery_code.code_hash IS 'SHA-256 hex digest of the normalized code; the code itself is only shown once';
+COMMENT ON COLUMN auth.user_recovery_code.user_id IS 'Reference to the user the code belongs to';
+COMMENT ON COLUMN auth.user_recovery_code.used_at IS 'Timestamp of when the code was used; NULL while usable';
+COMMENT ON COLUMN auth.user_recovery_code.created_at IS 'Timestamp of when the code was generated';
+
+CREATE TABLE auth.login_attempt (
+    attempt_key VARCHAR(330) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP,
+    locked_until TIMESTAMP
+);
+
+COMMENT ON TABLE auth.login_attempt IS 'Failed login attempts per account and per client address, used to back off and lock out';
+COMMENT ON COLUMN auth.login_attempt.attempt_key IS 'What the attempts are counted for: account:<email> or ip:<address>';
+COMMENT ON COLUMN auth.login_attempt.failures IS 'Consecutive failed attempts within the failure window';
+COMMENT ON COLUMN auth.login_attempt.last_failure_at IS 'Timestamp of the last failed attempt';
+COMMENT ON COLUMN auth.login_attempt.locked_until IS 'Timestamp until which logins are refused; NULL if never locked';
 
 CREATE TABLE auth.role (
     role_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -86,6 +180,54 @@ COMMENT ON COLUMN auth.user_role.user_id IS 'Identifier of the user';
 COMMENT ON COLUMN auth.user_role.role_id IS 'Identifier of the assigned role';
 COMMENT ON COLUMN auth.user_role.valid_until IS 'Date until the assignment is valid';
 
+CREATE INDEX idx_user_role_valid_until ON auth.user_role (valid_until);
+
+CREATE TABLE auth.user_role_zone (
+    user_id UUID,
+    role_id UUID,
+    zone_id UUID,
+    PRIMARY KEY (user_id, role_id, zone_id),
+    CONSTRAINT fk_user_role_zone_user_role FOREIGN KEY (user_id, role_id) REFERENCES auth.user_role(user_id, role_id) ON DELETE CASCADE
+    -- fk_user_role_zone_zone is added with treesense."zone"
+);
+
+CREATE INDEX ix_user_role_zone_zone ON auth.user_role_zone (zone_id);
+
+COMMENT ON TABLE auth.user_role_zone IS 'Zones a role assignment is limited to; an assignment without rows here applies everywhere';
+COMMENT ON COLUMN auth.user_role_zone.user_id IS 'Identifier of the user of the assignment';
+COMMENT ON COLUMN auth.user_role_zone.role_id IS 'Identifier of the role of the assignment';
+COMMENT ON COLUMN auth.user_role_zone.zone_id IS 'Identifier of the zone the assignment is limited to';
+
+CREATE TABLE auth.user_role_history (
+    user_role_history_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    role_id UUID,
+    role_name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP,
+    created_by UUID,
+    valid_until TIMESTAMP,
+    ended_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    end_reason VARCHAR(10) NOT NULL CHECK (end_reason IN ('EXPIRED', 'REVOKED')),
+    ended_by UUID,
+    CONSTRAINT fk_user_role_history_user FOREIGN KEY (user_id) REFERENCES auth."user"(user_id),
+    CONSTRAINT fk_user_role_history_role FOREIGN KEY (role_id) REFERENCES auth.role(role_id) ON DELETE SET NULL,
+    CONSTRAINT fk_user_role_history_ended_by FOREIGN KEY (ended_by) REFERENCES auth."user"(user_id)
+);
+
+CREATE INDEX idx_user_role_history_user_id ON auth.user_role_history (user_id);
+
+COMMENT ON TABLE auth.user_role_history IS 'Role assignments that ended, kept for auditing';
+COMMENT ON COLUMN auth.user_role_history.user_role_history_id IS 'Identifier of the history entry';
+COMMENT ON COLUMN auth.user_role_history.user_id IS 'Identifier of the user the role was assigned to';
+COMMENT ON COLUMN auth.user_role_history.role_id IS 'Identifier of the role that was assigned; NULL once the role is deleted';
+COMMENT ON COLUMN auth.user_role_history.role_name IS 'Name of the role when the assignment ended';
+COMMENT ON COLUMN auth.user_role_history.created_at IS 'Timestamp of when the assignment was made';
+COMMENT ON COLUMN auth.user_role_history.created_by IS 'Identifier of the user who made the assignment';
+COMMENT ON COLUMN auth.user_role_history.valid_until IS 'Date until the assignment was valid';
+COMMENT ON COLUMN auth.user_role_history.ended_at IS 'Timestamp of when the assignment was archived';
+COMMENT ON COLUMN auth.user_role_history.end_reason IS 'Why the assignment ended: EXPIRED when valid_until passed, REVOKED when it was deleted';
+COMMENT ON COLUMN auth.user_role_history.ended_by IS 'Identifier of the user who revoked the assignment; NULL for expirations';
+
 CREATE TABLE auth.permission (
     permission_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     permission_name VARCHAR(50) UNIQUE NOT NULL,
@@ -116,7 +258,7 @@ CREATE TABLE auth.role_permission (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     PRIMARY KEY (role_name, permission_name),
     CONSTRAINT fk_role_permission_permission FOREIGN KEY (permission_name) REFERENCES auth."permission"(permission_name),
-    CONSTRAINT fk_permission_role_role FOREIGN KEY (role_name) REFERENCES auth.role(role_name)
+    CONSTRAINT fk_permission_role_role FOREIGN KEY (role_name) REFERENCES auth.role(role_name) ON UPDATE CASCADE
 );
 
 COMMENT ON TABLE auth.role_permission IS 'Table for role assigment permissions in the application';
@@ -146,9 +288,9 @@ CREATE SCHEMA IF NOT EXISTS treesense;
 CREATE TABLE treesense."route" (
     route_id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
     user_id UUID NOT NULL,
-    route GEOMETRY(LineString, 4326) NOT NULL, -- TODO ver si es el mejor formato para almacenar la ruta en formato geoespacial
+    route GEOMETRY(LineString, 4326), -- built from treesense.route_point once the route has two points
     start_at TIMESTAMP NOT NULL,
-    end_at TIMESTAMP NOT NULL,
+    end_at TIMESTAMP, -- NULL while the route is open
     CONSTRAINT fk_route_user FOREIGN KEY (user_id) REFERENCES auth."user"(user_id)
 );
 
@@ -156,37 +298,131 @@ COMMENT ON TABLE treesense."route" IS 'Table of user routes';
 COMMENT ON COLUMN treesense."route".route_id IS 'Unique identifier for the route';
 COMMENT ON COLUMN treesense."route".user_id IS 'Unique identifier for the user than made that route';
 COMMENT ON COLUMN treesense."route".route IS 'Route (latitude, longitude)';
+COMMENT ON COLUMN treesense."route".end_at IS 'Timestamp of when the route was closed, NULL while it is open';
+
+CREATE UNIQUE INDEX ux_route_open_user ON treesense."route"(user_id) WHERE end_at IS NULL;
+
+
+CREATE TABLE treesense."route_point" (
+    route_point_id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+    route_id UUID NOT NULL,
+    location GEOMETRY(Point, 4326) NOT NULL,
+    recorded_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_route_point_route FOREIGN KEY (route_id) REFERENCES treesense."route"(route_id) ON DELETE CASCADE
+);
+
+CREATE INDEX ix_route_point_route ON treesense."route_point"(route_id, recorded_at);
+
+COMMENT ON TABLE treesense."route_point" IS 'GPS points recorded along a route';
+COMMENT ON COLUMN treesense."route_point".route_id IS 'Reference to the route the point belongs to';
+COMMENT ON COLUMN treesense."route_point".location IS 'Recorded position stored as a point (WGS 84 - SRID 4326)';
+COMMENT ON COLUMN treesense."route_point".recorded_at IS 'Timestamp of when the device recorded the point';
 
 
 CREATE TABLE treesense."tree_species" (
     tree_species_id VARCHAR(100) PRIMARY KEY,  
-    description TEXT  
+    scientific_name VARCHAR(150) UNIQUE NOT NULL,
+    family VARCHAR(100),
+    genus VARCHAR(100),
+    native BOOLEAN NOT NULL DEFAULT FALSE,
+    max_height FLOAT CHECK (max_height > 0),
+    growth_rate VARCHAR(10) CHECK (growth_rate IN ('SLOW', 'MEDIUM', 'FAST')),
+    description TEXT,
+    retired_at TIMESTAMP
 );
 
 COMMENT ON TABLE treesense."tree_species" IS 'Table storing different tree species';
 COMMENT ON COLUMN treesense."tree_species".tree_species_id IS 'Unique identifier for the tree species (code)';
+COMMENT ON COLUMN treesense."tree_species".scientific_name IS 'Accepted scientific (binomial) name of the species';
+COMMENT ON COLUMN treesense."tree_species".family IS 'Botanical family of the species';
+COMMENT ON COLUMN treesense."tree_species".genus IS 'Botanical genus of the species';
+COMMENT ON COLUMN treesense."tree_species".native IS 'Whether the species is native to the region (false means exotic)';
+COMMENT ON COLUMN treesense."tree_species".max_height IS 'Expected maximum height of a mature tree in meters';
+COMMENT ON COLUMN treesense."tree_species".growth_rate IS 'Expected growth rate: SLOW, MEDIUM or FAST';
 COMMENT ON COLUMN treesense."tree_species".description IS 'Additional information about the species';
+COMMENT ON COLUMN treesense."tree_species".retired_at IS 'Timestamp of when the species was retired from new surveys; NULL if active';
 
-INSERT INTO treesense."tree_species" (tree_species_id, description) VALUES
-('Quercus robur', 'Commonly known as English oak, native to Europe'),
-('Pinus sylvestris', 'Scots pine, widely distributed across Eurasia'),
-('Acer rubrum', 'Red maple, native to North America');
+INSERT INTO treesense."tree_species" (tree_species_id, scientific_name, family, genus, native, max_height, growth_rate, description) VALUES
+('Quercus robur', 'Quercus robur', 'Fagaceae', 'Quercus', FALSE, 40, 'SLOW', 'Commonly known as English oak, native to Europe'),
+('Pinus sylvestris', 'Pinus sylvestris', 'Pinaceae', 'Pinus', FALSE, 35, 'MEDIUM', 'Scots pine, widely distributed across Eurasia'),
+('Acer rubrum', 'Acer rubrum', 'Sapindaceae', 'Acer', FALSE, 30, 'FAST', 'Red maple, native to North America');
+
+
+CREATE TABLE treesense."tree_species_name" (
+    tree_species_id VARCHAR(100) NOT NULL,
+    language_code VARCHAR(10) NOT NULL,
+    common_name VARCHAR(150) NOT NULL,
+    description TEXT,
+    PRIMARY KEY (tree_species_id, language_code),
+    CONSTRAINT fk_tree_species_name_species FOREIGN KEY (tree_species_id) REFERENCES treesense."tree_species"(tree_species_id) ON DELETE CASCADE,
+    CONSTRAINT fk_tree_species_name_language FOREIGN KEY (language_code) REFERENCES conf.language(code)
+);
+
+COMMENT ON TABLE treesense."tree_species_name" IS 'Table storing the common names of each species per language';
+COMMENT ON COLUMN treesense."tree_species_name".tree_species_id IS 'Reference to the species';
+COMMENT ON COLUMN treesense."tree_species_name".language_code IS 'Language of the common name';
+COMMENT ON COLUMN treesense."tree_species_name".common_name IS 'Common name of the species in that language';
+COMMENT ON COLUMN treesense."tree_species_name".description IS 'Species description translated to that language; NULL falls back to tree_species.description';
+
+INSERT INTO treesense."tree_species_name" (tree_species_id, language_code, common_name, description) VALUES
+('Quercus robur', 'en', 'English oak', NULL),
+('Quercus robur', 'es', 'Roble común', 'Conocido como roble común o roble europeo, nativo de Europa'),
+('Quercus robur', 'zh', '夏栎', '又称英国栎，原产于欧洲'),
+('Pinus sylvestris', 'en', 'Scots pine', NULL),
+('Pinus sylvestris', 'es', 'Pino silvestre', 'Pino silvestre, ampliamente distribuido en Eurasia'),
+('Pinus sylvestris', 'zh', '欧洲赤松', '欧洲赤松，广泛分布于欧亚大陆'),
+('Acer rubrum', 'en', 'Red maple', NULL),
+('Acer rubrum', 'es', 'Arce rojo', 'Arce rojo, nativo de América del Norte'),
+('Acer rubrum', 'zh', '红花槭', '红花槭，原产于北美洲');
 
 
 
 CREATE TABLE treesense."tree_state" (
     tree_state_id VARCHAR(100) PRIMARY KEY,  
+    severity INT NOT NULL DEFAULT 0 CHECK (severity >= 0),
+    color VARCHAR(7) CHECK (color ~ '^#[0-9A-Fa-f]{6}$'),
     description TEXT  
 );
 
 COMMENT ON TABLE treesense."tree_state" IS 'Table storing different health states of trees';
 COMMENT ON COLUMN treesense."tree_state".tree_state_id IS 'Unique identifier for the tree state';
+COMMENT ON COLUMN treesense."tree_state".severity IS 'Ordering of the state from healthiest (0) to most severe';
+COMMENT ON COLUMN treesense."tree_state".color IS 'Hex color hint used to draw the state on maps (e.g., #2E7D32)';
 COMMENT ON COLUMN treesense."tree_state".description IS 'Additional information about the state';
 
-INSERT INTO treesense."tree_state" (tree_state_id, description) VALUES
-('Healthy', 'Tree is in good condition with no visible issues'),
-('Sick', 'Tree shows signs of disease or infestation'),
-('Dry', 'Tree appears to be dry or dying');
+INSERT INTO treesense."tree_state" (tree_state_id, severity, color, description) VALUES
+('Healthy', 0, '#2E7D32', 'Tree is in good condition with no visible issues'),
+('Sick', 1, '#F9A825', 'Tree shows signs of disease or infestation'),
+('Dry', 2, '#C62828', 'Tree appears to be dry or dying');
+
+
+CREATE TABLE treesense."tree_state_label" (
+    tree_state_id VARCHAR(100) NOT NULL,
+    language_code VARCHAR(10) NOT NULL,
+    label VARCHAR(100) NOT NULL,
+    description TEXT,
+    PRIMARY KEY (tree_state_id, language_code),
+    CONSTRAINT fk_tree_state_label_state FOREIGN KEY (tree_state_id) REFERENCES treesense."tree_state"(tree_state_id) ON DELETE CASCADE,
+    CONSTRAINT fk_tree_state_label_language FOREIGN KEY (language_code) REFERENCES conf.language(code)
+);
+
+COMMENT ON TABLE treesense."tree_state_label" IS 'Table storing the display label of each tree state per language';
+COMMENT ON COLUMN treesense."tree_state_label".tree_state_id IS 'Reference to the tree state';
+COMMENT ON COLUMN treesense."tree_state_label".language_code IS 'Language of the label';
+COMMENT ON COLUMN treesense."tree_state_label".label IS 'Label shown to users in that language';
+COMMENT ON COLUMN treesense."tree_state_label".description IS 'State description translated to that language; NULL falls back to tree_state.description';
+
+INSERT INTO treesense."tree_state_label" (tree_state_id, language_code, label, description) VALUES
+('Healthy', 'en', 'Healthy', NULL),
+('Healthy', 'es', 'Sano', 'El árbol está en buenas condiciones, sin problemas visibles'),
+('Healthy', 'zh', '健康', '树木状况良好，无明显问题'),
+('Sick', 'en', 'Sick', NULL),
+('Sick', 'es', 'Enfermo', 'El árbol muestra signos de enfermedad o plaga'),
+('Sick', 'zh', '患病', '树木有病害或虫害迹象'),
+('Dry', 'en', 'Dry', NULL),
+('Dry', 'es', 'Seco', 'El árbol parece seco o muriendo'),
+('Dry', 'zh', '干枯', '树木看起来干枯或濒临死亡');
 
 
 CREATE TABLE treesense."tree" (
@@ -198,7 +434,7 @@ CREATE TABLE treesense."tree" (
     age INT,
     height FLOAT,
     diameter FLOAT,
-    photo_url TEXT CHECK (photo_url ~* '^https?://.+') DEFAULT 'https://userphoto.png',
+    photo_url TEXT CHECK (photo_url ~* '^https?://.+'), -- external link, uploaded photos live in treesense.tree_photo
     description TEXT,
     created_by UUID,
     updated_by UUID,    
@@ -216,7 +452,7 @@ COMMENT ON TABLE treesense."tree" IS 'Table storing scanned trees along differen
 COMMENT ON COLUMN treesense."tree".tree_id IS 'Unique identifier for the tree';
 COMMENT ON COLUMN treesense."tree".route_id IS 'Reference to the route where the tree was scanned';
 COMMENT ON COLUMN treesense."tree".species IS 'Species name of the tree';
-COMMENT ON COLUMN treesense."tree".state IS 'State of the tree (e.g., healthy, sick, dry)';
+COMMENT ON COLUMN treesense."tree".state IS 'State of the tree (e.g., healthy, sick, dry), copied from the latest inspection';
 COMMENT ON COLUMN treesense."tree".location IS 'Geographic location of the tree stored as a point (WGS 84 - SRID 4326)';
 COMMENT ON COLUMN treesense."tree".age IS 'Approximate age of the tree in years';
 COMMENT ON COLUMN treesense."tree".height IS 'Height of the tree in meters';
@@ -225,6 +461,83 @@ COMMENT ON COLUMN treesense."tree".description IS 'Additional information about
 COMMENT ON COLUMN treesense."tree".created_at IS 'Timestamp of when the record was created';
 
 
+CREATE TABLE treesense."tree_photo" (
+    tree_photo_id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+    tree_id UUID NOT NULL,
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    uploaded_by UUID,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_tree_photo_tree FOREIGN KEY (tree_id) REFERENCES treesense."tree"(tree_id) ON DELETE CASCADE,
+    CONSTRAINT fk_tree_photo_uploaded_by FOREIGN KEY (uploaded_by) REFERENCES auth."user"(user_id)
+);
+
+CREATE INDEX ix_tree_photo_tree ON treesense."tree_photo"(tree_id, created_at);
+
+COMMENT ON TABLE treesense."tree_photo" IS 'Photos uploaded for a tree over time';
+COMMENT ON COLUMN treesense."tree_photo".storage_key IS 'Key of the original image in the blob store';
+COMMENT ON COLUMN treesense."tree_photo".thumbnail_key IS 'Key of the JPEG thumbnail in the blob store';
+COMMENT ON COLUMN treesense."tree_photo".size_bytes IS 'Size of the original image in bytes';
+
+
+CREATE TABLE treesense."tree_inspection" (
+    tree_inspection_id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+    tree_id UUID NOT NULL,
+    state VARCHAR(100) NOT NULL,
+    height FLOAT,
+    diameter FLOAT,
+    notes TEXT,
+    tree_photo_id UUID,
+    inspected_by UUID,
+    inspected_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_tree_inspection_tree FOREIGN KEY (tree_id) REFERENCES treesense."tree"(tree_id) ON DELETE CASCADE,
+    CONSTRAINT fk_tree_inspection_state FOREIGN KEY (state) REFERENCES treesense."tree_state"(tree_state_id),
+    CONSTRAINT fk_tree_inspection_photo FOREIGN KEY (tree_photo_id) REFERENCES treesense."tree_photo"(tree_photo_id) ON DELETE SET NULL,
+    CONSTRAINT fk_tree_inspection_inspected_by FOREIGN KEY (inspected_by) REFERENCES auth."user"(user_id)
+);
+
+CREATE INDEX ix_tree_inspection_tree ON treesense."tree_inspection"(tree_id, inspected_at);
+
+COMMENT ON TABLE treesense."tree_inspection" IS 'Dated observations of a tree, the latest one holds the current state and measurements';
+COMMENT ON COLUMN treesense."tree_inspection".state IS 'State observed during the inspection';
+COMMENT ON COLUMN treesense."tree_inspection".height IS 'Height measured during the inspection in meters';
+COMMENT ON COLUMN treesense."tree_inspection".diameter IS 'Trunk diameter measured during the inspection in centimeters';
+COMMENT ON COLUMN treesense."tree_inspection".inspected_at IS 'Timestamp of when the observation was made';
+
+
+CREATE TABLE treesense."zone" (
+    zone_id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+    zone_name VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    area GEOMETRY(MultiPolygon, 4326) NOT NULL,
+    created_by UUID,
+    updated_by UUID,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_zone_created_by FOREIGN KEY (created_by) REFERENCES auth."user"(user_id),
+    CONSTRAINT fk_zone_updated_by FOREIGN KEY (updated_by) REFERENCES auth."user"(user_id)
+);
+
+CREATE INDEX ix_zone_area ON treesense."zone" USING GIST (area);
+
+COMMENT ON TABLE treesense."zone" IS 'Districts the municipality splits its work into';
+COMMENT ON COLUMN treesense."zone".zone_id IS 'Unique identifier for the zone';
+COMMENT ON COLUMN treesense."zone".zone_name IS 'Name of the zone';
+COMMENT ON COLUMN treesense."zone".description IS 'Description of the zone';
+COMMENT ON COLUMN treesense."zone".area IS 'Boundary of the zone (WGS 84 - SRID 4326)';
+COMMENT ON COLUMN treesense."zone".created_by IS 'Identifier of the user who created the zone';
+COMMENT ON COLUMN treesense."zone".updated_by IS 'Identifier of the user who last updated the zone';
+COMMENT ON COLUMN treesense."zone".created_at IS 'Timestamp of when the zone was created';
+COMMENT ON COLUMN treesense."zone".updated_at IS 'Timestamp of when the zone was last updated';
+
+ALTER TABLE auth.user_role_zone ADD CONSTRAINT fk_user_role_zone_zone FOREIGN KEY (zone_id) REFERENCES treesense."zone"(zone_id);
+
+
 -- ===============================================
 -- Audit Schema: Schema for audit purpose
 -- ===============================================
@@ -236,9 +549,14 @@ CREATE TABLE audit."activity_log" (
     action_name VARCHAR(50) NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     user_id UUID,
+    detail VARCHAR(320),
     CONSTRAINT fk_activity_log_user_user FOREIGN KEY (user_id) REFERENCES auth."user"(user_id)
 );
 
+CREATE INDEX ix_activity_log_created_at ON audit."activity_log" (created_at DESC, activity_log_id DESC);
+CREATE INDEX ix_activity_log_user ON audit."activity_log" (user_id);
+
 COMMENT ON TABLE audit."activity_log" IS 'Table of audit for activitys of users';
 COMMENT ON COLUMN audit."activity_log".user_id IS 'Unique identifier for the user who made the action';
 COMMENT ON COLUMN audit."activity_log".action_name IS 'Action Name';
+COMMENT ON COLUMN audit."activity_log".detail IS 'What the action was about when the path does not say it, e.g. the locked email or address';
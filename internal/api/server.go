@@ -5,14 +5,23 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/PabloPei/TreeSense-Backend/conf"
 	"github.com/PabloPei/TreeSense-Backend/internal/audit"
+	"github.com/PabloPei/TreeSense-Backend/internal/auth"
+	"github.com/PabloPei/TreeSense-Backend/internal/inspections"
+	"github.com/PabloPei/TreeSense-Backend/internal/lockout"
 	"github.com/PabloPei/TreeSense-Backend/internal/middlewares"
 	"github.com/PabloPei/TreeSense-Backend/internal/permission"
+	"github.com/PabloPei/TreeSense-Backend/internal/photos"
 	"github.com/PabloPei/TreeSense-Backend/internal/roles"
+	"github.com/PabloPei/TreeSense-Backend/internal/routes"
 	"github.com/PabloPei/TreeSense-Backend/internal/trees"
 	"github.com/PabloPei/TreeSense-Backend/internal/users"
+	"github.com/PabloPei/TreeSense-Backend/internal/zones"
+	"github.com/PabloPei/TreeSense-Backend/pkg/mail"
+	"github.com/PabloPei/TreeSense-Backend/pkg/storage"
 	"github.com/gorilla/mux"
 )
 
@@ -40,21 +49,58 @@ func (s *APIServer) Run() error {
 	router.Use(middlewares.CORSMiddleware) //TODO: Chequear si dejar en prod
 	router.Use(middlewares.LoggingMiddleware)
 	router.Use(middlewares.RecoveryMiddleware)
+	router.Use(middlewares.LanguageMiddleware)
 	api := router.PathPrefix("/api/v1").Subrouter()
 
+	// Token signing keys
+	if err := auth.LoadSigningKeys(conf.ServerConfig.JWTKeysDir, conf.ServerConfig.JWTActiveKeyId); err != nil {
+		return err
+	}
+
+	// Blob storage
+	photoStore, err := storage.NewLocalStore(conf.StorageConfig.PhotoStorageDir)
+	if err != nil {
+		return err
+	}
+
+	// Outgoing email
+	var mailer mail.Mailer
+	if conf.MailConfig.Driver == "smtp" {
+		mailer = mail.NewSMTPMailer(conf.MailConfig.SMTPHost, conf.MailConfig.SMTPPort, conf.MailConfig.SMTPUsername, conf.MailConfig.SMTPPassword, conf.MailConfig.From)
+	} else {
+		mailer, err = mail.NewFileMailer(conf.MailConfig.FileDir, conf.MailConfig.From)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Repositories
 	userRepository := users.NewSQLRepository(s.db)
 	roleRepository := roles.NewSQLRepository(s.db)
 	treeRepository := trees.NewSQLRepository(s.db)
+	routeRepository := routes.NewSQLRepository(s.db)
+	photoRepository := photos.NewSQLRepository(s.db)
+	inspectionRepository := inspections.NewSQLRepository(s.db)
 	auditRepository := audit.NewSQLRepository(s.db)
 	permissionRepository := permission.NewSQLRepository(s.db)
+	zoneRepository := zones.NewSQLRepository(s.db)
+
+	var attemptStore lockout.AttemptStore = lockout.NewSQLRepository(s.db)
+	if conf.LoginGuardConfig.Store == "memory" {
+		attemptStore = lockout.NewMemoryStore()
+	}
 
 	// Services
-	userService := users.NewService(userRepository)
+	auditService := audit.NewService(auditRepository)
+	lockoutService := lockout.NewService(attemptStore, auditService, userRepository)
+	userService := users.NewService(userRepository, mailer, lockoutService)
 	roleService := roles.NewService(roleRepository, userRepository)
-	treeService := trees.NewService(treeRepository)
+	routeService := routes.NewService(routeRepository)
+	zoneService := zones.NewService(zoneRepository)
+	treeService := trees.NewService(treeRepository, routeService, zoneService)
+	photoService := photos.NewService(photoRepository, treeRepository, zoneService, photoStore)
+	inspectionService := inspections.NewService(inspectionRepository, treeRepository, photoRepository, zoneService)
 	permissionService := permission.NewService(permissionRepository, userRepository)
-	auditService := audit.NewService(auditRepository)
 
 	// Middlewares
 	authMiddleware := middlewares.NewAuthMiddleware(permissionService, userService, auditService)
@@ -62,11 +108,22 @@ func (s *APIServer) Run() error {
 
 	/// Subrouters
 
+	authHandler := auth.NewHandler()
+	authHandler.RegisterRoutes(router)
+
 	// without audit
 
 	treeRouter := api.PathPrefix("/tree").Subrouter()
 	treeHandler := trees.NewHandler(treeService)
 	treeHandler.RegisterRoutes(treeRouter, authMiddleware)
+	photoHandler := photos.NewHandler(photoService)
+	photoHandler.RegisterRoutes(treeRouter, authMiddleware)
+	inspectionHandler := inspections.NewHandler(inspectionService)
+	inspectionHandler.RegisterRoutes(treeRouter, authMiddleware)
+
+	routeRouter := api.PathPrefix("/route").Subrouter()
+	routeHandler := routes.NewHandler(routeService)
+	routeHandler.RegisterRoutes(routeRouter, authMiddleware)
 
 	// with audit
 	userRouter := api.PathPrefix("/user").Subrouter()
@@ -79,11 +136,28 @@ func (s *APIServer) Run() error {
 	roleHandler.RegisterRoutes(roleRouter, authMiddleware)
 	roleRouter.Use(auditMiddleware)
 
+	lockoutRouter := api.PathPrefix("/lockout").Subrouter()
+	lockoutHandler := lockout.NewHandler(lockoutService)
+	lockoutHandler.RegisterRoutes(lockoutRouter, authMiddleware)
+
+	zoneRouter := api.PathPrefix("/zone").Subrouter()
+	zoneRouter.Use(auditMiddleware)
+	zoneHandler := zones.NewHandler(zoneService)
+	zoneHandler.RegisterRoutes(zoneRouter, authMiddleware)
+
 	permissionRouter := api.PathPrefix("/permission").Subrouter()
 	permissionRouter.Use(auditMiddleware)
 	permissionHandler := permission.NewHandler(permissionService)
 	permissionHandler.RegisterRoutes(permissionRouter, authMiddleware)
 
+	auditRouter := api.PathPrefix("/audit").Subrouter()
+	auditRouter.Use(auditMiddleware)
+	auditHandler := audit.NewHandler(auditService)
+	auditHandler.RegisterRoutes(auditRouter, authMiddleware)
+
+	// Background jobs
+	go roleService.RunExpirySweeper(time.Duration(conf.ServerConfig.RoleExpirySweepInMinutes) * time.Minute)
+
 	log.Println("Server running on", s.addr)
 	return http.ListenAndServe(s.addr, router)
 }
@@ -1,15 +1,63 @@
 package audit
 
+import (
+	"net/http"
+	"time"
+)
+
 type ActivityLog struct {
     UserID []uint8 `json:"user_id"`
     Action string  `json:"action"`
+    Detail string  `json:"detail"`
+}
+
+// ActivityLogEntry is a logged activity as read back, with the name and email of the user who made it
+type ActivityLogEntry struct {
+	ActivityLogId []uint8   `json:"activityLogId"`
+	UserId        []uint8   `json:"userId"`
+	UserName      string    `json:"userName"`
+	Email         string    `json:"email"`
+	Action        string    `json:"action"`
+	Detail        string    `json:"detail"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type ActivityLogPage struct {
+	Entries    []ActivityLogEntry `json:"entries"`
+	NextCursor string             `json:"nextCursor,omitempty"`
+}
+
+// ActivityLogQuery holds every audit log filter; nil or empty fields are not applied
+type ActivityLogQuery struct {
+	UserId   []uint8
+	Email    string
+	Action   string // prefix of the action name, e.g. "delete" or "update_zone"
+	Resource string // case-insensitive text found in the action name or the detail, e.g. a zone id or an email
+	From     *time.Time
+	To       *time.Time
+	After    *ActivityLogCursor
+	Limit    int `validate:"min=0,max=500"` // 0 means no limit
+}
+
+// ActivityLogCursor points at the last entry of a page for keyset pagination
+type ActivityLogCursor struct {
+	CreatedAt     string `json:"t"`
+	ActivityLogId string `json:"id"`
 }
 
 type AuditService interface {
 	LogActivity(log ActivityLog) error
+	QueryActivity(query ActivityLogQuery, cursor string) (*ActivityLogPage, error)
+	ExportActivity(query ActivityLogQuery, fn func(entry ActivityLogEntry) error) error
 }
 
 type AuditRepository interface {
-	LogActivity(userID []uint8, action string) error
+	LogActivity(userID []uint8, action string, detail string) error
+	QueryActivity(query ActivityLogQuery) ([]ActivityLogEntry, error)
+	EachActivity(query ActivityLogQuery, fn func(entry ActivityLogEntry) error) error
 }
 
+// AuthMiddleware guards the audit routes. middlewares imports this package, so it can't be referenced directly.
+type AuthMiddleware interface {
+	RequireAuthAndPermission(permissions []string, useRefreshToken bool) func(http.HandlerFunc) http.HandlerFunc
+}
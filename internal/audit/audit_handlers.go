@@ -0,0 +1,273 @@
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/utils"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPageSize = 100
+
+	csvContentType    = "text/csv; charset=utf-8"
+	ndjsonContentType = "application/x-ndjson"
+)
+
+type Handler struct {
+	service AuditService
+}
+
+func NewHandler(service AuditService) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware AuthMiddleware) {
+
+	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleGetActivity)).Methods("GET")
+	router.HandleFunc("/export", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleExportActivity)).Methods("GET")
+}
+
+// Filters: userId, email, action (prefix), resource (text in the action or detail), from, to; paginated with limit and cursor
+func (h *Handler) handleGetActivity(w http.ResponseWriter, r *http.Request) {
+
+	params := r.URL.Query()
+
+	query, err := parseActivityLogQuery(params)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if !params.Has("limit") {
+		query.Limit = defaultPageSize
+	}
+
+	if err := utils.Validate.Struct(query); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	page, err := h.service.QueryActivity(*query, params.Get("cursor"))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// Same filters as the listing, without pagination. format=csv (default) or format=ndjson
+func (h *Handler) handleExportActivity(w http.ResponseWriter, r *http.Request) {
+
+	params := r.URL.Query()
+
+	format := params.Get("format")
+	if format != "" && format != "csv" && format != "ndjson" {
+		utils.WriteError(w, errors.ErrInvalidaPayload("format must be csv or ndjson"))
+		return
+	}
+
+	query, err := parseActivityLogQuery(params)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(query); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s", time.Now().UTC().Format("20060102-150405"))
+
+	var export activityLogExport
+	if format == "ndjson" {
+		export = newNDJSONExport(w, filename+".ndjson")
+	} else {
+		export = newCSVExport(w, filename+".csv")
+	}
+
+	err = h.service.ExportActivity(*query, export.write)
+	if err == nil {
+		err = export.finish()
+	}
+	if err != nil {
+		// Once the file has started the status is already sent, so the error can only be logged
+		if !export.started() {
+			utils.WriteError(w, err)
+			return
+		}
+		log.Println(err)
+	}
+}
+
+// Aux Functions
+
+// activityLogExport writes entries as a file download. Headers go out with the first entry,
+// so errors found before it can still be answered with an error status.
+type activityLogExport interface {
+	write(entry ActivityLogEntry) error
+	finish() error
+	started() bool
+}
+
+type csvExport struct {
+	w        http.ResponseWriter
+	filename string
+	writer   *csv.Writer
+}
+
+func newCSVExport(w http.ResponseWriter, filename string) *csvExport {
+	return &csvExport{w: w, filename: filename}
+}
+
+func (e *csvExport) start() error {
+	utils.WriteExportHeaders(e.w, csvContentType, e.filename)
+	e.writer = csv.NewWriter(e.w)
+	return e.writer.Write([]string{"activityLogId", "createdAt", "userId", "userName", "email", "action", "detail"})
+}
+
+func (e *csvExport) write(entry ActivityLogEntry) error {
+
+	if e.writer == nil {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	return e.writer.Write([]string{
+		string(entry.ActivityLogId),
+		entry.CreatedAt.Format(time.RFC3339),
+		string(entry.UserId),
+		escapeFormula(entry.UserName),
+		escapeFormula(entry.Email),
+		escapeFormula(entry.Action),
+		escapeFormula(entry.Detail),
+	})
+}
+
+// finish writes the header row alone when nothing matched, and flushes whatever is buffered
+func (e *csvExport) finish() error {
+
+	if e.writer == nil {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvExport) started() bool {
+	return e.writer != nil
+}
+
+type ndjsonExport struct {
+	w        http.ResponseWriter
+	filename string
+	encoder  *json.Encoder
+}
+
+func newNDJSONExport(w http.ResponseWriter, filename string) *ndjsonExport {
+	return &ndjsonExport{w: w, filename: filename}
+}
+
+func (e *ndjsonExport) start() {
+	utils.WriteExportHeaders(e.w, ndjsonContentType, e.filename)
+	e.encoder = json.NewEncoder(e.w)
+}
+
+func (e *ndjsonExport) write(entry ActivityLogEntry) error {
+
+	if e.encoder == nil {
+		e.start()
+	}
+
+	return e.encoder.Encode(entry)
+}
+
+// finish sends an empty file when nothing matched
+func (e *ndjsonExport) finish() error {
+
+	if e.encoder == nil {
+		e.start()
+	}
+
+	return nil
+}
+
+func (e *ndjsonExport) started() bool {
+	return e.encoder != nil
+}
+
+// escapeFormula keeps spreadsheets from running user controlled text as a formula (CSV injection)
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func parseActivityLogQuery(params url.Values) (*ActivityLogQuery, error) {
+
+	var err error
+	query := &ActivityLogQuery{
+		Email:    params.Get("email"),
+		Action:   params.Get("action"),
+		Resource: params.Get("resource"),
+	}
+
+	if userId := params.Get("userId"); userId != "" {
+		if !utils.IsUUID(userId) {
+			return nil, errors.ErrInvalidaPayload("userId must be a user id")
+		}
+		query.UserId = []uint8(userId)
+	}
+
+	if query.From, err = parseOptionalTime(params, "from", false); err != nil {
+		return nil, err
+	}
+	if query.To, err = parseOptionalTime(params, "to", true); err != nil {
+		return nil, err
+	}
+
+	if params.Has("limit") {
+		if query.Limit, err = strconv.Atoi(params.Get("limit")); err != nil {
+			return nil, errors.ErrInvalidaPayload("limit must be an integer")
+		}
+	}
+
+	return query, nil
+}
+
+// Dates are accepted as RFC 3339 timestamps or plain YYYY-MM-DD days. endOfDay makes a plain day inclusive.
+func parseOptionalTime(params url.Values, name string, endOfDay bool) (*time.Time, error) {
+
+	if !params.Has(name) {
+		return nil, nil
+	}
+
+	if value, err := time.Parse(time.RFC3339, params.Get(name)); err == nil {
+		return &value, nil
+	}
+
+	if value, err := time.Parse(time.DateOnly, params.Get(name)); err == nil {
+		if endOfDay {
+			value = value.Add(24*time.Hour - time.Microsecond)
+		}
+		return &value, nil
+	}
+
+	return nil, errors.ErrInvalidDateParam(name)
+}
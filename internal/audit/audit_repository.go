@@ -3,6 +3,9 @@ package audit
 import (
     "database/sql"
     "fmt"
+    "strings"
+
+    "github.com/PabloPei/TreeSense-Backend/internal/errors"
 )
 
 type SQLRepository struct {
@@ -14,14 +17,126 @@ func NewSQLRepository(db *sql.DB) *SQLRepository {
 	return &SQLRepository{db: db}
 }
 
-func (r *SQLRepository) LogActivity(userID []uint8, action string) error {
+func (r *SQLRepository) LogActivity(userID []uint8, action string, detail string) error {
 	_, err := r.db.Exec(`
-		INSERT INTO audit."activity_log" (user_id, action_name)
-		VALUES ($1, $2)
-	`, userID, action)
+		INSERT INTO audit."activity_log" (user_id, action_name, detail)
+		VALUES ($1, $2, NULLIF($3, ''))
+	`, userID, action, detail)
 	if err != nil {
 		return fmt.Errorf("failed to log activity: %w", err)
 	}
 	return nil
 }
 
+
+// Activity log columns in scanRowIntoActivityLogEntry order, with the user joined in
+const activityLogColumns = `a.activity_log_id, a.user_id, COALESCE(u.user_name, ''), COALESCE(u.email, ''),
+	a.action_name, COALESCE(a.detail, ''), a.created_at`
+
+// QueryActivity returns the newest entries first; activity_log_id breaks ties so the cursor always points at a single row
+func (r *SQLRepository) QueryActivity(query ActivityLogQuery) ([]ActivityLogEntry, error) {
+
+	entries := []ActivityLogEntry{}
+	err := r.EachActivity(query, func(entry ActivityLogEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// EachActivity hands the entries to fn in QueryActivity order as they are read, stopping at the first error fn returns
+func (r *SQLRepository) EachActivity(query ActivityLogQuery, fn func(entry ActivityLogEntry) error) error {
+
+	var conditions []string
+	var args []interface{}
+
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if query.UserId != nil {
+		conditions = append(conditions, "a.user_id = "+arg(query.UserId))
+	}
+	if query.Email != "" {
+		conditions = append(conditions, "u.email = "+arg(query.Email))
+	}
+	if query.Action != "" {
+		conditions = append(conditions, "a.action_name LIKE "+arg(escapeLike(query.Action)+"%"))
+	}
+	if query.Resource != "" {
+		pattern := arg("%" + escapeLike(query.Resource) + "%")
+		conditions = append(conditions, fmt.Sprintf("(a.action_name ILIKE %s OR a.detail ILIKE %s)", pattern, pattern))
+	}
+	if query.From != nil {
+		conditions = append(conditions, "a.created_at >= "+arg(query.From.UTC()))
+	}
+	if query.To != nil {
+		conditions = append(conditions, "a.created_at <= "+arg(query.To.UTC()))
+	}
+	if query.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(a.created_at, a.activity_log_id) < (%s::timestamp, %s::uuid)",
+			arg(query.After.CreatedAt), arg(query.After.ActivityLogId)))
+	}
+
+	statement := "SELECT " + activityLogColumns + " FROM audit.\"activity_log\" a LEFT JOIN auth.\"user\" u ON u.user_id = a.user_id"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY a.created_at DESC, a.activity_log_id DESC"
+	if query.Limit > 0 {
+		statement += " LIMIT " + arg(query.Limit)
+	}
+
+	rows, err := r.db.Query(statement, args...)
+	if err != nil {
+		return errors.ErrReadingActivityLog(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanRowIntoActivityLogEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*entry); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return errors.ErrReadingActivityLog(err.Error())
+	}
+
+	return nil
+}
+
+// Aux Functions
+
+func scanRowIntoActivityLogEntry(rows *sql.Rows) (*ActivityLogEntry, error) {
+
+	entry := new(ActivityLogEntry)
+	err := rows.Scan(
+		&entry.ActivityLogId,
+		&entry.UserId,
+		&entry.UserName,
+		&entry.Email,
+		&entry.Action,
+		&entry.Detail,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return nil, errors.ErrReadingActivityLog(err.Error())
+	}
+
+	return entry, nil
+}
+
+// escapeLike makes the LIKE wildcards in a filter match themselves
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
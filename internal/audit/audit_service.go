@@ -1,5 +1,15 @@
 package audit
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/utils"
+)
+
 type Service struct {
 	repository AuditRepository
 }
@@ -9,5 +19,95 @@ func NewService(repository AuditRepository) *Service {
 }
 
 func (s *Service) LogActivity(log ActivityLog) error {
-	return s.repository.LogActivity(log.UserID, log.Action)
-}
\ No newline at end of file
+	return s.repository.LogActivity(log.UserID, log.Action, log.Detail)
+}
+
+// QueryActivity returns one page of the audit log. cursor is the nextCursor of the previous page, empty for the first one.
+func (s *Service) QueryActivity(query ActivityLogQuery, cursor string) (*ActivityLogPage, error) {
+
+	if cursor != "" {
+		after, err := decodeActivityLogCursor(cursor)
+		if err != nil {
+			return nil, errors.ErrInvalidaPayload("invalid cursor")
+		}
+		query.After = after
+	}
+
+	// One extra row tells whether there is a next page
+	limit := query.Limit
+	if limit > 0 {
+		query.Limit = limit + 1
+	}
+
+	entries, err := s.repository.QueryActivity(query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ActivityLogPage{Entries: entries}
+	if limit > 0 && len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextCursor = encodeActivityLogCursor(page.Entries[limit-1])
+	}
+
+	convertToArgentina(page.Entries)
+
+	return page, nil
+}
+
+// ExportActivity hands every entry matching the query to fn as it is read, for compliance reviews.
+// Entries are streamed rather than loaded, so the whole log can be exported.
+func (s *Service) ExportActivity(query ActivityLogQuery, fn func(entry ActivityLogEntry) error) error {
+
+	query.After = nil
+	query.Limit = 0
+
+	return s.repository.EachActivity(query, func(entry ActivityLogEntry) error {
+		entry.CreatedAt = utils.ConvertUTCToArgentina(entry.CreatedAt)
+		return fn(entry)
+	})
+}
+
+// Aux Functions
+
+func convertToArgentina(entries []ActivityLogEntry) {
+	for i := range entries {
+		entries[i].CreatedAt = utils.ConvertUTCToArgentina(entries[i].CreatedAt)
+	}
+}
+
+// activityLogCursorTimeLayout keeps the microseconds postgres stores so no entry is skipped between pages
+const activityLogCursorTimeLayout = "2006-01-02T15:04:05.999999"
+
+// The cursor is built before the time zone conversion, so it keeps the stored UTC timestamp
+func encodeActivityLogCursor(entry ActivityLogEntry) string {
+
+	cursor, _ := json.Marshal(ActivityLogCursor{
+		CreatedAt:     entry.CreatedAt.Format(activityLogCursorTimeLayout),
+		ActivityLogId: string(entry.ActivityLogId),
+	})
+
+	return base64.RawURLEncoding.EncodeToString(cursor)
+}
+
+func decodeActivityLogCursor(cursor string) (*ActivityLogCursor, error) {
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	after := new(ActivityLogCursor)
+	if err := json.Unmarshal(raw, after); err != nil {
+		return nil, err
+	}
+
+	if !utils.IsUUID(after.ActivityLogId) {
+		return nil, fmt.Errorf("invalid cursor activity log id %q", after.ActivityLogId)
+	}
+	if _, err := time.Parse(activityLogCursorTimeLayout, after.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	return after, nil
+}
@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/PabloPei/TreeSense-Backend/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct{}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// RegisterRoutes mounts the public key discovery endpoint, which lives at the root and not under the API prefix
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/.well-known/jwks.json", h.handleJWKS).Methods("GET")
+}
+
+func (h *Handler) handleJWKS(w http.ResponseWriter, r *http.Request) {
+
+	// Verifiers may cache the keys for a while; a new key is published before it starts signing
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.WriteJSON(w, http.StatusOK, JWKS())
+}
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	stderrors "errors"
 	"time"
 
 	"github.com/PabloPei/TreeSense-Backend/conf"
@@ -8,35 +11,99 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+)
+
+// Authentication methods (RFC 8176) listed in the amr claim
+const (
+	passwordMethod = "pwd"
+	otpMethod      = "otp"
+)
+
 type UserJWT struct {
-	UserId   string
-	Email    string
-	UserName string
+	UserId    string
+	Email     string
+	UserName  string
+	SessionId string
+	TokenId   string // jti of the refresh token, empty for access tokens
+	TwoFactor bool   // the login passed a second factor
+}
+
+// Claims are the standard registered claims plus the TreeSense ones. The subject is the user id.
+type Claims struct {
+	jwt.RegisteredClaims
+	Email     string   `json:"email"`
+	UserName  string   `json:"userName"`
+	SessionId string   `json:"sid"`
+	TokenType string   `json:"typ"`
+	Methods   []string `json:"amr"`
+}
+
+// HasTwoFactor reports whether the token comes from a login that passed a second factor
+func (c *Claims) HasTwoFactor() bool {
+	for _, method := range c.Methods {
+		if method == otpMethod {
+			return true
+		}
+	}
+	return false
 }
 
+// SessionChecker tells whether a login session is still active, so tokens of logged out sessions are rejected
+type SessionChecker interface {
+	IsSessionActive(sessionId string) (bool, error)
+}
+
+// CreateJWT signs a token with the active key. Refresh tokens are addressed to the issuer itself, so other
+// services accepting access tokens never accept them.
 func CreateJWT(user UserJWT, refreshToken bool) (string, error) {
 
-	var expirationTime int64
-	var secret []byte
+	if signingKeys == nil {
+		return "", errors.ErrJWTCreation
+	}
+
+	now := time.Now().UTC()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    conf.ServerConfig.JWTIssuer,
+			Subject:   user.UserId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+		Email:     user.Email,
+		UserName:  user.UserName,
+		SessionId: user.SessionId,
+		Methods:   []string{passwordMethod},
+	}
+	if user.TwoFactor {
+		claims.Methods = append(claims.Methods, otpMethod)
+	}
 
 	if refreshToken {
-		secret = []byte(conf.ServerConfig.RefreshTokenSecret)
 		expiration := time.Duration(conf.ServerConfig.RefreshTokenExpirationInHours) * time.Hour
-		expirationTime = time.Now().UTC().Add(expiration).Unix()
+		claims.Audience = jwt.ClaimStrings{conf.ServerConfig.JWTIssuer}
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(expiration))
+		claims.ID = user.TokenId
+		claims.TokenType = refreshTokenType
 	} else {
-		secret = []byte(conf.ServerConfig.JWTSecret)
+		tokenId, err := newTokenId()
+		if err != nil {
+			return "", err
+		}
 		expiration := time.Duration(conf.ServerConfig.JWTExpirationInSeconds) * time.Second
-		expirationTime = time.Now().UTC().Add(expiration).Unix()
+		claims.Audience = jwt.ClaimStrings{conf.ServerConfig.JWTAudience}
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(expiration))
+		claims.ID = tokenId
+		claims.TokenType = accessTokenType
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userId":    user.UserId,
-		"email":     user.Email,
-		"userName":  user.UserName,
-		"expiresAt": expirationTime,
-	})
+	key := signingKeys.active
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
 
-	tokenString, err := token.SignedString(secret)
+	tokenString, err := token.SignedString(key.private)
 	if err != nil {
 		return "", err
 	}
@@ -44,40 +111,75 @@ func CreateJWT(user UserJWT, refreshToken bool) (string, error) {
 	return tokenString, nil
 }
 
-func ValidateJWT(tokenString string, refreshToken bool) (jwt.MapClaims, error) {
+// ValidateJWT checks the signature, the registered claims and that the token's session is still active
+func ValidateJWT(tokenString string, refreshToken bool, sessions SessionChecker) (*Claims, error) {
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.ErrSignMethod(token.Header["alg"].(string))
-		}
+	audience := conf.ServerConfig.JWTAudience
+	tokenType := accessTokenType
+	if refreshToken {
+		audience = conf.ServerConfig.JWTIssuer
+		tokenType = refreshTokenType
+	}
 
-		if refreshToken {
-			return []byte(conf.ServerConfig.RefreshTokenSecret), nil
-		} else {
-			return []byte(conf.ServerConfig.JWTSecret), nil
+	claims := new(Claims)
+	_, err := jwt.ParseWithClaims(tokenString, claims, verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(conf.ServerConfig.JWTIssuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+
+	if err != nil {
+		if stderrors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errors.ErrJWTTokenExpired
 		}
+		return nil, errors.ErrJWTInvalidToken
+	}
 
-	})
+	if claims.TokenType != tokenType || claims.Subject == "" || claims.SessionId == "" {
+		return nil, errors.ErrJWTInvalidToken
+	}
 
+	active, err := sessions.IsSessionActive(claims.SessionId)
 	if err != nil {
 		return nil, err
 	}
+	if !active {
+		return nil, errors.ErrSessionRevoked
+	}
+
+	return claims, nil
+}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
+// Aux Functions
+
+// verificationKey picks the public key named by the token's kid header
+func verificationKey(token *jwt.Token) (interface{}, error) {
+
+	if signingKeys == nil {
 		return nil, errors.ErrJWTInvalidToken
 	}
 
-	exp, ok := claims["expiresAt"].(float64)
+	keyId, _ := token.Header["kid"].(string)
+	key, ok := signingKeys.keys[keyId]
 	if !ok {
 		return nil, errors.ErrJWTInvalidToken
 	}
 
-	expirationTime := int64(exp)
-	currentTime := time.Now().UTC().Unix()
-	if currentTime > expirationTime {
-		return nil, errors.ErrJWTTokenExpired
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.ErrSignMethod(token.Method.Alg())
 	}
 
-	return claims, nil
+	return key.private.Public(), nil
+}
+
+func newTokenId() (string, error) {
+
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buffer), nil
 }
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// keySet holds every key tokens may be signed with. Only the active one signs; keeping the retired ones lets
+// tokens issued before a rotation stay valid until they expire.
+type keySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+var signingKeys *keySet
+
+// JSONWebKey is the public half of a signing key as published in the JWKS
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyId     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// LoadSigningKeys reads the RSA and Ed25519 private keys in dir, one PEM file per key named <kid>.pem.
+// When dir holds no keys an ephemeral Ed25519 key is generated, so development setups work without any files.
+func LoadSigningKeys(dir string, activeKeyId string) error {
+
+	set := &keySet{keys: map[string]*signingKey{}}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		key, err := readSigningKey(path)
+		if err != nil {
+			return err
+		}
+		set.keys[key.id] = key
+	}
+
+	if len(set.keys) == 0 {
+		log.Printf("no JWT signing keys found in %s, using an ephemeral key: tokens won't survive a restart", dir)
+
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		key := &signingKey{id: "ephemeral", method: jwt.SigningMethodEdDSA, private: private}
+		set.keys[key.id] = key
+		set.active = key
+		signingKeys = set
+
+		return nil
+	}
+
+	switch {
+	case activeKeyId != "":
+		set.active = set.keys[activeKeyId]
+		if set.active == nil {
+			return fmt.Errorf("active JWT key %s not found in %s", activeKeyId, dir)
+		}
+	case len(set.keys) == 1:
+		for _, key := range set.keys {
+			set.active = key
+		}
+	default:
+		return fmt.Errorf("%d JWT keys found in %s, set which one signs with JWT_ACTIVE_KEY_ID", len(set.keys), dir)
+	}
+
+	signingKeys = set
+
+	return nil
+}
+
+// JWKS returns the public keys tokens are verified with
+func JWKS() JSONWebKeySet {
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	if signingKeys == nil {
+		return set
+	}
+
+	ids := make([]string, 0, len(signingKeys.keys))
+	for id := range signingKeys.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		key := signingKeys.keys[id]
+		jwk := JSONWebKey{KeyId: key.id, Use: "sig", Algorithm: key.method.Alg()}
+
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// Aux Functions
+
+func readSigningKey(path string) (*signingKey, error) {
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+
+	var private interface{}
+	if block.Type == "RSA PRIVATE KEY" {
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't parse JWT key %s: %v", path, err)
+	}
+
+	id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	switch key := private.(type) {
+	case *rsa.PrivateKey:
+		return &signingKey{id: id, method: jwt.SigningMethodRS256, private: key}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{id: id, method: jwt.SigningMethodEdDSA, private: key}, nil
+	default:
+		return nil, fmt.Errorf("JWT key %s must be an RSA or Ed25519 private key", path)
+	}
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewOpaqueToken returns a random single-use token to send to the user and the hash to store in its place
+func NewOpaqueToken() (string, string, error) {
+
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buffer)
+
+	return token, HashOpaqueToken(token), nil
+}
+
+// HashOpaqueToken returns the SHA-256 hex digest under which an opaque token is stored
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,80 +3,347 @@ package errors
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 )
 
+// Error is the error every layer returns. Code is stable for clients to branch on, Status is the HTTP status it maps to,
+// and the message template is kept apart from its arguments so it can be translated before it is written.
+type Error struct {
+	Code     string
+	Status   int
+	Details  interface{}
+	template string
+	args     []interface{}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf(e.template, e.args...)
+}
+
+// Is matches errors of the same code, so errors.Is works on errors carrying details
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Template returns the untranslated message with its fmt verbs
+func (e *Error) Template() string {
+	return e.template
+}
+
+func (e *Error) Args() []interface{} {
+	return e.args
+}
+
+func newError(code string, status int, template string, args ...interface{}) error {
+	return &Error{Code: code, Status: status, template: template, args: args}
+}
+
+// FieldError describes one invalid field of a payload, named as the client sent it
+type FieldError struct {
+	Field    string `json:"field"`
+	Rule     string `json:"rule"`
+	Param    string `json:"param,omitempty"`
+	Message  string `json:"message"`
+	template string
+}
+
+// NewFieldError renders template, replacing %s with param when present
+func NewFieldError(field string, rule string, param string, template string) FieldError {
+	message := template
+	if strings.Contains(template, "%s") {
+		message = fmt.Sprintf(template, param)
+	}
+	return FieldError{Field: field, Rule: rule, Param: param, Message: message, template: template}
+}
+
+// Template returns the untranslated message with its fmt verbs
+func (f FieldError) Template() string {
+	return f.template
+}
+
+// AsError returns err as an *Error, treating untyped errors as internal ones
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return ErrInternal(err.Error()).(*Error)
+}
+
+// WithDetails returns a copy of err carrying extra data for the client, e.g. the offending fields
+func WithDetails(err error, details interface{}) error {
+	e, ok := err.(*Error)
+	if !ok {
+		return err
+	}
+	withDetails := *e
+	withDetails.Details = details
+	return &withDetails
+}
+
 var (
-	ErrInvalidCredentials    = errors.New("invalid email or password")
-	ErrJWTCreation           = errors.New("unable to create JWT token")
-	ErrJWTInvalidToken       = errors.New("error authenticating user: Token not valid")
-	ErrJWTTokenExpired       = errors.New("error authenticating user: JWT token expired")
-	ErrUploadPhoto           = errors.New("unable to upload photo")
-	ErrRoleAssigmentExist    = errors.New("role assigment already exist")
-	ErrUserNotFound          = errors.New("user not found")
-	ErrTreeNotFound          = errors.New("tree not found")
-	ErrTreeSpeciesNotFound   = errors.New("tree species not found")
-	ErrTreeStateNotFound     = errors.New("tree state not found")
-	ErrRoleNotFound          = errors.New("role not found")
-	ErrPermissionNotFound    = errors.New("permission not found")
-	ErrRoleAssigmentNotExist = errors.New("role assigment doesn't exist")
-	ErrCantDeleteRole        = func(err string) error {
-		return fmt.Errorf("can't delete role assigment: %v", err)
+	ErrInvalidCredentials      = newError("INVALID_CREDENTIALS", http.StatusUnauthorized, "invalid email or password")
+	ErrJWTCreation             = newError("JWT_CREATION_FAILED", http.StatusInternalServerError, "unable to create JWT token")
+	ErrJWTInvalidToken         = newError("INVALID_TOKEN", http.StatusUnauthorized, "error authenticating user: Token not valid")
+	ErrJWTTokenExpired         = newError("TOKEN_EXPIRED", http.StatusUnauthorized, "error authenticating user: JWT token expired")
+	ErrUploadPhoto             = newError("USER_PHOTO_UPLOAD_FAILED", http.StatusBadRequest, "unable to upload photo")
+	ErrRoleAssigmentExist      = newError("ROLE_ASSIGNMENT_ALREADY_EXISTS", http.StatusConflict, "role assigment already exist")
+	ErrUserNotFound            = newError("USER_NOT_FOUND", http.StatusNotFound, "user not found")
+	ErrTreeNotFound            = newError("TREE_NOT_FOUND", http.StatusNotFound, "tree not found")
+	ErrTreeSpeciesNotFound     = newError("TREE_SPECIES_NOT_FOUND", http.StatusNotFound, "tree species not found")
+	ErrTreeStateNotFound       = newError("TREE_STATE_NOT_FOUND", http.StatusNotFound, "tree state not found")
+	ErrTreeSpeciesRetired      = newError("TREE_SPECIES_RETIRED", http.StatusConflict, "tree species is retired and can't be used in new surveys")
+	ErrTreeStateInUse          = newError("TREE_STATE_IN_USE", http.StatusConflict, "tree state is used by existing trees or inspections")
+	ErrRoleNotFound            = newError("ROLE_NOT_FOUND", http.StatusNotFound, "role not found")
+	ErrPermissionNotFound      = newError("PERMISSION_NOT_FOUND", http.StatusNotFound, "permission not found")
+	ErrRoleAssigmentNotExist   = newError("ROLE_ASSIGNMENT_NOT_FOUND", http.StatusNotFound, "role assigment doesn't exist")
+	ErrRouteNotFound           = newError("ROUTE_NOT_FOUND", http.StatusNotFound, "route not found")
+	ErrRouteClosed             = newError("ROUTE_CLOSED", http.StatusConflict, "route is already closed")
+	ErrRouteAlreadyOpen        = newError("ROUTE_ALREADY_OPEN", http.StatusConflict, "user already has an open route")
+	ErrRouteNotOwned           = newError("ROUTE_NOT_OWNED", http.StatusForbidden, "route belongs to another user")
+	ErrPhotoNotFound           = newError("PHOTO_NOT_FOUND", http.StatusNotFound, "photo not found")
+	ErrPhotoDecode             = newError("PHOTO_DECODE_FAILED", http.StatusBadRequest, "photo is not a valid image")
+	ErrInspectionInFuture      = newError("INSPECTION_IN_FUTURE", http.StatusBadRequest, "inspection date can't be in the future")
+	ErrLanguageNotFound        = newError("LANGUAGE_NOT_FOUND", http.StatusBadRequest, "language not found")
+	ErrSessionRevoked          = newError("SESSION_REVOKED", http.StatusUnauthorized, "session has been logged out")
+	ErrRefreshTokenReused      = newError("REFRESH_TOKEN_REUSED", http.StatusUnauthorized, "refresh token was already used; the session has been revoked")
+	ErrTwoFactorRequired       = newError("TWO_FACTOR_REQUIRED", http.StatusForbidden, "this action requires signing in with two-factor authentication")
+	ErrTwoFactorAlreadyEnabled = newError("TWO_FACTOR_ALREADY_ENABLED", http.StatusConflict, "two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = newError("TWO_FACTOR_NOT_ENABLED", http.StatusConflict, "two-factor authentication is not enabled")
+	ErrInvalidTwoFactorCode    = newError("INVALID_TWO_FACTOR_CODE", http.StatusUnauthorized, "invalid two-factor code")
+	ErrInvalidUserToken        = newError("INVALID_USER_TOKEN", http.StatusBadRequest, "token is invalid, expired or already used")
+	ErrEmailNotVerified        = newError("EMAIL_NOT_VERIFIED", http.StatusForbidden, "email address is not verified")
+	ErrEmailAlreadyVerified    = newError("EMAIL_ALREADY_VERIFIED", http.StatusConflict, "email address is already verified")
+	ErrRoleInUse               = newError("ROLE_IN_USE", http.StatusConflict, "role is still assigned to users; revoke or reassign those assignments first")
+	ErrRolePermissionExist     = newError("ROLE_PERMISSION_ALREADY_EXISTS", http.StatusConflict, "role already has this permission")
+	ErrRolePermissionNotExist  = newError("ROLE_PERMISSION_NOT_FOUND", http.StatusNotFound, "role doesn't have this permission")
+	ErrLastAdmin               = newError("LAST_ADMIN", http.StatusConflict, "can't remove the ADMIN role from the last user holding it")
+	ErrAdminRoleRename         = newError("ADMIN_ROLE_RENAME", http.StatusConflict, "the ADMIN role can't be renamed")
+	ErrAdminRoleDelete         = newError("ADMIN_ROLE_DELETE", http.StatusConflict, "the ADMIN role can't be deleted")
+	ErrAdminRolePermission     = newError("ADMIN_ROLE_PERMISSION", http.StatusConflict, "permissions can't be revoked from the ADMIN role")
+	ErrZoneNotFound            = newError("ZONE_NOT_FOUND", http.StatusNotFound, "zone not found")
+	ErrZoneInUse               = newError("ZONE_IN_USE", http.StatusConflict, "zone is used by role assignments")
+	ErrOutsideZone             = newError("OUTSIDE_ZONE", http.StatusForbidden, "location is outside the zones you can work in")
+	ErrZoneScopedConfig        = newError("ZONE_SCOPED_CONFIG", http.StatusForbidden, "zones can't be managed with a zone-limited CONFIG permission")
+	ErrRoleValidUntilInPast    = newError("ROLE_VALID_UNTIL_IN_PAST", http.StatusBadRequest, "role assigment valid until date must be in the future")
+	ErrCantDeleteRole          = func(err string) error {
+		return newError("ROLE_ASSIGNMENT_DELETE_FAILED", http.StatusInternalServerError, "can't delete role assigment: %v", err)
 	}
 	ErrLogActivity = func(err error) error {
-		return fmt.Errorf("can't log activity: %v", err)
+		return newError("ACTIVITY_LOG_FAILED", http.StatusInternalServerError, "can't log activity: %v", err)
 	}
 	ErrReadingPermission = func(err string) error {
-		return fmt.Errorf("can't read user permissions: %v", err)
+		return newError("PERMISSION_READ_FAILED", http.StatusInternalServerError, "can't read user permissions: %v", err)
 	}
 	ErrCantUploadRole = func(err string) error {
-		return fmt.Errorf("can't upload role info: %v", err)
+		return newError("ROLE_SAVE_FAILED", http.StatusInternalServerError, "can't upload role info: %v", err)
 	}
 	ErrCantUploadTree = func(err string) error {
-		return fmt.Errorf("can't create tree: %v", err)
+		return newError("TREE_CREATE_FAILED", http.StatusInternalServerError, "can't create tree: %v", err)
+	}
+	ErrCantUpdateTree = func(err string) error {
+		return newError("TREE_UPDATE_FAILED", http.StatusInternalServerError, "can't update tree: %v", err)
+	}
+	ErrCantDeleteTree = func(err string) error {
+		return newError("TREE_DELETE_FAILED", http.StatusInternalServerError, "can't delete tree: %v", err)
+	}
+	ErrCantUploadRoute = func(err string) error {
+		return newError("ROUTE_SAVE_FAILED", http.StatusInternalServerError, "can't upload route: %v", err)
+	}
+	ErrRouteScan = func(err string) error {
+		return newError("ROUTE_READ_FAILED", http.StatusInternalServerError, "error scanning route: %v", err)
+	}
+	ErrCantStorePhoto = func(err string) error {
+		return newError("PHOTO_STORE_FAILED", http.StatusInternalServerError, "can't store photo: %v", err)
+	}
+	ErrPhotoScan = func(err string) error {
+		return newError("PHOTO_READ_FAILED", http.StatusInternalServerError, "error scanning photo: %v", err)
+	}
+	ErrPhotoTooLarge = func(maxBytes int64) error {
+		return WithDetails(newError("PHOTO_TOO_LARGE", http.StatusRequestEntityTooLarge, "photo exceeds the maximum size of %d bytes", maxBytes), map[string]int64{"maxBytes": maxBytes})
+	}
+	ErrPhotoTooManyPixels = func(maxPixels int64) error {
+		return WithDetails(newError("PHOTO_TOO_MANY_PIXELS", http.StatusRequestEntityTooLarge, "photo exceeds the maximum of %d pixels", maxPixels), map[string]int64{"maxPixels": maxPixels})
+	}
+	ErrPhotoContentType = func(contentType string) error {
+		return newError("PHOTO_CONTENT_TYPE_UNSUPPORTED", http.StatusUnsupportedMediaType, "unsupported photo content type %s", contentType)
+	}
+	ErrCantUploadInspection = func(err string) error {
+		return newError("INSPECTION_CREATE_FAILED", http.StatusInternalServerError, "can't create inspection: %v", err)
+	}
+	ErrInspectionScan = func(err string) error {
+		return newError("INSPECTION_READ_FAILED", http.StatusInternalServerError, "error scanning inspection: %v", err)
+	}
+	ErrCantUpdateLanguage = func(err string) error {
+		return newError("USER_LANGUAGE_UPDATE_FAILED", http.StatusInternalServerError, "can't update user language: %v", err)
+	}
+	ErrCantUploadSession = func(err string) error {
+		return newError("SESSION_SAVE_FAILED", http.StatusInternalServerError, "can't upload session: %v", err)
+	}
+	ErrReadingSession = func(err string) error {
+		return newError("SESSION_READ_FAILED", http.StatusInternalServerError, "error reading session: %v", err)
+	}
+	ErrCantUploadTwoFactor = func(err string) error {
+		return newError("TWO_FACTOR_SAVE_FAILED", http.StatusInternalServerError, "can't upload two-factor settings: %v", err)
+	}
+	ErrReadingTwoFactor = func(err string) error {
+		return newError("TWO_FACTOR_READ_FAILED", http.StatusInternalServerError, "error reading two-factor settings: %v", err)
+	}
+	ErrLoginLocked = func(retryAfterSeconds int64) error {
+		return WithDetails(newError("LOGIN_LOCKED", http.StatusTooManyRequests, "too many failed login attempts, try again in %d seconds", retryAfterSeconds), map[string]int64{"retryAfterSeconds": retryAfterSeconds})
+	}
+	ErrInvalidZoneArea = func(err string) error {
+		return newError("INVALID_ZONE_AREA", http.StatusBadRequest, "invalid zone area: %v", err)
+	}
+	ErrCantUploadZone = func(err string) error {
+		return newError("ZONE_SAVE_FAILED", http.StatusInternalServerError, "can't upload zone: %v", err)
+	}
+	ErrReadingZone = func(err string) error {
+		return newError("ZONE_READ_FAILED", http.StatusInternalServerError, "error reading zone: %v", err)
+	}
+	ErrZoneAlreadyExist = func(zone string) error {
+		return newError("ZONE_ALREADY_EXISTS", http.StatusConflict, "zone %s already exists", zone)
+	}
+	ErrReadingActivityLog = func(err string) error {
+		return newError("ACTIVITY_LOG_READ_FAILED", http.StatusInternalServerError, "error reading activity log: %v", err)
+	}
+	ErrCantUploadLoginAttempts = func(err string) error {
+		return newError("LOGIN_ATTEMPTS_SAVE_FAILED", http.StatusInternalServerError, "can't upload login attempts: %v", err)
+	}
+	ErrReadingLoginAttempts = func(err string) error {
+		return newError("LOGIN_ATTEMPTS_READ_FAILED", http.StatusInternalServerError, "error reading login attempts: %v", err)
+	}
+	ErrCantUploadUserToken = func(err string) error {
+		return newError("USER_TOKEN_SAVE_FAILED", http.StatusInternalServerError, "can't upload user token: %v", err)
+	}
+	ErrCantSendMail = func(err string) error {
+		return newError("MAIL_SEND_FAILED", http.StatusInternalServerError, "can't send email: %v", err)
+	}
+	ErrInternal = func(err string) error {
+		return newError("INTERNAL_ERROR", http.StatusInternalServerError, "internal error: %v", err)
 	}
 	ErrCantUploadUser = func(err string) error {
-		return fmt.Errorf("can't upload user info: %v", err)
+		return newError("USER_SAVE_FAILED", http.StatusInternalServerError, "can't upload user info: %v", err)
 	}
 	ErrPermissionDenied = func(permission string) error {
-		return fmt.Errorf("user do not have %v permissions", permission)
+		return newError("PERMISSION_DENIED", http.StatusForbidden, "user do not have %v permissions", permission)
+	}
+	ErrInvalidFields = func(fields []FieldError) error {
+		names := make([]string, len(fields))
+		for i, field := range fields {
+			names[i] = field.Field
+		}
+		return WithDetails(newError("VALIDATION_FAILED", http.StatusBadRequest, "invalid fields: %v", strings.Join(names, ", ")), fields)
 	}
 	ErrInvalidaPayload = func(err string) error {
-		return fmt.Errorf("invalid payload: %v", err)
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: %v", err)
+	}
+	// Invalid payloads whose detail takes arguments keep them apart, so the catalog can translate the template
+	ErrInvalidNumberParam = func(name string) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: %s must be a number", name)
+	}
+	ErrInvalidIntegerParam = func(name string) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: %s must be an integer", name)
+	}
+	ErrInvalidDateParam = func(name string) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: %s must be a date (YYYY-MM-DD) or RFC 3339 timestamp", name)
+	}
+	ErrUnsupportedExportFormat = func(format string) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: unsupported export format %s", format)
+	}
+	ErrInvalidCSVHeader = func(err string) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: can't read csv header: %v", err)
+	}
+	ErrInvalidCSVRow = func(err string) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: can't read csv row: %v", err)
+	}
+	ErrMissingCSVColumn = func(column string) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: missing csv column %s", column)
+	}
+	ErrInvalidGPX = func(err string) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: can't read gpx: %v", err)
+	}
+	ErrGPXPointWithoutTime = func(point int) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: gpx track point %d has no time", point)
+	}
+	ErrGPXPointOutOfRange = func(point int) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: gpx track point %d is out of range", point)
+	}
+	ErrUntranslatedCommonName = func(language string) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: description in %s has no common name", language)
+	}
+	ErrUntranslatedLabel = func(language string) error {
+		return newError("INVALID_PAYLOAD", http.StatusBadRequest, "invalid payload: description in %s has no label", language)
 	}
 	ErrUserAlreadyExist = func(email string) error {
-		return fmt.Errorf("user with email %s already exists", email)
+		return newError("USER_ALREADY_EXISTS", http.StatusConflict, "user with email %s already exists", email)
 	}
 	ErrHashingPassword = func(hashError error) error {
-		return fmt.Errorf("failed to hash password: %v", hashError)
+		return newError("PASSWORD_HASH_FAILED", http.StatusInternalServerError, "failed to hash password: %v", hashError)
 	}
 	ErrSignMethod = func(alg string) error {
-		return fmt.Errorf("unexpected signing method: %v", alg)
+		return newError("INVALID_TOKEN_SIGNING_METHOD", http.StatusUnauthorized, "unexpected signing method: %v", alg)
 	}
 	ErrUserScan = func(err string) error {
-		return fmt.Errorf("error scanning user: %v", err)
+		return newError("USER_READ_FAILED", http.StatusInternalServerError, "error scanning user: %v", err)
 	}
 	ErrPermissionScan = func(err string) error {
-		return fmt.Errorf("error scanning permission: %v", err)
+		return newError("PERMISSION_READ_FAILED", http.StatusInternalServerError, "error scanning permission: %v", err)
 	}
 	ErrTreeScan = func(err string) error {
-		return fmt.Errorf("error scanning tree: %v", err)
+		return newError("TREE_READ_FAILED", http.StatusInternalServerError, "error scanning tree: %v", err)
 	}
 	ErrTreeSpeciesScan = func(err string) error {
-		return fmt.Errorf("error scanning tree species: %v", err)
+		return newError("TREE_SPECIES_READ_FAILED", http.StatusInternalServerError, "error scanning tree species: %v", err)
+	}
+	ErrCantUploadSpecies = func(err string) error {
+		return newError("TREE_SPECIES_SAVE_FAILED", http.StatusInternalServerError, "can't upload tree species: %v", err)
+	}
+	ErrTreeSpeciesAlreadyExist = func(speciesId string) error {
+		return newError("TREE_SPECIES_ALREADY_EXISTS", http.StatusConflict, "tree species %s already exists", speciesId)
+	}
+	ErrCantUploadTreeState = func(err string) error {
+		return newError("TREE_STATE_SAVE_FAILED", http.StatusInternalServerError, "can't upload tree state: %v", err)
+	}
+	ErrCantDeleteTreeState = func(err string) error {
+		return newError("TREE_STATE_DELETE_FAILED", http.StatusInternalServerError, "can't delete tree state: %v", err)
+	}
+	ErrTreeStateAlreadyExist = func(stateId string) error {
+		return newError("TREE_STATE_ALREADY_EXISTS", http.StatusConflict, "tree state %s already exists", stateId)
+	}
+	ErrTreeStateScan = func(err string) error {
+		return newError("TREE_STATE_READ_FAILED", http.StatusInternalServerError, "error scanning tree state: %v", err)
+	}
+	ErrReadingTreeState = func(err string) error {
+		return newError("TREE_STATE_READ_FAILED", http.StatusInternalServerError, "error reading tree state: %v", err)
 	}
 	ErrReadingSpecies = func(err string) error {
-		return fmt.Errorf("error reading tree species: %v", err)
+		return newError("TREE_SPECIES_READ_FAILED", http.StatusInternalServerError, "error reading tree species: %v", err)
 	}
 	ErrReadingRole = func(err string) error {
-		return fmt.Errorf("error reading role: %v", err)
+		return newError("ROLE_READ_FAILED", http.StatusInternalServerError, "error reading role: %v", err)
 	}
 	ErrRoleScan = func(err string) error {
-		return fmt.Errorf("error scaning role: %v", err)
+		return newError("ROLE_READ_FAILED", http.StatusInternalServerError, "error scaning role: %v", err)
 	}
 	ErrUserNotHavePermissions = func(permissions []string) error {
-		return fmt.Errorf("error user does not have permissions:  %v", permissions)
+		return WithDetails(newError("PERMISSION_DENIED", http.StatusForbidden, "error user does not have permissions:  %v", permissions), map[string][]string{"permissions": permissions})
+	}
+	ErrRoleEscalation = func(permissions []string) error {
+		return WithDetails(newError("ROLE_ESCALATION", http.StatusForbidden, "can't manage a role with permissions you don't have: %v", permissions), map[string][]string{"permissions": permissions})
+	}
+	ErrRoleZoneEscalation = func(permissions []string) error {
+		return WithDetails(newError("ROLE_ZONE_ESCALATION", http.StatusForbidden, "can't manage a role beyond the zones you hold its permissions in: %v", permissions), map[string][]string{"permissions": permissions})
+	}
+	ErrPermissionEscalation = func(permission string) error {
+		return newError("PERMISSION_ESCALATION", http.StatusForbidden, "can't grant a permission you don't have: %v", permission)
+	}
+	ErrPermissionZoneEscalation = func(permission string) error {
+		return newError("PERMISSION_ZONE_ESCALATION", http.StatusForbidden, "can't grant a permission you only hold in some zones: %v", permission)
 	}
 	ErrRoleAlreadyExist = func(role string) error {
-		return fmt.Errorf("role with name %s already exists", role)
+		return newError("ROLE_ALREADY_EXISTS", http.StatusConflict, "role with name %s already exists", role)
 	}
 )
@@ -0,0 +1,139 @@
+package i18n
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+)
+
+// Language used when the request gives no usable preference. Messages are written in it, so it needs no catalog.
+const DefaultLanguage = "en"
+
+// Translations per language, keyed by the English message or error template. Must match conf.language.
+var catalogs = map[string]map[string]string{
+	"en": {},
+	"es": esMessages,
+	"zh": zhMessages,
+}
+
+type languageKey struct{}
+
+func IsSupported(language string) bool {
+	_, ok := catalogs[language]
+	return ok
+}
+
+// T translates message, falling back to the English text when there is no translation
+func T(language string, message string) string {
+	if translated, ok := catalogs[language][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// TranslateError renders err in language. String arguments are translated too, so literal details like "missing email" are localized.
+func TranslateError(language string, err error) string {
+
+	var e *errors.Error
+	if !stderrors.As(err, &e) {
+		return T(language, err.Error())
+	}
+
+	args := make([]interface{}, len(e.Args()))
+	for i, arg := range e.Args() {
+		if text, ok := arg.(string); ok {
+			arg = T(language, text)
+		}
+		args[i] = arg
+	}
+
+	return fmt.Sprintf(T(language, e.Template()), args...)
+}
+
+// TranslateFieldErrors renders the message of every field error in language
+func TranslateFieldErrors(language string, fields []errors.FieldError) []errors.FieldError {
+
+	translated := make([]errors.FieldError, len(fields))
+	for i, field := range fields {
+		translated[i] = errors.NewFieldError(field.Field, field.Rule, field.Param, T(language, field.Template()))
+	}
+
+	return translated
+}
+
+// ParseAcceptLanguage returns the supported language with the highest weight in an Accept-Language header
+func ParseAcceptLanguage(header string) string {
+
+	type weighted struct {
+		language string
+		weight   float64
+	}
+
+	var candidates []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+
+		if weight > 0 && IsSupported(base) {
+			candidates = append(candidates, weighted{language: base, weight: weight})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return DefaultLanguage
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].weight > candidates[j].weight
+	})
+
+	return candidates[0].language
+}
+
+func WithLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, languageKey{}, language)
+}
+
+func LanguageFromContext(ctx context.Context) string {
+	if language, ok := ctx.Value(languageKey{}).(string); ok {
+		return language
+	}
+	return DefaultLanguage
+}
+
+// languageWriter carries the response language to the utils writers, which only see the http.ResponseWriter
+type languageWriter struct {
+	http.ResponseWriter
+	language string
+}
+
+// WithWriterLanguage sets the language of w, wrapping it the first time
+func WithWriterLanguage(w http.ResponseWriter, language string) http.ResponseWriter {
+	if lw, ok := w.(*languageWriter); ok {
+		lw.language = language
+		return lw
+	}
+	return &languageWriter{ResponseWriter: w, language: language}
+}
+
+func LanguageFromWriter(w http.ResponseWriter) string {
+	if lw, ok := w.(*languageWriter); ok {
+		return lw.language
+	}
+	return DefaultLanguage
+}
@@ -0,0 +1,206 @@
+package i18n
+
+var esMessages = map[string]string{
+	// Errors
+	"invalid email or password":                                "email o contraseña inválidos",
+	"unable to create JWT token":                               "no se pudo crear el token JWT",
+	"error authenticating user: Token not valid":               "error al autenticar al usuario: token inválido",
+	"error authenticating user: JWT token expired":             "error al autenticar al usuario: el token JWT expiró",
+	"unable to upload photo":                                   "no se pudo subir la foto",
+	"role assigment already exist":                             "la asignación de rol ya existe",
+	"user not found":                                           "usuario no encontrado",
+	"tree not found":                                           "árbol no encontrado",
+	"tree species not found":                                   "especie de árbol no encontrada",
+	"tree state not found":                                     "estado de árbol no encontrado",
+	"tree species is retired and can't be used in new surveys": "la especie está retirada y no puede usarse en nuevos relevamientos",
+	"tree state is used by existing trees or inspections":      "el estado está en uso por árboles o inspecciones existentes",
+	"role not found":                                           "rol no encontrado",
+	"permission not found":                                     "permiso no encontrado",
+	"role assigment doesn't exist":                             "la asignación de rol no existe",
+	"route not found":                                          "recorrido no encontrado",
+	"route is already closed":                                  "el recorrido ya está cerrado",
+	"user already has an open route":                           "el usuario ya tiene un recorrido abierto",
+	"route belongs to another user":                            "el recorrido pertenece a otro usuario",
+	"photo not found":                                          "foto no encontrada",
+	"photo is not a valid image":                               "la foto no es una imagen válida",
+	"inspection date can't be in the future":                   "la fecha de inspección no puede estar en el futuro",
+	"language not found":                                       "idioma no encontrado",
+	"can't delete role assigment: %v":                          "no se pudo eliminar la asignación de rol: %v",
+	"can't log activity: %v":                                   "no se pudo registrar la actividad: %v",
+	"can't read user permissions: %v":                          "no se pudieron leer los permisos del usuario: %v",
+	"can't upload role info: %v":                               "no se pudo guardar el rol: %v",
+	"can't create tree: %v":                                    "no se pudo crear el árbol: %v",
+	"can't update tree: %v":                                    "no se pudo actualizar el árbol: %v",
+	"can't delete tree: %v":                                    "no se pudo eliminar el árbol: %v",
+	"can't upload route: %v":                                   "no se pudo guardar el recorrido: %v",
+	"error scanning route: %v":                                 "error al leer el recorrido: %v",
+	"can't store photo: %v":                                    "no se pudo guardar la foto: %v",
+	"error scanning photo: %v":                                 "error al leer la foto: %v",
+	"photo exceeds the maximum size of %d bytes":               "la foto supera el tamaño máximo de %d bytes",
+	"photo exceeds the maximum of %d pixels":                   "la foto supera el máximo de %d píxeles",
+	"unsupported photo content type %s":                        "tipo de contenido de foto no soportado %s",
+	"can't create inspection: %v":                              "no se pudo crear la inspección: %v",
+	"error scanning inspection: %v":                            "error al leer la inspección: %v",
+	"can't upload user info: %v":                               "no se pudo guardar el usuario: %v",
+	"user do not have %v permissions":                          "el usuario no tiene permisos %v",
+	"invalid payload: %v":                                      "datos inválidos: %v",
+	"invalid payload: %s must be a number":                     "datos inválidos: %s debe ser un número",
+	"invalid payload: %s must be an integer":                   "datos inválidos: %s debe ser un número entero",
+	"invalid payload: %s must be a date (YYYY-MM-DD) or RFC 3339 timestamp":       "datos inválidos: %s debe ser una fecha (AAAA-MM-DD) o un timestamp RFC 3339",
+	"invalid payload: unsupported export format %s":                               "datos inválidos: formato de exportación no soportado %s",
+	"invalid payload: can't read csv header: %v":                                  "datos inválidos: no se pudo leer el encabezado del csv: %v",
+	"invalid payload: can't read csv row: %v":                                     "datos inválidos: no se puede leer la fila del csv: %v",
+	"invalid payload: missing csv column %s":                                      "datos inválidos: falta la columna %s en el csv",
+	"invalid payload: can't read gpx: %v":                                         "datos inválidos: no se pudo leer el gpx: %v",
+	"invalid payload: gpx track point %d has no time":                             "datos inválidos: el punto %d del track gpx no tiene hora",
+	"invalid payload: gpx track point %d is out of range":                         "datos inválidos: el punto %d del track gpx está fuera de rango",
+	"invalid payload: description in %s has no common name":                       "datos inválidos: la descripción en %s no tiene nombre común",
+	"invalid payload: description in %s has no label":                             "datos inválidos: la descripción en %s no tiene etiqueta",
+	"user with email %s already exists":                                           "ya existe un usuario con el email %s",
+	"failed to hash password: %v":                                                 "no se pudo cifrar la contraseña: %v",
+	"unexpected signing method: %v":                                               "método de firma inesperado: %v",
+	"error scanning user: %v":                                                     "error al leer el usuario: %v",
+	"error scanning permission: %v":                                               "error al leer el permiso: %v",
+	"error scanning tree: %v":                                                     "error al leer el árbol: %v",
+	"error scanning tree species: %v":                                             "error al leer la especie: %v",
+	"can't upload tree species: %v":                                               "no se pudo guardar la especie: %v",
+	"tree species %s already exists":                                              "la especie %s ya existe",
+	"can't upload tree state: %v":                                                 "no se pudo guardar el estado: %v",
+	"can't delete tree state: %v":                                                 "no se pudo eliminar el estado: %v",
+	"tree state %s already exists":                                                "el estado %s ya existe",
+	"error scanning tree state: %v":                                               "error al leer el estado: %v",
+	"error reading tree state: %v":                                                "error al consultar los estados: %v",
+	"error reading tree species: %v":                                              "error al consultar las especies: %v",
+	"error reading role: %v":                                                      "error al consultar el rol: %v",
+	"error scaning role: %v":                                                      "error al leer el rol: %v",
+	"error user does not have permissions:  %v":                                   "error: el usuario no tiene los permisos %v",
+	"role with name %s already exists":                                            "ya existe un rol con el nombre %s",
+	"can't update user language: %v":                                              "no se pudo actualizar el idioma del usuario: %v",
+	"internal error: %v":                                                          "error interno: %v",
+	"token is invalid, expired or already used":                                   "el token es inválido, expiró o ya fue usado",
+	"email address is not verified":                                               "la dirección de email no está verificada",
+	"email address is already verified":                                           "la dirección de email ya está verificada",
+	"role assigment valid until date must be in the future":                       "la fecha de vigencia de la asignación de rol debe ser futura",
+	"role is still assigned to users; revoke or reassign those assignments first": "el rol sigue asignado a usuarios; revocá o reasigná esas asignaciones primero",
+	"role already has this permission":                                            "el rol ya tiene este permiso",
+	"role doesn't have this permission":                                           "el rol no tiene este permiso",
+	"can't remove the ADMIN role from the last user holding it":                   "no se puede quitar el rol ADMIN al último usuario que lo tiene",
+	"the ADMIN role can't be renamed":                                             "el rol ADMIN no se puede renombrar",
+	"the ADMIN role can't be deleted":                                             "el rol ADMIN no se puede eliminar",
+	"permissions can't be revoked from the ADMIN role":                            "no se pueden revocar permisos del rol ADMIN",
+	"zone not found":                                                              "zona no encontrada",
+	"zone is used by role assignments":                                            "la zona está en uso por asignaciones de rol",
+	"location is outside the zones you can work in":                               "la ubicación está fuera de las zonas en las que podés trabajar",
+	"zones can't be managed with a zone-limited CONFIG permission":                "las zonas no se pueden administrar con un permiso CONFIG limitado a zonas",
+	"can't manage a role with permissions you don't have: %v":                     "no podés gestionar un rol con permisos que no tenés: %v",
+	"can't manage a role beyond the zones you hold its permissions in: %v":        "no podés gestionar un rol fuera de las zonas en las que tenés sus permisos: %v",
+	"can't grant a permission you don't have: %v":                                 "no podés otorgar un permiso que no tenés: %v",
+	"can't grant a permission you only hold in some zones: %v":                    "no podés otorgar un permiso que solo tenés en algunas zonas: %v",
+	"can't upload user token: %v":                                                 "no se pudo guardar el token del usuario: %v",
+	"can't send email: %v":                                                        "no se pudo enviar el email: %v",
+	"session has been logged out":                                                 "la sesión fue cerrada",
+	"refresh token was already used; the session has been revoked":                "el refresh token ya fue usado; la sesión fue revocada",
+	"can't upload session: %v":                                                    "no se pudo guardar la sesión: %v",
+	"error reading session: %v":                                                   "error al consultar la sesión: %v",
+	"this action requires signing in with two-factor authentication":              "esta acción requiere iniciar sesión con autenticación de dos factores",
+	"two-factor authentication is already enabled":                                "la autenticación de dos factores ya está activada",
+	"two-factor authentication is not enabled":                                    "la autenticación de dos factores no está activada",
+	"invalid two-factor code":                                                     "código de dos factores inválido",
+	"can't upload two-factor settings: %v":                                        "no se pudo guardar la configuración de dos factores: %v",
+	"error reading two-factor settings: %v":                                       "error al consultar la configuración de dos factores: %v",
+	"too many failed login attempts, try again in %d seconds":                     "demasiados intentos de inicio de sesión fallidos, probá de nuevo en %d segundos",
+	"can't upload login attempts: %v":                                             "no se pudieron guardar los intentos de inicio de sesión: %v",
+	"error reading login attempts: %v":                                            "error al consultar los intentos de inicio de sesión: %v",
+	"invalid zone area: %v":                                                       "área de zona inválida: %v",
+	"can't upload zone: %v":                                                       "no se pudo guardar la zona: %v",
+	"error reading zone: %v":                                                      "error al consultar la zona: %v",
+	"zone %s already exists":                                                      "la zona %s ya existe",
+	"error reading activity log: %v":                                              "error al consultar el registro de actividad: %v",
+
+	// Error details
+	"missing request body":                                     "falta el cuerpo de la solicitud",
+	"missing email":                                            "falta el email",
+	"missing csv file":                                         "falta el archivo csv",
+	"missing gpx file":                                         "falta el archivo gpx",
+	"missing photo file":                                       "falta el archivo de la foto",
+	"gpx tracks must have at least two points":                 "los tracks gpx deben tener al menos dos puntos",
+	"invalid cursor":                                           "cursor inválido",
+	"createdBy must be a user id":                              "createdBy debe ser un id de usuario",
+	"userId must be a user id":                                 "userId debe ser un id de usuario",
+	"order must be asc or desc":                                "order debe ser asc o desc",
+	"limit must be an integer":                                 "limit debe ser un número entero",
+	"format must be csv or ndjson":                             "format debe ser csv o ndjson",
+	"lat must be a number":                                     "lat debe ser un número",
+	"lon must be a number":                                     "lon debe ser un número",
+	"radius must be a number":                                  "radius debe ser un número",
+	"bbox must be minLon,minLat,maxLon,maxLat":                 "bbox debe ser minLon,minLat,maxLon,maxLat",
+	"days must be a positive integer":                          "days debe ser un número entero positivo",
+	"area must be a GeoJSON Polygon or MultiPolygon":           "el área debe ser un Polygon o MultiPolygon GeoJSON",
+	"area polygons must be closed and must not self-intersect": "los polígonos del área deben estar cerrados y no pueden cortarse a sí mismos",
+
+	// Field validation messages
+	"invalid fields: %v":                                        "campos inválidos: %v",
+	"is not valid":                                              "no es válido",
+	"is required":                                               "es obligatorio",
+	"is required when %s is present":                            "es obligatorio cuando se envía %s",
+	"must be at least %s":                                       "debe ser como mínimo %s",
+	"must be at most %s":                                        "debe ser como máximo %s",
+	"must be greater than %s":                                   "debe ser mayor que %s",
+	"must be less than %s":                                      "debe ser menor que %s",
+	"must have length %s":                                       "debe tener longitud %s",
+	"must be one of: %s":                                        "debe ser uno de: %s",
+	"is required when %s is missing":                            "es obligatorio cuando falta %s",
+	"must be a valid IP address":                                "debe ser una dirección IP válida",
+	"must not contain duplicates":                               "no debe contener duplicados",
+	"must be a valid email":                                     "debe ser un email válido",
+	"must be a valid UUID":                                      "debe ser un UUID válido",
+	"must be a valid URI":                                       "debe ser una URI válida",
+	"must be valid base64":                                      "debe ser base64 válido",
+	"must be a hex color like #2E7D32":                          "debe ser un color hexadecimal como #2E7D32",
+	"must be a latitude between -90 and 90":                     "debe ser una latitud entre -90 y 90",
+	"must be a longitude between -180 and 180":                  "debe ser una longitud entre -180 y 180",
+	"must be a tree height greater than 0 and up to 130 meters": "debe ser una altura mayor que 0 y de hasta 130 metros",
+	"must be a trunk diameter greater than 0 and up to 1200 centimeters": "debe ser un diámetro de tronco mayor que 0 y de hasta 1200 centímetros",
+
+	// Success messages
+	"User registered successfully":                                    "Usuario registrado correctamente",
+	"Photo uploaded successfully":                                     "Foto subida correctamente",
+	"Language updated successfully":                                   "Idioma actualizado correctamente",
+	"Tree created successfully":                                       "Árbol creado correctamente",
+	"Tree updated successfully":                                       "Árbol actualizado correctamente",
+	"Tree deleted successfully":                                       "Árbol eliminado correctamente",
+	"Route points added successfully":                                 "Puntos de la ruta agregados correctamente",
+	"Route closed successfully":                                       "Ruta cerrada correctamente",
+	"Tree species created successfully":                               "Especie creada correctamente",
+	"Tree species updated successfully":                               "Especie actualizada correctamente",
+	"Tree species retired successfully":                               "Especie retirada correctamente",
+	"Tree state created successfully":                                 "Estado creado correctamente",
+	"Tree state updated successfully":                                 "Estado actualizado correctamente",
+	"Tree state deleted successfully":                                 "Estado eliminado correctamente",
+	"Inspection created successfully":                                 "Inspección creada correctamente",
+	"Role created successfully":                                       "Rol creado correctamente",
+	"Role assigned successfully":                                      "Rol asignado correctamente",
+	"Role assigned deleted":                                           "Asignación de rol eliminada",
+	"If the email is registered, a password reset link has been sent": "Si el email está registrado, se envió un enlace para restablecer la contraseña",
+	"Password reset successfully":                                     "Contraseña restablecida correctamente",
+	"Verification email sent":                                         "Email de verificación enviado",
+	"Email verified successfully":                                     "Email verificado correctamente",
+	"Logged out successfully":                                         "Sesión cerrada correctamente",
+	"Logged out from all devices":                                     "Sesión cerrada en todos los dispositivos",
+	"Two-factor authentication disabled":                              "Autenticación de dos factores desactivada",
+	"Login unlocked successfully":                                     "Inicio de sesión desbloqueado correctamente",
+	"Role assigment renewed successfully":                             "Asignación de rol renovada correctamente",
+	"Role updated successfully":                                       "Rol actualizado correctamente",
+	"Role deleted successfully":                                       "Rol eliminado correctamente",
+	"Permission granted successfully":                                 "Permiso otorgado correctamente",
+	"Permission revoked successfully":                                 "Permiso revocado correctamente",
+	"Zone created successfully":                                       "Zona creada correctamente",
+	"Zone updated successfully":                                       "Zona actualizada correctamente",
+	"Zone deleted successfully":                                       "Zona eliminada correctamente",
+
+	// Emails
+	"Reset your TreeSense password": "Restablecé tu contraseña de TreeSense",
+	"Verify your TreeSense email":   "Verificá tu email de TreeSense",
+	"Hello %s,\n\nWe received a request to reset your TreeSense password. Open the link below to choose a new one:\n\n%s\n\nThe link expires in %d minutes. If you didn't ask for it, you can ignore this email.\n": "Hola %s,\n\nRecibimos un pedido para restablecer tu contraseña de TreeSense. Abrí el siguiente enlace para elegir una nueva:\n\n%s\n\nEl enlace vence en %d minutos. Si no lo pediste, podés ignorar este email.\n",
+	"Hello %s,\n\nPlease confirm your TreeSense email address by opening the link below:\n\n%s\n\nThe link expires in %d hours.\n":                                                                                  "Hola %s,\n\nConfirmá tu dirección de email de TreeSense abriendo el siguiente enlace:\n\n%s\n\nEl enlace vence en %d horas.\n",
+}
@@ -0,0 +1,206 @@
+package i18n
+
+var zhMessages = map[string]string{
+	// Errors
+	"invalid email or password":                                "邮箱或密码无效",
+	"unable to create JWT token":                               "无法创建 JWT 令牌",
+	"error authenticating user: Token not valid":               "用户认证失败：令牌无效",
+	"error authenticating user: JWT token expired":             "用户认证失败：JWT 令牌已过期",
+	"unable to upload photo":                                   "无法上传照片",
+	"role assigment already exist":                             "角色分配已存在",
+	"user not found":                                           "未找到用户",
+	"tree not found":                                           "未找到树木",
+	"tree species not found":                                   "未找到树种",
+	"tree species is retired and can't be used in new surveys": "该树种已停用，不能用于新的调查",
+	"tree state not found":                                     "未找到树木状态",
+	"tree state is used by existing trees or inspections":      "该状态已被现有树木或检查记录使用",
+	"role not found":                                           "未找到角色",
+	"permission not found":                                     "未找到权限",
+	"role assigment doesn't exist":                             "角色分配不存在",
+	"route not found":                                          "未找到路线",
+	"route is already closed":                                  "路线已关闭",
+	"user already has an open route":                           "用户已有未关闭的路线",
+	"route belongs to another user":                            "路线属于其他用户",
+	"photo not found":                                          "未找到照片",
+	"photo is not a valid image":                               "照片不是有效的图像",
+	"inspection date can't be in the future":                   "检查日期不能晚于当前时间",
+	"language not found":                                       "未找到语言",
+	"can't delete role assigment: %v":                          "无法删除角色分配：%v",
+	"can't log activity: %v":                                   "无法记录活动：%v",
+	"can't read user permissions: %v":                          "无法读取用户权限：%v",
+	"can't upload role info: %v":                               "无法保存角色信息：%v",
+	"can't create tree: %v":                                    "无法创建树木：%v",
+	"can't update tree: %v":                                    "无法更新树木：%v",
+	"can't delete tree: %v":                                    "无法删除树木：%v",
+	"can't upload route: %v":                                   "无法保存路线：%v",
+	"error scanning route: %v":                                 "读取路线出错：%v",
+	"can't store photo: %v":                                    "无法保存照片：%v",
+	"error scanning photo: %v":                                 "读取照片出错：%v",
+	"photo exceeds the maximum size of %d bytes":               "照片超过最大限制 %d 字节",
+	"photo exceeds the maximum of %d pixels":                   "照片超过最大像素数 %d",
+	"unsupported photo content type %s":                        "不支持的照片类型 %s",
+	"can't create inspection: %v":                              "无法创建检查记录：%v",
+	"error scanning inspection: %v":                            "读取检查记录出错：%v",
+	"can't upload user info: %v":                               "无法保存用户信息：%v",
+	"user do not have %v permissions":                          "用户没有 %v 权限",
+	"invalid payload: %v":                                      "请求数据无效：%v",
+	"invalid payload: %s must be a number":                     "无效数据：%s 必须为数字",
+	"invalid payload: %s must be an integer":                   "无效数据：%s 必须为整数",
+	"invalid payload: %s must be a date (YYYY-MM-DD) or RFC 3339 timestamp":       "无效数据：%s 必须为日期（YYYY-MM-DD）或 RFC 3339 时间戳",
+	"invalid payload: unsupported export format %s":                               "无效数据：不支持的导出格式 %s",
+	"invalid payload: can't read csv header: %v":                                  "无效数据：无法读取 csv 表头：%v",
+	"invalid payload: can't read csv row: %v":                                     "无效数据：无法读取 csv 行：%v",
+	"invalid payload: missing csv column %s":                                      "无效数据：csv 缺少列 %s",
+	"invalid payload: can't read gpx: %v":                                         "无效数据：无法读取 gpx：%v",
+	"invalid payload: gpx track point %d has no time":                             "无效数据：gpx 轨迹点 %d 没有时间",
+	"invalid payload: gpx track point %d is out of range":                         "无效数据：gpx 轨迹点 %d 超出范围",
+	"invalid payload: description in %s has no common name":                       "无效数据：%s 的描述没有通用名称",
+	"invalid payload: description in %s has no label":                             "无效数据：%s 的描述没有标签",
+	"user with email %s already exists":                                           "邮箱为 %s 的用户已存在",
+	"failed to hash password: %v":                                                 "密码加密失败：%v",
+	"unexpected signing method: %v":                                               "意外的签名方法：%v",
+	"error scanning user: %v":                                                     "读取用户出错：%v",
+	"error scanning permission: %v":                                               "读取权限出错：%v",
+	"error scanning tree: %v":                                                     "读取树木出错：%v",
+	"error scanning tree species: %v":                                             "读取树种出错：%v",
+	"can't upload tree species: %v":                                               "无法保存树种：%v",
+	"tree species %s already exists":                                              "树种 %s 已存在",
+	"can't upload tree state: %v":                                                 "无法保存树木状态：%v",
+	"can't delete tree state: %v":                                                 "无法删除树木状态：%v",
+	"tree state %s already exists":                                                "树木状态 %s 已存在",
+	"error scanning tree state: %v":                                               "读取树木状态出错：%v",
+	"error reading tree state: %v":                                                "查询树木状态出错：%v",
+	"error reading tree species: %v":                                              "查询树种出错：%v",
+	"error reading role: %v":                                                      "查询角色出错：%v",
+	"error scaning role: %v":                                                      "读取角色出错：%v",
+	"error user does not have permissions:  %v":                                   "错误：用户没有权限 %v",
+	"role with name %s already exists":                                            "名为 %s 的角色已存在",
+	"can't update user language: %v":                                              "无法更新用户语言：%v",
+	"internal error: %v":                                                          "内部错误：%v",
+	"token is invalid, expired or already used":                                   "令牌无效、已过期或已被使用",
+	"email address is not verified":                                               "邮箱地址尚未验证",
+	"email address is already verified":                                           "邮箱地址已验证",
+	"role assigment valid until date must be in the future":                       "角色分配的有效期必须是将来的日期",
+	"role is still assigned to users; revoke or reassign those assignments first": "该角色仍分配给用户；请先撤销或重新分配这些分配",
+	"role already has this permission":                                            "该角色已拥有此权限",
+	"role doesn't have this permission":                                           "该角色没有此权限",
+	"can't remove the ADMIN role from the last user holding it":                   "不能移除最后一个拥有 ADMIN 角色的用户的该角色",
+	"the ADMIN role can't be renamed":                                             "ADMIN 角色不能重命名",
+	"the ADMIN role can't be deleted":                                             "ADMIN 角色不能删除",
+	"permissions can't be revoked from the ADMIN role":                            "不能撤销 ADMIN 角色的权限",
+	"zone not found":                                                              "未找到区域",
+	"zone is used by role assignments":                                            "该区域正被角色分配使用",
+	"location is outside the zones you can work in":                               "位置不在您可工作的区域内",
+	"zones can't be managed with a zone-limited CONFIG permission":                "受区域限制的 CONFIG 权限不能管理区域",
+	"can't manage a role with permissions you don't have: %v":                     "不能管理拥有您所没有权限的角色：%v",
+	"can't manage a role beyond the zones you hold its permissions in: %v":        "不能在你拥有其权限的区域之外管理该角色：%v",
+	"can't grant a permission you don't have: %v":                                 "不能授予你没有的权限：%v",
+	"can't grant a permission you only hold in some zones: %v":                    "不能授予你仅在部分区域拥有的权限：%v",
+	"can't upload user token: %v":                                                 "无法保存用户令牌：%v",
+	"can't send email: %v":                                                        "无法发送邮件：%v",
+	"session has been logged out":                                                 "会话已注销",
+	"refresh token was already used; the session has been revoked":                "刷新令牌已被使用，会话已被撤销",
+	"can't upload session: %v":                                                    "无法保存会话：%v",
+	"error reading session: %v":                                                   "查询会话出错：%v",
+	"this action requires signing in with two-factor authentication":              "此操作需要使用双重身份验证登录",
+	"two-factor authentication is already enabled":                                "双重身份验证已启用",
+	"two-factor authentication is not enabled":                                    "双重身份验证未启用",
+	"invalid two-factor code":                                                     "双重验证码无效",
+	"can't upload two-factor settings: %v":                                        "无法保存双重身份验证设置：%v",
+	"error reading two-factor settings: %v":                                       "查询双重身份验证设置出错：%v",
+	"too many failed login attempts, try again in %d seconds":                     "登录失败次数过多，请在 %d 秒后重试",
+	"can't upload login attempts: %v":                                             "无法保存登录尝试记录：%v",
+	"error reading login attempts: %v":                                            "查询登录尝试记录出错：%v",
+	"invalid zone area: %v":                                                       "区域范围无效：%v",
+	"can't upload zone: %v":                                                       "无法保存区域：%v",
+	"error reading zone: %v":                                                      "查询区域出错：%v",
+	"zone %s already exists":                                                      "区域 %s 已存在",
+	"error reading activity log: %v":                                              "查询活动日志出错：%v",
+
+	// Error details
+	"missing request body":                                     "缺少请求体",
+	"missing email":                                            "缺少邮箱",
+	"missing csv file":                                         "缺少 csv 文件",
+	"missing gpx file":                                         "缺少 gpx 文件",
+	"missing photo file":                                       "缺少照片文件",
+	"gpx tracks must have at least two points":                 "gpx 轨迹至少需要两个点",
+	"invalid cursor":                                           "游标无效",
+	"createdBy must be a user id":                              "createdBy 必须为用户 ID",
+	"userId must be a user id":                                 "userId 必须为用户 ID",
+	"order must be asc or desc":                                "order 必须为 asc 或 desc",
+	"limit must be an integer":                                 "limit 必须为整数",
+	"format must be csv or ndjson":                             "format 必须为 csv 或 ndjson",
+	"lat must be a number":                                     "lat 必须为数字",
+	"lon must be a number":                                     "lon 必须为数字",
+	"radius must be a number":                                  "radius 必须为数字",
+	"bbox must be minLon,minLat,maxLon,maxLat":                 "bbox 格式必须为 minLon,minLat,maxLon,maxLat",
+	"days must be a positive integer":                          "days 必须为正整数",
+	"area must be a GeoJSON Polygon or MultiPolygon":           "范围必须是 GeoJSON Polygon 或 MultiPolygon",
+	"area polygons must be closed and must not self-intersect": "范围多边形必须闭合且不能自相交",
+
+	// Field validation messages
+	"invalid fields: %v":                                        "字段无效：%v",
+	"is not valid":                                              "无效",
+	"is required":                                               "为必填项",
+	"is required when %s is present":                            "在提供 %s 时为必填项",
+	"must be at least %s":                                       "不能小于 %s",
+	"must be at most %s":                                        "不能大于 %s",
+	"must be greater than %s":                                   "必须大于 %s",
+	"must be less than %s":                                      "必须小于 %s",
+	"must have length %s":                                       "长度必须为 %s",
+	"must be one of: %s":                                        "必须是以下之一：%s",
+	"is required when %s is missing":                            "在缺少 %s 时为必填项",
+	"must be a valid IP address":                                "必须是有效的 IP 地址",
+	"must not contain duplicates":                               "不能包含重复项",
+	"must be a valid email":                                     "必须是有效的邮箱",
+	"must be a valid UUID":                                      "必须是有效的 UUID",
+	"must be a valid URI":                                       "必须是有效的 URI",
+	"must be valid base64":                                      "必须是有效的 base64",
+	"must be a hex color like #2E7D32":                          "必须是十六进制颜色，例如 #2E7D32",
+	"must be a latitude between -90 and 90":                     "必须是 -90 到 90 之间的纬度",
+	"must be a longitude between -180 and 180":                  "必须是 -180 到 180 之间的经度",
+	"must be a tree height greater than 0 and up to 130 meters": "树高必须大于 0 且不超过 130 米",
+	"must be a trunk diameter greater than 0 and up to 1200 centimeters": "树干直径必须大于 0 且不超过 1200 厘米",
+
+	// Success messages
+	"User registered successfully":                                    "用户注册成功",
+	"Photo uploaded successfully":                                     "照片上传成功",
+	"Language updated successfully":                                   "语言更新成功",
+	"Tree created successfully":                                       "树木创建成功",
+	"Tree updated successfully":                                       "树木更新成功",
+	"Tree deleted successfully":                                       "树木删除成功",
+	"Route points added successfully":                                 "路线点添加成功",
+	"Route closed successfully":                                       "路线已关闭",
+	"Tree species created successfully":                               "树种创建成功",
+	"Tree species updated successfully":                               "树种更新成功",
+	"Tree species retired successfully":                               "树种已停用",
+	"Tree state created successfully":                                 "树木状态创建成功",
+	"Tree state updated successfully":                                 "树木状态更新成功",
+	"Tree state deleted successfully":                                 "树木状态删除成功",
+	"Inspection created successfully":                                 "检查记录创建成功",
+	"Role created successfully":                                       "角色创建成功",
+	"Role assigned successfully":                                      "角色分配成功",
+	"Role assigned deleted":                                           "角色分配已删除",
+	"If the email is registered, a password reset link has been sent": "如果该邮箱已注册，重置密码的链接已发送",
+	"Password reset successfully":                                     "密码重置成功",
+	"Verification email sent":                                         "验证邮件已发送",
+	"Email verified successfully":                                     "邮箱验证成功",
+	"Logged out successfully":                                         "已成功注销",
+	"Logged out from all devices":                                     "已在所有设备上注销",
+	"Two-factor authentication disabled":                              "双重身份验证已停用",
+	"Login unlocked successfully":                                     "登录已解锁",
+	"Role assigment renewed successfully":                             "角色分配已续期",
+	"Role updated successfully":                                       "角色已更新",
+	"Role deleted successfully":                                       "角色已删除",
+	"Permission granted successfully":                                 "权限已授予",
+	"Permission revoked successfully":                                 "权限已撤销",
+	"Zone created successfully":                                       "区域已创建",
+	"Zone updated successfully":                                       "区域已更新",
+	"Zone deleted successfully":                                       "区域已删除",
+
+	// Emails
+	"Reset your TreeSense password": "重置您的 TreeSense 密码",
+	"Verify your TreeSense email":   "验证您的 TreeSense 邮箱",
+	"Hello %s,\n\nWe received a request to reset your TreeSense password. Open the link below to choose a new one:\n\n%s\n\nThe link expires in %d minutes. If you didn't ask for it, you can ignore this email.\n": "%s，您好：\n\n我们收到了重置您 TreeSense 密码的请求。请打开以下链接设置新密码：\n\n%s\n\n该链接将在 %d 分钟后失效。如果这不是您本人的操作，请忽略此邮件。\n",
+	"Hello %s,\n\nPlease confirm your TreeSense email address by opening the link below:\n\n%s\n\nThe link expires in %d hours.\n":                                                                                  "%s，您好：\n\n请打开以下链接确认您的 TreeSense 邮箱地址：\n\n%s\n\n该链接将在 %d 小时后失效。\n",
+}
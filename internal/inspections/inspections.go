@@ -0,0 +1,37 @@
+package inspections
+
+import (
+	"time"
+)
+
+type Inspection struct {
+	InspectionId []uint8   `json:"inspectionId"`
+	TreeId       []uint8   `json:"treeId"`
+	State        string    `json:"state"`
+	Height       float64   `json:"height"`
+	Diameter     float64   `json:"diameter"`
+	Notes        string    `json:"notes"`
+	TreePhotoId  []uint8   `json:"treePhotoId"`
+	InspectedBy  []uint8   `json:"inspectedBy"`
+	InspectedAt  time.Time `json:"inspectedAt"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type InspectionRepository interface {
+	CreateInspection(inspection Inspection) error
+	GetInspectionsByTreeId(treeId []uint8) ([]Inspection, error)
+}
+
+type InspectionService interface {
+	CreateInspection(treeId []uint8, payload createInspectionPayload, userId []uint8, zones []string) error
+	GetTreeTimeline(treeId []uint8) ([]Inspection, error)
+}
+
+type createInspectionPayload struct {
+	State       string     `json:"state" validate:"required"`
+	Height      float64    `json:"height" validate:"required,tree_height"`
+	Diameter    float64    `json:"diameter" validate:"required,tree_diameter"`
+	Notes       string     `json:"notes"`
+	PhotoId     string     `json:"photoId" validate:"omitempty,uuid"`
+	InspectedAt *time.Time `json:"inspectedAt"` // defaults to now
+}
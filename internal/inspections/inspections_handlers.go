@@ -0,0 +1,76 @@
+package inspections
+
+import (
+	"net/http"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/middlewares"
+	"github.com/PabloPei/TreeSense-Backend/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	service InspectionService
+}
+
+func NewHandler(service InspectionService) *Handler {
+	return &Handler{service: service}
+}
+
+// Registered on the tree subrouter, paths are relative to /tree
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middlewares.Middleware) {
+
+	router.HandleFunc("/{treeId}/inspections", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleCreateInspection)).Methods("POST")
+	router.HandleFunc("/{treeId}/inspections", middleware.RequireAuthAndAnyPermission([]string{"READ", "SURVEY"}, false)(h.handleGetTreeTimeline)).Methods("GET")
+}
+
+func (h *Handler) handleCreateInspection(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	treeId := mux.Vars(r)["treeId"]
+	if !utils.IsUUID(treeId) {
+		utils.WriteError(w, errors.ErrTreeNotFound)
+		return
+	}
+
+	var inspection createInspectionPayload
+	if err := utils.ParseJSON(r, &inspection); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(inspection); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err = h.service.CreateInspection([]uint8(treeId), inspection, userId, middlewares.GetZonesFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusCreated, "Inspection created successfully")
+}
+
+func (h *Handler) handleGetTreeTimeline(w http.ResponseWriter, r *http.Request) {
+
+	treeId := mux.Vars(r)["treeId"]
+	if !utils.IsUUID(treeId) {
+		utils.WriteError(w, errors.ErrTreeNotFound)
+		return
+	}
+
+	inspections, err := h.service.GetTreeTimeline([]uint8(treeId))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"treeId": treeId, "inspections": inspections})
+}
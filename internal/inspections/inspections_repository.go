@@ -0,0 +1,106 @@
+package inspections
+
+import (
+	"database/sql"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+)
+
+// Postgres SQL Repository
+type SQLRepository struct {
+	db *sql.DB
+}
+
+func NewSQLRepository(db *sql.DB) *SQLRepository {
+	return &SQLRepository{db: db}
+}
+
+type scannable interface {
+	Scan(dest ...interface{}) error
+}
+
+const inspectionColumns = "tree_inspection_id, tree_id, state, COALESCE(height, 0), COALESCE(diameter, 0), COALESCE(notes, ''), tree_photo_id, inspected_by, inspected_at, created_at"
+
+// CreateInspection stores the observation and, when it is the most recent one, copies it onto the tree
+func (s *SQLRepository) CreateInspection(inspection Inspection) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadInspection(err.Error())
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT INTO treesense.\"tree_inspection\" (tree_id, state, height, diameter, notes, tree_photo_id, inspected_by, inspected_at) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)",
+		inspection.TreeId, inspection.State, inspection.Height, inspection.Diameter, inspection.Notes, inspection.TreePhotoId, inspection.InspectedBy, inspection.InspectedAt.UTC(),
+	)
+	if err != nil {
+		return errors.ErrCantUploadInspection(err.Error())
+	}
+
+	_, err = tx.Exec(`
+	UPDATE treesense."tree" SET state = $2, height = $3, diameter = $4, updated_by = $5, updated_at = CURRENT_TIMESTAMP
+	WHERE tree_id = $1 AND NOT EXISTS (
+		SELECT 1 FROM treesense."tree_inspection" WHERE tree_id = $1 AND inspected_at > $6
+	)
+	`, inspection.TreeId, inspection.State, inspection.Height, inspection.Diameter, inspection.InspectedBy, inspection.InspectedAt.UTC())
+	if err != nil {
+		return errors.ErrCantUploadInspection(err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadInspection(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) GetInspectionsByTreeId(treeId []uint8) ([]Inspection, error) {
+
+	rows, err := s.db.Query("SELECT "+inspectionColumns+" FROM treesense.\"tree_inspection\" WHERE tree_id = $1 ORDER BY inspected_at, created_at", treeId)
+	if err != nil {
+		return nil, errors.ErrInspectionScan(err.Error())
+	}
+
+	defer rows.Close()
+
+	var inspections []Inspection
+
+	for rows.Next() {
+		inspection, err := scanRowIntoInspection(rows)
+		if err != nil {
+			return nil, err
+		}
+		inspections = append(inspections, *inspection)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrInspectionScan(err.Error())
+	}
+
+	return inspections, nil
+}
+
+/// Aux Function ///
+
+func scanRowIntoInspection(row scannable) (*Inspection, error) {
+
+	inspection := new(Inspection)
+	err := row.Scan(
+		&inspection.InspectionId,
+		&inspection.TreeId,
+		&inspection.State,
+		&inspection.Height,
+		&inspection.Diameter,
+		&inspection.Notes,
+		&inspection.TreePhotoId,
+		&inspection.InspectedBy,
+		&inspection.InspectedAt,
+		&inspection.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, errors.ErrInspectionScan(err.Error())
+	}
+	return inspection, nil
+}
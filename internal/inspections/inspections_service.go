@@ -0,0 +1,95 @@
+package inspections
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/photos"
+	"github.com/PabloPei/TreeSense-Backend/internal/trees"
+	"github.com/PabloPei/TreeSense-Backend/utils"
+)
+
+type Service struct {
+	repository      InspectionRepository
+	treeRepository  trees.TreeRepository
+	photoRepository photos.PhotoRepository
+	zoneChecker     trees.ZoneChecker
+}
+
+func NewService(repository InspectionRepository, treeRepository trees.TreeRepository, photoRepository photos.PhotoRepository, zoneChecker trees.ZoneChecker) *Service {
+	return &Service{repository: repository, treeRepository: treeRepository, photoRepository: photoRepository, zoneChecker: zoneChecker}
+}
+
+// CreateInspection records an observation of a tree inside the zones; nil zones means anywhere
+func (s *Service) CreateInspection(treeId []uint8, payload createInspectionPayload, userId []uint8, zones []string) error {
+
+	tree, err := s.treeRepository.GetTreeById(treeId)
+	if err != nil {
+		return err
+	}
+
+	if err := trees.CheckZones(s.zoneChecker, zones, tree.Latitude, tree.Longitude); err != nil {
+		return err
+	}
+
+	if _, err := s.treeRepository.GetTreeStateById(payload.State); err != nil {
+		return errors.ErrTreeStateNotFound
+	}
+
+	inspection := Inspection{
+		TreeId:      treeId,
+		State:       payload.State,
+		Height:      payload.Height,
+		Diameter:    payload.Diameter,
+		Notes:       payload.Notes,
+		InspectedBy: userId,
+		InspectedAt: time.Now(),
+	}
+
+	if payload.InspectedAt != nil {
+		if payload.InspectedAt.After(time.Now()) {
+			return errors.ErrInspectionInFuture
+		}
+		inspection.InspectedAt = *payload.InspectedAt
+	}
+
+	// The photo has to be one already uploaded for this same tree
+	if payload.PhotoId != "" {
+		photo, err := s.photoRepository.GetPhotoById([]uint8(payload.PhotoId))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(photo.TreeId, treeId) {
+			return errors.ErrPhotoNotFound
+		}
+		inspection.TreePhotoId = photo.TreePhotoId
+	}
+
+	return s.repository.CreateInspection(inspection)
+}
+
+// GetTreeTimeline returns every inspection of the tree, oldest first
+func (s *Service) GetTreeTimeline(treeId []uint8) ([]Inspection, error) {
+
+	if _, err := s.treeRepository.GetTreeById(treeId); err != nil {
+		return nil, err
+	}
+
+	inspections, err := s.repository.GetInspectionsByTreeId(treeId)
+	if err != nil {
+		return nil, err
+	}
+
+	if inspections == nil {
+		inspections = []Inspection{}
+	}
+
+	// Convert timestamps to Argentina's time zone
+	for i := range inspections {
+		inspections[i].InspectedAt = utils.ConvertUTCToArgentina(inspections[i].InspectedAt)
+		inspections[i].CreatedAt = utils.ConvertUTCToArgentina(inspections[i].CreatedAt)
+	}
+
+	return inspections, nil
+}
@@ -0,0 +1,37 @@
+package lockout
+
+import (
+	"time"
+)
+
+// Attempts is the failed login state of one account or client address
+type Attempts struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt *time.Time `json:"lastFailureAt"`
+	LockedUntil   *time.Time `json:"lockedUntil"`
+}
+
+// AttemptStore keeps the attempts. The Postgres store is shared by every instance of the API; the memory one suits
+// a single instance or development.
+type AttemptStore interface {
+	GetAttempts(key string) (*Attempts, error)
+	// RecordFailure adds a failure, starting the count over if the last one is older than window
+	RecordFailure(key string, now time.Time, window time.Duration) (*Attempts, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+	GetLocked(now time.Time) ([]Attempts, error)
+}
+
+type LockoutService interface {
+	Check(email string, address string) error
+	RegisterFailure(email string, address string) error
+	RegisterSuccess(email string) error
+	GetLocked() ([]Attempts, error)
+	Unlock(adminId []uint8, payload UnlockPayload) error
+}
+
+type UnlockPayload struct {
+	Email   string `json:"email" validate:"required_without=Address,omitempty,email"`
+	Address string `json:"address" validate:"omitempty,ip"`
+}
@@ -0,0 +1,62 @@
+package lockout
+
+import (
+	"net/http"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/middlewares"
+	"github.com/PabloPei/TreeSense-Backend/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	service LockoutService
+}
+
+func NewHandler(service LockoutService) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middlewares.Middleware) {
+	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleGetLocked)).Methods("GET")
+	router.HandleFunc("/unlock", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleUnlock)).Methods("POST")
+}
+
+func (h *Handler) handleGetLocked(w http.ResponseWriter, r *http.Request) {
+
+	locked, err := h.service.GetLocked()
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, locked)
+}
+
+func (h *Handler) handleUnlock(w http.ResponseWriter, r *http.Request) {
+
+	adminId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	var payload UnlockPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err = h.service.Unlock(adminId, payload)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Login unlocked successfully")
+}
@@ -0,0 +1,118 @@
+package lockout
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the attempts in process memory, so each API instance counts on its own and restarts forget them
+type MemoryStore struct {
+	mu        sync.Mutex
+	attempts  map[string]Attempts
+	pruneSize int // size at which stale entries are dropped, so made up emails can't grow the map forever
+}
+
+const minPruneSize = 1024
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: map[string]Attempts{}, pruneSize: minPruneSize}
+}
+
+func (m *MemoryStore) GetAttempts(key string) (*Attempts, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, ok := m.attempts[key]
+	if !ok {
+		attempts = Attempts{Key: key}
+	}
+
+	return &attempts, nil
+}
+
+func (m *MemoryStore) RecordFailure(key string, now time.Time, window time.Duration) (*Attempts, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, ok := m.attempts[key]
+	if !ok {
+		attempts = Attempts{Key: key}
+		if len(m.attempts) >= m.pruneSize {
+			m.prune(now, window)
+		}
+	}
+
+	if attempts.LastFailureAt != nil && attempts.LastFailureAt.Before(now.Add(-window)) {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.LastFailureAt = &now
+
+	m.attempts[key] = attempts
+
+	return &attempts, nil
+}
+
+func (m *MemoryStore) Lock(key string, until time.Time) error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if attempts, ok := m.attempts[key]; ok {
+		attempts.LockedUntil = &until
+		m.attempts[key] = attempts
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) Reset(key string) error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+
+	return nil
+}
+
+func (m *MemoryStore) GetLocked(now time.Time) ([]Attempts, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	locked := []Attempts{}
+	for _, attempts := range m.attempts {
+		if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+			locked = append(locked, attempts)
+		}
+	}
+
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].LockedUntil.After(*locked[j].LockedUntil)
+	})
+
+	return locked, nil
+}
+
+// Aux Functions
+
+// prune drops the entries whose failures are forgotten and that aren't locked. The caller holds the mutex.
+func (m *MemoryStore) prune(now time.Time, window time.Duration) {
+
+	for key, attempts := range m.attempts {
+		stale := attempts.LastFailureAt == nil || attempts.LastFailureAt.Before(now.Add(-window))
+		locked := attempts.LockedUntil != nil && attempts.LockedUntil.After(now)
+		if stale && !locked {
+			delete(m.attempts, key)
+		}
+	}
+
+	m.pruneSize = 2 * len(m.attempts)
+	if m.pruneSize < minPruneSize {
+		m.pruneSize = minPruneSize
+	}
+}
@@ -0,0 +1,101 @@
+package lockout
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+)
+
+// Postgres SQL Repository
+type SQLRepository struct {
+	db *sql.DB
+}
+
+func NewSQLRepository(db *sql.DB) *SQLRepository {
+	return &SQLRepository{db: db}
+}
+
+func (s *SQLRepository) GetAttempts(key string) (*Attempts, error) {
+
+	attempts := &Attempts{Key: key}
+	err := s.db.QueryRow(
+		"SELECT failures, last_failure_at, locked_until FROM auth.login_attempt WHERE attempt_key = $1",
+		key,
+	).Scan(&attempts.Failures, &attempts.LastFailureAt, &attempts.LockedUntil)
+
+	if err == sql.ErrNoRows {
+		return attempts, nil
+	}
+	if err != nil {
+		return nil, errors.ErrReadingLoginAttempts(err.Error())
+	}
+
+	return attempts, nil
+}
+
+func (s *SQLRepository) RecordFailure(key string, now time.Time, window time.Duration) (*Attempts, error) {
+
+	attempts := &Attempts{Key: key}
+	err := s.db.QueryRow(
+		`INSERT INTO auth.login_attempt (attempt_key, failures, last_failure_at) VALUES ($1, 1, $2)
+		ON CONFLICT (attempt_key) DO UPDATE SET
+			failures = CASE WHEN auth.login_attempt.last_failure_at < $3 THEN 1 ELSE auth.login_attempt.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING failures, last_failure_at, locked_until`,
+		key, now.UTC(), now.Add(-window).UTC(),
+	).Scan(&attempts.Failures, &attempts.LastFailureAt, &attempts.LockedUntil)
+
+	if err != nil {
+		return nil, errors.ErrCantUploadLoginAttempts(err.Error())
+	}
+
+	return attempts, nil
+}
+
+func (s *SQLRepository) Lock(key string, until time.Time) error {
+
+	_, err := s.db.Exec("UPDATE auth.login_attempt SET locked_until = $2 WHERE attempt_key = $1", key, until.UTC())
+	if err != nil {
+		return errors.ErrCantUploadLoginAttempts(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) Reset(key string) error {
+
+	_, err := s.db.Exec("DELETE FROM auth.login_attempt WHERE attempt_key = $1", key)
+	if err != nil {
+		return errors.ErrCantUploadLoginAttempts(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) GetLocked(now time.Time) ([]Attempts, error) {
+
+	rows, err := s.db.Query(
+		"SELECT attempt_key, failures, last_failure_at, locked_until FROM auth.login_attempt WHERE locked_until > $1 ORDER BY locked_until DESC",
+		now.UTC(),
+	)
+	if err != nil {
+		return nil, errors.ErrReadingLoginAttempts(err.Error())
+	}
+	defer rows.Close()
+
+	locked := []Attempts{}
+	for rows.Next() {
+		var attempts Attempts
+		if err := rows.Scan(&attempts.Key, &attempts.Failures, &attempts.LastFailureAt, &attempts.LockedUntil); err != nil {
+			return nil, errors.ErrReadingLoginAttempts(err.Error())
+		}
+		locked = append(locked, attempts)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrReadingLoginAttempts(err.Error())
+	}
+
+	return locked, nil
+}
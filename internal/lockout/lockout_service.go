@@ -0,0 +1,186 @@
+package lockout
+
+import (
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/PabloPei/TreeSense-Backend/conf"
+	"github.com/PabloPei/TreeSense-Backend/internal/audit"
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/users"
+)
+
+// First delay once the free failures are used up; it doubles with every further failure
+const baseBackoff = time.Second
+
+type Service struct {
+	store          AttemptStore
+	auditService   audit.AuditService
+	userRepository users.UserRepository
+}
+
+func NewService(store AttemptStore, auditService audit.AuditService, userRepository users.UserRepository) *Service {
+	return &Service{store: store, auditService: auditService, userRepository: userRepository}
+}
+
+// Check refuses the attempt while the account or the client address is backing off or locked
+func (s *Service) Check(email string, address string) error {
+
+	now := time.Now()
+	for _, key := range attemptKeys(email, address) {
+		attempts, err := s.store.GetAttempts(key)
+		if err != nil {
+			return err
+		}
+
+		if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+			return errors.ErrLoginLocked(int64(math.Ceil(attempts.LockedUntil.Sub(now).Seconds())))
+		}
+	}
+
+	return nil
+}
+
+// RegisterFailure counts a failed attempt against the account and the address, delaying or locking them as needed
+func (s *Service) RegisterFailure(email string, address string) error {
+
+	now := time.Now()
+	window := time.Duration(conf.LoginGuardConfig.FailureWindowInMinutes) * time.Minute
+
+	for _, key := range attemptKeys(email, address) {
+		attempts, err := s.store.RecordFailure(key, now, window)
+		if err != nil {
+			return err
+		}
+
+		delay, lockout := backoff(key, attempts.Failures)
+		if delay == 0 {
+			continue
+		}
+
+		if err := s.store.Lock(key, now.Add(delay)); err != nil {
+			return err
+		}
+
+		if lockout {
+			s.logActivity(s.userIdOf(key), "lockout_"+keyKind(key), keyValue(key))
+		}
+	}
+
+	return nil
+}
+
+// RegisterSuccess forgets the account's failures. The address keeps its count, so one valid account doesn't
+// let a client keep guessing others.
+func (s *Service) RegisterSuccess(email string) error {
+	return s.store.Reset(accountKey(email))
+}
+
+func (s *Service) GetLocked() ([]Attempts, error) {
+	return s.store.GetLocked(time.Now())
+}
+
+func (s *Service) Unlock(adminId []uint8, payload UnlockPayload) error {
+
+	if payload.Email != "" {
+		if err := s.store.Reset(accountKey(payload.Email)); err != nil {
+			return err
+		}
+		s.logActivity(adminId, "unlock_account", strings.ToLower(payload.Email))
+	}
+
+	if payload.Address != "" {
+		if err := s.store.Reset(addressKey(payload.Address)); err != nil {
+			return err
+		}
+		s.logActivity(adminId, "unlock_ip", payload.Address)
+	}
+
+	return nil
+}
+
+// Aux Functions
+
+// backoff returns how long to refuse logins after failures, and whether that is a full lockout
+func backoff(key string, failures int) (time.Duration, bool) {
+
+	free, max := conf.LoginGuardConfig.AccountFreeFailures, conf.LoginGuardConfig.AccountMaxFailures
+	if keyKind(key) == "ip" {
+		free, max = conf.LoginGuardConfig.IPFreeFailures, conf.LoginGuardConfig.IPMaxFailures
+	}
+
+	lockout := time.Duration(conf.LoginGuardConfig.LockoutInMinutes) * time.Minute
+
+	if int64(failures) >= max {
+		return lockout, true
+	}
+	if int64(failures) <= free {
+		return 0, false
+	}
+
+	// Capping the exponent keeps the shift from overflowing
+	exponent := int64(failures) - free - 1
+	if exponent > 20 {
+		exponent = 20
+	}
+
+	delay := baseBackoff << exponent
+	if delay > lockout {
+		delay = lockout
+	}
+
+	return delay, false
+}
+
+func (s *Service) userIdOf(key string) []uint8 {
+
+	if keyKind(key) != "account" {
+		return nil
+	}
+
+	user, err := s.userRepository.GetUserByEmail(keyValue(key))
+	if err != nil {
+		return nil
+	}
+
+	return user.UserId
+}
+
+// logActivity records in the audit log; a failure there must not block logins, so it is only logged
+func (s *Service) logActivity(userId []uint8, action string, detail string) {
+
+	err := s.auditService.LogActivity(audit.ActivityLog{UserID: userId, Action: action, Detail: detail})
+	if err != nil {
+		log.Println(errors.ErrLogActivity(err))
+	}
+}
+
+func attemptKeys(email string, address string) []string {
+
+	keys := []string{accountKey(email)}
+	if address != "" {
+		keys = append(keys, addressKey(address))
+	}
+
+	return keys
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func addressKey(address string) string {
+	return "ip:" + address
+}
+
+func keyKind(key string) string {
+	kind, _, _ := strings.Cut(key, ":")
+	return kind
+}
+
+func keyValue(key string) string {
+	_, value, _ := strings.Cut(key, ":")
+	return value
+}
@@ -2,9 +2,11 @@ package middlewares
 
 type PermissionService interface{
 	UserHasPermissions(permissionNames []string, userId []uint8) (bool, error)
+	GetPermissionZones(permissionNames []string, userId []uint8) ([]string, error)
 }
 
 type UserService interface{
-	UserExist(userId []uint8) (bool, error)
+	GetUserLanguage(userId []uint8) (string, error)
+	IsSessionActive(sessionId string) (bool, error)
 }
 
@@ -4,15 +4,20 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/PabloPei/TreeSense-Backend/conf"
 	"github.com/PabloPei/TreeSense-Backend/internal/audit"
 	"github.com/PabloPei/TreeSense-Backend/internal/auth"
 	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/i18n"
 	"github.com/PabloPei/TreeSense-Backend/utils"
 )
 
 type ContextKey string
 
 var UserKey ContextKey = "userId"
+var SessionKey ContextKey = "sessionId"
+var TokenKey ContextKey = "tokenId"
+var ZonesKey ContextKey = "zones"
 
 type Middleware struct {
 	permissionService PermissionService
@@ -25,6 +30,16 @@ func NewAuthMiddleware(permissionService PermissionService, userService UserServ
 }
 
 func (m *Middleware) RequireAuthAndPermission(permissions []string, useRefreshToken bool) func(http.HandlerFunc) http.HandlerFunc {
+	return m.requireAuth(permissions, false, useRefreshToken)
+}
+
+// RequireAuthAndAnyPermission lets the request through when the user holds at least one of the permissions.
+// 2FA and zones are resolved for the permissions the user holds.
+func (m *Middleware) RequireAuthAndAnyPermission(permissions []string, useRefreshToken bool) func(http.HandlerFunc) http.HandlerFunc {
+	return m.requireAuth(permissions, true, useRefreshToken)
+}
+
+func (m *Middleware) requireAuth(permissions []string, anyPermission bool, useRefreshToken bool) func(http.HandlerFunc) http.HandlerFunc {
 	return func(handler http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 
@@ -36,34 +51,53 @@ func (m *Middleware) RequireAuthAndPermission(permissions []string, useRefreshTo
 
 			token := utils.GetTokenFromRequest(r)
 
-			claims, err := auth.ValidateJWT(token, useRefreshToken)
+			claims, err := auth.ValidateJWT(token, useRefreshToken, m.userService)
 			if err != nil {
-				utils.WriteError(w, http.StatusForbidden, errors.ErrJWTInvalidToken)
+				utils.WriteError(w, err)
 				return
 			}
 
-			userIDStr, ok := claims["userId"].(string)
-			if !ok {
-				utils.WriteError(w, http.StatusForbidden, errors.ErrJWTInvalidToken)
+			userIDStr := claims.Subject
+			userID := []uint8(userIDStr)
+
+			language, err := m.userService.GetUserLanguage(userID)
+			if err != nil {
+				utils.WriteError(w, errors.ErrUserNotFound)
 				return
 			}
 
-			userID := []uint8(userIDStr)
+			// The user's preference wins over Accept-Language
+			ctx := r.Context()
+			if i18n.IsSupported(language) {
+				w = i18n.WithWriterLanguage(w, language)
+				ctx = i18n.WithLanguage(ctx, language)
+			}
+
+			granted, ok, err := m.grantedPermissions(permissions, anyPermission, userID)
+			if err != nil || !ok {
+				utils.WriteError(w, errors.ErrUserNotHavePermissions(permissions))
+				return
+			}
 
-			exists, err := m.userService.UserExist(userID)
-			if err != nil || !exists {
-				utils.WriteError(w, http.StatusForbidden, errors.ErrUserNotFound)
+			if requiresTwoFactor(granted) && !claims.HasTwoFactor() {
+				utils.WriteError(w, errors.ErrTwoFactorRequired)
 				return
 			}
 
-			hasPerm, err := m.permissionService.UserHasPermissions(permissions, userID)
-			if err != nil || !hasPerm {
-				utils.WriteError(w, http.StatusForbidden, errors.ErrUserNotHavePermissions(permissions))
+			// Zone scoped assignments only grant the permissions inside their zones, which the services enforce
+			zones, err := m.permissionService.GetPermissionZones(granted, userID)
+			if err != nil {
+				utils.WriteError(w, err)
 				return
 			}
 
 			// Agregamos userID al contexto
-			ctx := context.WithValue(r.Context(), UserKey, userIDStr)
+			ctx = context.WithValue(ctx, UserKey, userIDStr)
+			ctx = context.WithValue(ctx, ZonesKey, zones)
+			ctx = context.WithValue(ctx, SessionKey, claims.SessionId)
+			if useRefreshToken {
+				ctx = context.WithValue(ctx, TokenKey, claims.ID)
+			}
 			handler(w, r.WithContext(ctx))
 
 			// Registro de actividad si corresponde
@@ -82,6 +116,40 @@ func (m *Middleware) RequireAuthAndPermission(permissions []string, useRefreshTo
 	}
 }
 
+// grantedPermissions returns the permissions the request goes through with, and false if the user lacks them
+func (m *Middleware) grantedPermissions(permissions []string, anyPermission bool, userID []uint8) ([]string, bool, error) {
+
+	if !anyPermission || len(permissions) == 0 {
+		hasPerm, err := m.permissionService.UserHasPermissions(permissions, userID)
+		return permissions, err == nil && hasPerm, err
+	}
+
+	var granted []string
+	for _, permission := range permissions {
+		hasPerm, err := m.permissionService.UserHasPermissions([]string{permission}, userID)
+		if err != nil {
+			return nil, false, err
+		}
+		if hasPerm {
+			granted = append(granted, permission)
+		}
+	}
+
+	return granted, len(granted) > 0, nil
+}
+
+// requiresTwoFactor tells whether any of the permissions falls under the 2FA policy
+func requiresTwoFactor(permissions []string) bool {
+	for _, permission := range permissions {
+		for _, required := range conf.AccountConfig.TwoFactorRequiredPermissions {
+			if permission == required {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func GetUserIDFromContext(ctx context.Context) ([]uint8, error) {
 	userID, ok := ctx.Value(UserKey).(string)
 
@@ -90,3 +158,28 @@ func GetUserIDFromContext(ctx context.Context) ([]uint8, error) {
 	}
 	return []uint8(userID), nil
 }
+
+func GetSessionIDFromContext(ctx context.Context) (string, error) {
+	sessionID, ok := ctx.Value(SessionKey).(string)
+
+	if !ok {
+		return "", errors.ErrJWTInvalidToken
+	}
+	return sessionID, nil
+}
+
+// GetTokenIDFromContext returns the jti of the refresh token the request was authenticated with
+func GetTokenIDFromContext(ctx context.Context) (string, error) {
+	tokenID, ok := ctx.Value(TokenKey).(string)
+
+	if !ok {
+		return "", errors.ErrJWTInvalidToken
+	}
+	return tokenID, nil
+}
+
+// GetZonesFromContext returns the zone ids the route's permissions are limited to for the user, or nil if they apply everywhere
+func GetZonesFromContext(ctx context.Context) []string {
+	zones, _ := ctx.Value(ZonesKey).([]string)
+	return zones
+}
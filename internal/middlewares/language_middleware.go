@@ -0,0 +1,17 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/i18n"
+)
+
+// LanguageMiddleware picks the response language from Accept-Language. RequireAuthAndPermission then switches to the user's own language.
+func LanguageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		language := i18n.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+
+		ctx := i18n.WithLanguage(r.Context(), language)
+		next.ServeHTTP(i18n.WithWriterLanguage(w, language), r.WithContext(ctx))
+	})
+}
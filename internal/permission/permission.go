@@ -9,6 +9,7 @@ type PermissionAssignment struct {
 type PermissionRepository interface {
 	GetUserPermissions(userId []uint8) ([]PermissionAssignment, error)
 	GetPermissionByName(name string) (*PermissionAssignment, error)
+	GetUserPermissionZones(userId []uint8, permissionNames []string) (map[string][]string, error)
 }
 
 type PermissionService interface {
@@ -28,13 +28,13 @@ func (h *Handler) handleGetPermissions(w http.ResponseWriter, r *http.Request) {
 
 	userId, err := middlewares.GetUserIDFromContext(r.Context())
 	if err != nil {
-		utils.WriteError(w, http.StatusUnauthorized, errors.ErrJWTInvalidToken)
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
 		return
 	}
 
 	permissions, err := h.service.GetCurrentUserPermissions(userId)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
@@ -47,13 +47,13 @@ func (h *Handler) handleGetUserPermissions(w http.ResponseWriter, r *http.Reques
 	email, ok := vars["email"]
 
 	if !ok {
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrUserNotFound)
+		utils.WriteError(w, errors.ErrUserNotFound)
 		return
 	}
 
 	permissions, err := h.service.GetUserPermissions(email)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
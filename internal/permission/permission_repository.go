@@ -3,6 +3,7 @@ package permission
 import (
 	"database/sql"
 	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/lib/pq"
 )
 
 // Postgres SQL Repository
@@ -39,6 +40,7 @@ func (s *SQLRepository) GetUserPermissions(userId []uint8) ([]PermissionAssignme
         auth.permission p ON rp.permission_name = p.permission_name
     WHERE 
         ur.user_id = $1
+        AND ur.valid_until > CURRENT_TIMESTAMP  -- Las asignaciones vencidas no otorgan permisos
     ORDER BY 
         p.permission_name, r.role_id;  -- Agregar orden para DISTINCT ON
     `
@@ -90,6 +92,52 @@ func (s *SQLRepository) GetPermissionByName(name string) (*PermissionAssignment,
 }
 
 
+// GetUserPermissionZones maps each of the permissions the user holds to the zone ids it is limited to.
+// A permission granted by any assignment without zones maps to nil, meaning it applies everywhere.
+func (s *SQLRepository) GetUserPermissionZones(userId []uint8, permissionNames []string) (map[string][]string, error) {
+
+	rows, err := s.db.Query(
+		`SELECT rp.permission_name, urz.zone_id
+		FROM auth.user_role ur
+		JOIN auth.role r ON ur.role_id = r.role_id
+		JOIN auth.role_permission rp ON r.role_name = rp.role_name
+		LEFT JOIN auth.user_role_zone urz ON urz.user_id = ur.user_id AND urz.role_id = ur.role_id
+		WHERE ur.user_id = $1 AND ur.valid_until > CURRENT_TIMESTAMP AND rp.permission_name = ANY($2)`,
+		userId, pq.Array(permissionNames),
+	)
+	if err != nil {
+		return nil, errors.ErrReadingPermission(err.Error())
+	}
+	defer rows.Close()
+
+	zones := map[string][]string{}
+	everywhere := map[string]bool{}
+	for rows.Next() {
+		var permissionName string
+		var zoneId sql.NullString
+		if err := rows.Scan(&permissionName, &zoneId); err != nil {
+			return nil, errors.ErrPermissionScan(err.Error())
+		}
+
+		if !zoneId.Valid {
+			everywhere[permissionName] = true
+			continue
+		}
+		zones[permissionName] = append(zones[permissionName], zoneId.String)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrPermissionScan(err.Error())
+	}
+
+	for permissionName := range everywhere {
+		zones[permissionName] = nil
+	}
+
+	return zones, nil
+}
+
+
 /// Aux Function ///
 func scanRowIntoPermissionsAssigment(row scannable) (*PermissionAssignment, error) {
 	permission := new(PermissionAssignment)
@@ -53,3 +53,61 @@ func (s *Service) UserHasPermissions(permissionNames []string, userId []uint8) (
 
 	return true, nil
 }
+
+// GetPermissionZones returns the zone ids where the user holds all the permissions, or nil if that is everywhere.
+// Zones are compared by id, so two overlapping zones granting different permissions don't combine.
+func (s *Service) GetPermissionZones(permissionNames []string, userId []uint8) ([]string, error) {
+
+	if len(permissionNames) == 0 {
+		return nil, nil
+	}
+
+	permissionZones, err := s.repository.GetUserPermissionZones(userId, permissionNames)
+	if err != nil {
+		return nil, err
+	}
+
+	var zones []string
+	restricted := false
+	for _, permissionName := range permissionNames {
+		grantedZones, ok := permissionZones[permissionName]
+		if !ok {
+			return []string{}, nil
+		}
+		if grantedZones == nil {
+			continue
+		}
+
+		if !restricted {
+			zones = grantedZones
+			restricted = true
+			continue
+		}
+		zones = intersectZones(zones, grantedZones)
+	}
+
+	if restricted && zones == nil {
+		zones = []string{}
+	}
+
+	return zones, nil
+}
+
+/// Aux Functions ///
+
+func intersectZones(a []string, b []string) []string {
+
+	inB := make(map[string]bool, len(b))
+	for _, zone := range b {
+		inB[zone] = true
+	}
+
+	intersection := []string{}
+	for _, zone := range a {
+		if inB[zone] {
+			intersection = append(intersection, zone)
+		}
+	}
+
+	return intersection
+}
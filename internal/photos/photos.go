@@ -0,0 +1,39 @@
+package photos
+
+import (
+	"io"
+	"time"
+)
+
+type TreePhoto struct {
+	TreePhotoId  []uint8   `json:"treePhotoId"`
+	TreeId       []uint8   `json:"treeId"`
+	StorageKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	ContentType  string    `json:"contentType"`
+	SizeBytes    int64     `json:"sizeBytes"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	UploadedBy   []uint8   `json:"uploadedBy"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Url          string    `json:"url"`
+	ThumbnailUrl string    `json:"thumbnailUrl"`
+}
+
+type PhotoRepository interface {
+	CreatePhoto(photo TreePhoto) ([]uint8, error)
+	GetPhotoById(photoId []uint8) (*TreePhoto, error)
+	GetPhotosByTreeId(treeId []uint8) ([]TreePhoto, error)
+}
+
+type PhotoService interface {
+	UploadPhoto(treeId []uint8, file io.Reader, userId []uint8, zones []string) (*TreePhoto, error)
+	GetTreePhotos(treeId []uint8) ([]TreePhoto, error)
+	OpenPhoto(treeId []uint8, photoId []uint8, thumbnail bool) (io.ReadCloser, string, error)
+}
+
+// Only formats the standard library can decode are accepted, thumbnails are always JPEG
+var allowedContentTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+}
@@ -0,0 +1,110 @@
+package photos
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/PabloPei/TreeSense-Backend/conf"
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/middlewares"
+	"github.com/PabloPei/TreeSense-Backend/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	service PhotoService
+}
+
+func NewHandler(service PhotoService) *Handler {
+	return &Handler{service: service}
+}
+
+// Registered on the tree subrouter, paths are relative to /tree
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middlewares.Middleware) {
+
+	router.HandleFunc("/{treeId}/photos", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleUploadPhoto)).Methods("POST")
+	router.HandleFunc("/{treeId}/photos", middleware.RequireAuthAndAnyPermission([]string{"READ", "SURVEY"}, false)(h.handleGetTreePhotos)).Methods("GET")
+	router.HandleFunc("/{treeId}/photos/{photoId}", middleware.RequireAuthAndAnyPermission([]string{"READ", "SURVEY"}, false)(h.handleGetPhoto)).Methods("GET")
+}
+
+// Expects a multipart form with the image in the "photo" field
+func (h *Handler) handleUploadPhoto(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	treeId := mux.Vars(r)["treeId"]
+	if !utils.IsUUID(treeId) {
+		utils.WriteError(w, errors.ErrTreeNotFound)
+		return
+	}
+
+	// Leave room for the multipart envelope around the image
+	maxSize := conf.StorageConfig.PhotoMaxSizeInBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		utils.WriteError(w, errors.ErrPhotoTooLarge(maxSize))
+		return
+	}
+
+	file, _, err := r.FormFile("photo")
+	if err != nil {
+		utils.WriteError(w, errors.ErrInvalidaPayload("missing photo file"))
+		return
+	}
+	defer file.Close()
+
+	photo, err := h.service.UploadPhoto([]uint8(treeId), file, userId, middlewares.GetZonesFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, photo)
+}
+
+func (h *Handler) handleGetTreePhotos(w http.ResponseWriter, r *http.Request) {
+
+	treeId := mux.Vars(r)["treeId"]
+	if !utils.IsUUID(treeId) {
+		utils.WriteError(w, errors.ErrTreeNotFound)
+		return
+	}
+
+	photos, err := h.service.GetTreePhotos([]uint8(treeId))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"photos": photos})
+}
+
+// size=thumbnail serves the JPEG thumbnail instead of the original
+func (h *Handler) handleGetPhoto(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	if !utils.IsUUID(vars["treeId"]) {
+		utils.WriteError(w, errors.ErrTreeNotFound)
+		return
+	}
+	if !utils.IsUUID(vars["photoId"]) {
+		utils.WriteError(w, errors.ErrPhotoNotFound)
+		return
+	}
+
+	reader, contentType, err := h.service.OpenPhoto([]uint8(vars["treeId"]), []uint8(vars["photoId"]), r.URL.Query().Get("size") == "thumbnail")
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, reader)
+}
@@ -0,0 +1,95 @@
+package photos
+
+import (
+	"database/sql"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+)
+
+// Postgres SQL Repository
+type SQLRepository struct {
+	db *sql.DB
+}
+
+func NewSQLRepository(db *sql.DB) *SQLRepository {
+	return &SQLRepository{db: db}
+}
+
+type scannable interface {
+	Scan(dest ...interface{}) error
+}
+
+const photoColumns = "tree_photo_id, tree_id, storage_key, thumbnail_key, content_type, size_bytes, width, height, uploaded_by, created_at"
+
+func (s *SQLRepository) CreatePhoto(photo TreePhoto) ([]uint8, error) {
+
+	var photoId []uint8
+	err := s.db.QueryRow(
+		"INSERT INTO treesense.\"tree_photo\" (tree_id, storage_key, thumbnail_key, content_type, size_bytes, width, height, uploaded_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING tree_photo_id",
+		photo.TreeId, photo.StorageKey, photo.ThumbnailKey, photo.ContentType, photo.SizeBytes, photo.Width, photo.Height, photo.UploadedBy,
+	).Scan(&photoId)
+
+	if err != nil {
+		return nil, errors.ErrCantStorePhoto(err.Error())
+	}
+
+	return photoId, nil
+}
+
+func (s *SQLRepository) GetPhotoById(photoId []uint8) (*TreePhoto, error) {
+	row := s.db.QueryRow("SELECT "+photoColumns+" FROM treesense.\"tree_photo\" WHERE tree_photo_id = $1", photoId)
+	return scanRowIntoPhoto(row)
+}
+
+func (s *SQLRepository) GetPhotosByTreeId(treeId []uint8) ([]TreePhoto, error) {
+
+	rows, err := s.db.Query("SELECT "+photoColumns+" FROM treesense.\"tree_photo\" WHERE tree_id = $1 ORDER BY created_at DESC", treeId)
+	if err != nil {
+		return nil, errors.ErrPhotoScan(err.Error())
+	}
+
+	defer rows.Close()
+
+	var photos []TreePhoto
+
+	for rows.Next() {
+		photo, err := scanRowIntoPhoto(rows)
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, *photo)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrPhotoScan(err.Error())
+	}
+
+	return photos, nil
+}
+
+/// Aux Function ///
+
+func scanRowIntoPhoto(row scannable) (*TreePhoto, error) {
+
+	photo := new(TreePhoto)
+	err := row.Scan(
+		&photo.TreePhotoId,
+		&photo.TreeId,
+		&photo.StorageKey,
+		&photo.ThumbnailKey,
+		&photo.ContentType,
+		&photo.SizeBytes,
+		&photo.Width,
+		&photo.Height,
+		&photo.UploadedBy,
+		&photo.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrPhotoNotFound
+		}
+		return nil, errors.ErrPhotoScan(err.Error())
+	}
+	return photo, nil
+}
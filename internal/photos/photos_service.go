@@ -0,0 +1,233 @@
+package photos
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+
+	"github.com/PabloPei/TreeSense-Backend/conf"
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/trees"
+	"github.com/PabloPei/TreeSense-Backend/pkg/storage"
+	"github.com/PabloPei/TreeSense-Backend/utils"
+)
+
+const photoPath = "/api/v1/tree/%s/photos/%s"
+
+type Service struct {
+	repository     PhotoRepository
+	treeRepository trees.TreeRepository
+	zoneChecker    trees.ZoneChecker
+	store          storage.BlobStore
+}
+
+func NewService(repository PhotoRepository, treeRepository trees.TreeRepository, zoneChecker trees.ZoneChecker, store storage.BlobStore) *Service {
+	return &Service{repository: repository, treeRepository: treeRepository, zoneChecker: zoneChecker, store: store}
+}
+
+// UploadPhoto checks size and real content type, stores the original plus a JPEG thumbnail and records both keys.
+// The tree must lie inside the zones; nil zones means anywhere.
+func (s *Service) UploadPhoto(treeId []uint8, file io.Reader, userId []uint8, zones []string) (*TreePhoto, error) {
+
+	tree, err := s.treeRepository.GetTreeById(treeId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := trees.CheckZones(s.zoneChecker, zones, tree.Latitude, tree.Longitude); err != nil {
+		return nil, err
+	}
+
+	maxSize := conf.StorageConfig.PhotoMaxSizeInBytes
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, errors.ErrCantStorePhoto(err.Error())
+	}
+	if int64(len(data)) > maxSize {
+		return nil, errors.ErrPhotoTooLarge(maxSize)
+	}
+
+	// The declared multipart type is not trusted, the content is sniffed instead
+	contentType := http.DetectContentType(data)
+	extension, ok := allowedContentTypes[contentType]
+	if !ok {
+		return nil, errors.ErrPhotoContentType(contentType)
+	}
+
+	// The header is enough to reject huge dimensions before decode allocates them
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.ErrPhotoDecode
+	}
+	maxPixels := conf.StorageConfig.PhotoMaxPixels
+	if int64(config.Width)*int64(config.Height) > maxPixels {
+		return nil, errors.ErrPhotoTooManyPixels(maxPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.ErrPhotoDecode
+	}
+
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, thumbnail(img, int(conf.StorageConfig.PhotoThumbnailMaxInPixel)), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, errors.ErrCantStorePhoto(err.Error())
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, errors.ErrCantStorePhoto(err.Error())
+	}
+
+	photo := TreePhoto{
+		TreeId:       treeId,
+		StorageKey:   fmt.Sprintf("trees/%s/%s.%s", treeId, name, extension),
+		ThumbnailKey: fmt.Sprintf("trees/%s/%s_thumb.jpg", treeId, name),
+		ContentType:  contentType,
+		SizeBytes:    int64(len(data)),
+		Width:        img.Bounds().Dx(),
+		Height:       img.Bounds().Dy(),
+		UploadedBy:   userId,
+	}
+
+	if err := s.store.Put(photo.StorageKey, bytes.NewReader(data), contentType); err != nil {
+		return nil, errors.ErrCantStorePhoto(err.Error())
+	}
+
+	if err := s.store.Put(photo.ThumbnailKey, &thumb, "image/jpeg"); err != nil {
+		s.store.Delete(photo.StorageKey)
+		return nil, errors.ErrCantStorePhoto(err.Error())
+	}
+
+	photoId, err := s.repository.CreatePhoto(photo)
+	if err != nil {
+		s.store.Delete(photo.StorageKey)
+		s.store.Delete(photo.ThumbnailKey)
+		return nil, err
+	}
+
+	created, err := s.repository.GetPhotoById(photoId)
+	if err != nil {
+		return nil, err
+	}
+
+	preparePhoto(created)
+
+	return created, nil
+}
+
+func (s *Service) GetTreePhotos(treeId []uint8) ([]TreePhoto, error) {
+
+	if _, err := s.treeRepository.GetTreeById(treeId); err != nil {
+		return nil, err
+	}
+
+	photos, err := s.repository.GetPhotosByTreeId(treeId)
+	if err != nil {
+		return nil, err
+	}
+
+	if photos == nil {
+		photos = []TreePhoto{}
+	}
+
+	for i := range photos {
+		preparePhoto(&photos[i])
+	}
+
+	return photos, nil
+}
+
+// OpenPhoto returns the image (or its thumbnail) and its content type, the caller must close the reader
+func (s *Service) OpenPhoto(treeId []uint8, photoId []uint8, thumbnail bool) (io.ReadCloser, string, error) {
+
+	photo, err := s.repository.GetPhotoById(photoId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !bytes.Equal(photo.TreeId, treeId) {
+		return nil, "", errors.ErrPhotoNotFound
+	}
+
+	key, contentType := photo.StorageKey, photo.ContentType
+	if thumbnail {
+		key, contentType = photo.ThumbnailKey, "image/jpeg"
+	}
+
+	reader, err := s.store.Get(key)
+	if err == storage.ErrNotFound {
+		return nil, "", errors.ErrPhotoNotFound
+	} else if err != nil {
+		return nil, "", errors.ErrCantStorePhoto(err.Error())
+	}
+
+	return reader, contentType, nil
+}
+
+// Aux Functions
+
+func preparePhoto(photo *TreePhoto) {
+	photo.CreatedAt = utils.ConvertUTCToArgentina(photo.CreatedAt)
+	photo.Url = fmt.Sprintf(photoPath, photo.TreeId, photo.TreePhotoId)
+	photo.ThumbnailUrl = photo.Url + "?size=thumbnail"
+}
+
+func randomName() (string, error) {
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// thumbnail scales img so its longest side is at most maxSide, averaging the source pixels behind each target pixel
+func thumbnail(img image.Image, maxSide int) image.Image {
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width <= maxSide && height <= maxSide {
+		return img
+	}
+
+	targetWidth, targetHeight := maxSide, height*maxSide/width
+	if height > width {
+		targetWidth, targetHeight = width*maxSide/height, maxSide
+	}
+	targetWidth, targetHeight = max(targetWidth, 1), max(targetHeight, 1)
+
+	thumb := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+
+	for y := 0; y < targetHeight; y++ {
+		y0 := bounds.Min.Y + y*height/targetHeight
+		y1 := max(bounds.Min.Y+(y+1)*height/targetHeight, y0+1)
+
+		for x := 0; x < targetWidth; x++ {
+			x0 := bounds.Min.X + x*width/targetWidth
+			x1 := max(bounds.Min.X+(x+1)*width/targetWidth, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			thumb.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+
+	return thumb
+}
@@ -4,6 +4,9 @@ import (
 	"time"
 )
 
+// AdminRole is the role that must always keep at least one holder
+const AdminRole = "ADMIN"
+
 type Role struct {
 	RoleId       	 []uint8   `json:"roleId"`
 	RoleName     	 string    `json:"roleName"`
@@ -18,25 +21,72 @@ type RoleAssigment struct {
 	RoleDescription  string    `json:"roleDescription"`
 	ValidUntil    	 time.Time `json:"validUntil"`
 	AssignedBy    	 []uint8   `json:"assignedBy"`
+	Zones            []string  `json:"zones"` // names of the zones the role is limited to, empty if it applies everywhere
+}
+
+type Permission struct {
+	PermissionName string `json:"permissionName"`
+	Description    string `json:"description"`
+}
+
+// RolePermissions is one row of the role–permission matrix
+type RolePermissions struct {
+	RoleName        string   `json:"roleName"`
+	RoleDescription string   `json:"roleDescription"`
+	Permissions     []string `json:"permissions"`
+}
+
+type PermissionMatrix struct {
+	Permissions []Permission      `json:"permissions"`
+	Roles       []RolePermissions `json:"roles"`
+}
+
+// RoleExpiration is an active assignment that lapses soon
+type RoleExpiration struct {
+	UserId     []uint8   `json:"userId"`
+	UserName   string    `json:"userName"`
+	Email      string    `json:"email"`
+	RoleName   string    `json:"roleName"`
+	ValidUntil time.Time `json:"validUntil"`
 }
 
 type RoleRepository interface {
 	CreateRole(Role) error
 	GetRoles() ([]Role, error) 
 	GetRoleByName(roleName string) (*Role, error)
-	CreateRoleAssigment(userId []uint8, roleId []uint8, by []uint8, valid_until time.Time) error
+	UpdateRole(roleId []uint8, roleName string, description string) error
+	DeleteRole(roleId []uint8) error
+	GetPermissions() ([]Permission, error)
+	GetPermissionByName(permissionName string) (*Permission, error)
+	GetRolePermissions() ([]RolePermissions, error)
+	GetRolePermissionNames(roleName string) ([]string, error)
+	GetUserPermissionZones(userId []uint8) (map[string][]string, error)
+	GrantPermission(roleName string, permissionName string) (bool, error)
+	RevokePermission(roleName string, permissionName string) (bool, error)
+	CreateRoleAssigment(userId []uint8, roleId []uint8, by []uint8, valid_until time.Time, zoneNames []string) error
 	GetUserRoles(userId []uint8)([]RoleAssigment, error)
-	DeleteRoleAssigment(userId []uint8, roleId []uint8) error
+	DeleteRoleAssigment(userId []uint8, roleId []uint8, by []uint8, keepLastHolder bool) error
+	RenewRoleAssigment(userId []uint8, roleId []uint8, by []uint8, validUntil time.Time) (bool, error)
+	GetExpiringRoleAssigments(before time.Time) ([]RoleExpiration, error)
+	ExtendLastRoleAssigment(roleId []uint8, horizon time.Time, validUntil time.Time) (bool, error)
+	ArchiveExpiredRoleAssigments() (int64, error)
 }
 
 type RoleService interface {
 	CreateRole(payload CreateRolePayload) error
 	GetRoles() ([]Role, error) 
+	UpdateRole(payload UpdateRolePayload) error
+	DeleteRole(payload DeleteRolePayload) error
+	GetPermissionMatrix() (*PermissionMatrix, error)
+	GrantPermission(payload RolePermissionPayload, by []uint8) error
+	RevokePermission(payload RolePermissionPayload) error
 	CreateRoleAssigment(payload CreateUserRoleAssigmentPayload, email string, by []uint8) error
 	GetUserRoles(email string) ([]RoleAssigment, error)
 	GetCurrentUserRoles(userId []uint8)([]RoleAssigment, error) 
 	UserHasRole(roleName string, userId []uint8)(bool, error)
-	DeleteRoleAssigment(payload DeleteUserRoleAssigmentPayload, email string) error
+	DeleteRoleAssigment(payload DeleteUserRoleAssigmentPayload, email string, by []uint8) error
+	RenewRoleAssigment(payload RenewUserRoleAssigmentPayload, email string, by []uint8) error
+	GetExpiringRoleAssigments(days int) ([]RoleExpiration, error)
 }
 
 type CreateRolePayload struct {
@@ -44,9 +94,31 @@ type CreateRolePayload struct {
 	RoleDescription    string `json:"roleDescription" validate:"required"`
 }
 
+// UpdateRolePayload renames the role and/or changes its description; empty fields are left as they are
+type UpdateRolePayload struct {
+	RoleName        string `json:"roleName" validate:"required"`
+	NewRoleName     string `json:"newRoleName" validate:"omitempty,max=50"`
+	RoleDescription string `json:"roleDescription"`
+}
+
+type DeleteRolePayload struct {
+	RoleName string `json:"roleName" validate:"required"`
+}
+
+type RolePermissionPayload struct {
+	RoleName       string `json:"roleName" validate:"required"`
+	PermissionName string `json:"permissionName" validate:"required"`
+}
+
 type CreateUserRoleAssigmentPayload struct {
 	RoleName           string `json:"roleName" validate:"required"`
 	ValidUntil         time.Time `json:"validUntil" validate:"required"`
+	Zones              []string `json:"zones" validate:"omitempty,unique,dive,required"` // zone names; empty applies everywhere
+}
+
+type RenewUserRoleAssigmentPayload struct {
+	RoleName   string    `json:"roleName" validate:"required"`
+	ValidUntil time.Time `json:"validUntil" validate:"required"`
 }
 
 type DeleteUserRoleAssigmentPayload struct {
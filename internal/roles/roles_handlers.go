@@ -2,11 +2,11 @@ package roles
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/PabloPei/TreeSense-Backend/internal/errors"
 	"github.com/PabloPei/TreeSense-Backend/internal/middlewares"
 	"github.com/PabloPei/TreeSense-Backend/utils"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
@@ -23,12 +23,22 @@ func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middlewares.Mid
 	/// Roles ///
 	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleCreateRole)).Methods("POST")
 	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleGetRoles)).Methods("GET")
+	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleUpdateRole)).Methods("PUT", "PATCH")
+	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleDeleteRole)).Methods("DELETE")
 	router.HandleFunc("/all", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleGetAllRoles)).Methods("GET")
+	router.HandleFunc("/expirations", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleGetExpiringRoleAssigments)).Methods("GET")
+
+	/// Permissions ///
+	router.HandleFunc("/permissions", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleGetPermissionMatrix)).Methods("GET")
+	router.HandleFunc("/permissions", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleGrantPermission)).Methods("POST")
+	router.HandleFunc("/permissions", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleRevokePermission)).Methods("DELETE")
+
 	router.HandleFunc("/{email}", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleGetUserRoles)).Methods("GET")
 
 	/// Assigments ///
-	router.HandleFunc("/{email}", middleware.RequireAuthAndPermission([]string{}, false)(h.handleCreateRoleAssigment)).Methods("POST")
-	router.HandleFunc("/{email}", middleware.RequireAuthAndPermission([]string{}, false)(h.handleDeleteRoleAssigment)).Methods("DELETE")
+	router.HandleFunc("/{email}", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleCreateRoleAssigment)).Methods("POST")
+	router.HandleFunc("/{email}", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleDeleteRoleAssigment)).Methods("DELETE")
+	router.HandleFunc("/{email}/renew", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleRenewRoleAssigment)).Methods("PUT")
 }
 
 // / Roles ///
@@ -36,32 +46,73 @@ func (h *Handler) handleCreateRole(w http.ResponseWriter, r *http.Request) {
 
 	var role CreateRolePayload
 	if err := utils.ParseJSON(r, &role); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
 	if err := utils.Validate.Struct(role); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrInvalidaPayload(validationErrors.Error()))
+		utils.WriteError(w, utils.ValidationError(err))
 		return
 	}
 
 	err := h.service.CreateRole(role)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusCreated, "Role created successfully")
+}
+
+func (h *Handler) handleUpdateRole(w http.ResponseWriter, r *http.Request) {
+
+	var role UpdateRolePayload
+	if err := utils.ParseJSON(r, &role); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(role); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err := h.service.UpdateRole(role)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Role updated successfully")
+}
+
+func (h *Handler) handleDeleteRole(w http.ResponseWriter, r *http.Request) {
+
+	var role DeleteRolePayload
+	if err := utils.ParseJSON(r, &role); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(role); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err := h.service.DeleteRole(role)
+	if err != nil {
+		utils.WriteError(w, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, map[string]string{
-		"message": "Role created successfully",
-	})
+	utils.WriteMessage(w, http.StatusOK, "Role deleted successfully")
 }
 
 func (h *Handler) handleGetAllRoles(w http.ResponseWriter, r *http.Request) {
 
 	roles, err := h.service.GetRoles()
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
@@ -72,13 +123,13 @@ func (h *Handler) handleGetRoles(w http.ResponseWriter, r *http.Request) {
 
 	userId, err := middlewares.GetUserIDFromContext(r.Context())
 	if err != nil {
-		utils.WriteError(w, http.StatusUnauthorized, errors.ErrJWTInvalidToken)
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
 		return
 	}
 
 	roles, err := h.service.GetCurrentUserRoles(userId)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
@@ -91,88 +142,207 @@ func (h *Handler) handleGetUserRoles(w http.ResponseWriter, r *http.Request) {
 	email, ok := vars["email"]
 
 	if !ok {
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrUserNotFound)
+		utils.WriteError(w, errors.ErrUserNotFound)
 		return
 	}
 
 	roles, err := h.service.GetUserRoles(email)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, roles)
 }
 
+/// Permissions ///
+
+func (h *Handler) handleGetPermissionMatrix(w http.ResponseWriter, r *http.Request) {
+
+	matrix, err := h.service.GetPermissionMatrix()
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, matrix)
+}
+
+func (h *Handler) handleGrantPermission(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	var rolePermission RolePermissionPayload
+	if err := utils.ParseJSON(r, &rolePermission); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(rolePermission); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err = h.service.GrantPermission(rolePermission, userId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusCreated, "Permission granted successfully")
+}
+
+func (h *Handler) handleRevokePermission(w http.ResponseWriter, r *http.Request) {
+
+	var rolePermission RolePermissionPayload
+	if err := utils.ParseJSON(r, &rolePermission); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(rolePermission); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err := h.service.RevokePermission(rolePermission)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Permission revoked successfully")
+}
+
 /// Assigments ///
 
 func (h *Handler) handleCreateRoleAssigment(w http.ResponseWriter, r *http.Request) {
 
 	var roleAssigment CreateUserRoleAssigmentPayload
 	if err := utils.ParseJSON(r, &roleAssigment); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
 	if err := utils.Validate.Struct(roleAssigment); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrInvalidaPayload(validationErrors.Error()))
+		utils.WriteError(w, utils.ValidationError(err))
 		return
 	}
 
 	userId, err := middlewares.GetUserIDFromContext(r.Context())
 
 	if err != nil {
-		utils.WriteError(w, http.StatusUnauthorized, errors.ErrJWTInvalidToken)
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
 		return
 	}
 
 	vars := mux.Vars(r)
 	email, ok := vars["email"]
 	if !ok {
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrUserNotFound)
+		utils.WriteError(w, errors.ErrUserNotFound)
 		return
 	}
 
 	err = h.service.CreateRoleAssigment(roleAssigment, email, userId)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, map[string]string{
-		"message": "Role assigned successfully",
-	})
+	utils.WriteMessage(w, http.StatusCreated, "Role assigned successfully")
 }
 
 func (h *Handler) handleDeleteRoleAssigment(w http.ResponseWriter, r *http.Request) {
 
 	var roleAssigment DeleteUserRoleAssigmentPayload
 	if err := utils.ParseJSON(r, &roleAssigment); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
 	if err := utils.Validate.Struct(roleAssigment); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrInvalidaPayload(validationErrors.Error()))
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
 		return
 	}
 
 	vars := mux.Vars(r)
 	email, ok := vars["email"]
 	if !ok {
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrUserNotFound)
+		utils.WriteError(w, errors.ErrUserNotFound)
+		return
+	}
+
+	err = h.service.DeleteRoleAssigment(roleAssigment, email, userId)
+	if err != nil {
+		utils.WriteError(w, err)
 		return
 	}
 
-	err := h.service.DeleteRoleAssigment(roleAssigment, email)
+	utils.WriteMessage(w, http.StatusCreated, "Role assigned deleted")
+}
+
+func (h *Handler) handleRenewRoleAssigment(w http.ResponseWriter, r *http.Request) {
+
+	var roleAssigment RenewUserRoleAssigmentPayload
+	if err := utils.ParseJSON(r, &roleAssigment); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(roleAssigment); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	vars := mux.Vars(r)
+	email, ok := vars["email"]
+	if !ok {
+		utils.WriteError(w, errors.ErrUserNotFound)
+		return
+	}
+
+	err = h.service.RenewRoleAssigment(roleAssigment, email, userId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Role assigment renewed successfully")
+}
+
+func (h *Handler) handleGetExpiringRoleAssigments(w http.ResponseWriter, r *http.Request) {
+
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			utils.WriteError(w, errors.ErrInvalidaPayload("days must be a positive integer"))
+			return
+		}
+		days = parsed
+	}
+
+	expirations, err := h.service.GetExpiringRoleAssigments(days)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, map[string]string{
-		"message": "Role assigned deleted",
-	})
+	utils.WriteJSON(w, http.StatusOK, expirations)
 }
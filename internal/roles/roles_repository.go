@@ -2,8 +2,10 @@ package roles
 
 import (
 	"database/sql"
+	"fmt"
 	"time"
 	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/lib/pq"
 )
 
 // Postgres SQL Repository
@@ -66,7 +68,17 @@ func (s *SQLRepository) GetRoles() ([]Role, error) {
 
 func (s *SQLRepository) GetUserRoles(userId []uint8)([]RoleAssigment, error){
 
-	rows, err := s.db.Query("SELECT r.role_id, r.role_name, r.description, ur.valid_until, ur.created_by FROM auth.user_role ur JOIN auth.\"role\" r ON ur.role_id = r.role_id WHERE ur.user_id = $1", userId)
+	rows, err := s.db.Query(
+		`SELECT r.role_id, r.role_name, r.description, ur.valid_until, ur.created_by,
+			COALESCE((
+				SELECT array_agg(z.zone_name ORDER BY z.zone_name)
+				FROM auth.user_role_zone urz JOIN treesense."zone" z ON urz.zone_id = z.zone_id
+				WHERE urz.user_id = ur.user_id AND urz.role_id = ur.role_id
+			), '{}')
+		FROM auth.user_role ur JOIN auth."role" r ON ur.role_id = r.role_id
+		WHERE ur.user_id = $1 AND ur.valid_until > CURRENT_TIMESTAMP`,
+		userId,
+	)
 
 	if err != nil {
 		return nil, errors.ErrReadingRole(err.Error())
@@ -109,11 +121,12 @@ func (s *SQLRepository) GetRoleByName(roleName string) (*Role, error) {
 	return role, nil
 }
 
-/// Assigments /// 
-func (s *SQLRepository) CreateRoleAssigment(userId []uint8, roleId []uint8, by []uint8, valid_until time.Time) error {
+func (s *SQLRepository) UpdateRole(roleId []uint8, roleName string, description string) error {
+
+	// Renaming cascades to auth.role_permission through its foreign key
 	_, err := s.db.Exec(
-		"INSERT INTO auth.\"user_role\" (user_id, role_id, created_by, updated_by, valid_until) VALUES ($1, $2, $3, $3, $4)",
-		userId, roleId, by, valid_until,
+		"UPDATE auth.\"role\" SET role_name = $2, description = $3, updated_at = CURRENT_TIMESTAMP WHERE role_id = $1",
+		roleId, roleName, description,
 	)
 	if err != nil {
 		return errors.ErrCantUploadRole(err.Error())
@@ -122,20 +135,488 @@ func (s *SQLRepository) CreateRoleAssigment(userId []uint8, roleId []uint8, by [
 	return nil
 }
 
-func (s *SQLRepository) DeleteRoleAssigment(userId []uint8, roleId []uint8) error {
+// DeleteRole removes a role nobody holds anymore, along with its permissions. Lapsed assignments are archived first.
+func (s *SQLRepository) DeleteRole(roleId []uint8) error {
 
-	_, err := s.db.Exec(
-		"DELETE FROM auth.\"user_role\" WHERE user_id=$1 AND role_id=$2",
-		userId, roleId,
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantDeleteRole(err.Error())
+	}
+	defer tx.Rollback()
+
+	// Locking the role blocks new assignments until the transaction ends
+	var roleName string
+	err = tx.QueryRow("SELECT role_name FROM auth.\"role\" WHERE role_id = $1 FOR UPDATE", roleId).Scan(&roleName)
+	if err == sql.ErrNoRows {
+		return errors.ErrRoleNotFound
+	}
+	if err != nil {
+		return errors.ErrCantDeleteRole(err.Error())
+	}
+
+	var assigned bool
+	err = tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM auth.user_role WHERE role_id = $1 AND valid_until > CURRENT_TIMESTAMP)",
+		roleId,
+	).Scan(&assigned)
+	if err != nil {
+		return errors.ErrCantDeleteRole(err.Error())
+	}
+	if assigned {
+		return errors.ErrRoleInUse
+	}
+
+	if _, err := archiveRoleAssigments(tx, "ur.role_id = $1", "EXPIRED", nil, roleId); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM auth.role_permission WHERE role_name = $1", roleName); err != nil {
+		return errors.ErrCantDeleteRole(err.Error())
+	}
+
+	if _, err := tx.Exec("DELETE FROM auth.\"role\" WHERE role_id = $1", roleId); err != nil {
+		return errors.ErrCantDeleteRole(err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantDeleteRole(err.Error())
+	}
+
+	return nil
+}
+
+/// Permissions ///
+
+func (s *SQLRepository) GetPermissions() ([]Permission, error) {
+
+	rows, err := s.db.Query("SELECT permission_name, COALESCE(description, '') FROM auth.permission ORDER BY permission_name")
+	if err != nil {
+		return nil, errors.ErrReadingPermission(err.Error())
+	}
+	defer rows.Close()
+
+	permissions := []Permission{}
+	for rows.Next() {
+		var permission Permission
+		if err := rows.Scan(&permission.PermissionName, &permission.Description); err != nil {
+			return nil, errors.ErrPermissionScan(err.Error())
+		}
+		permissions = append(permissions, permission)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrPermissionScan(err.Error())
+	}
+
+	return permissions, nil
+}
+
+func (s *SQLRepository) GetPermissionByName(permissionName string) (*Permission, error) {
+
+	permission := new(Permission)
+	err := s.db.QueryRow(
+		"SELECT permission_name, COALESCE(description, '') FROM auth.permission WHERE permission_name = $1",
+		permissionName,
+	).Scan(&permission.PermissionName, &permission.Description)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrPermissionNotFound
+	}
+	if err != nil {
+		return nil, errors.ErrPermissionScan(err.Error())
+	}
+
+	return permission, nil
+}
+
+// GetRolePermissions lists every role with the permissions it grants, roles without any included
+func (s *SQLRepository) GetRolePermissions() ([]RolePermissions, error) {
+
+	rows, err := s.db.Query(
+		`SELECT r.role_name, COALESCE(r.description, ''), rp.permission_name
+		FROM auth."role" r
+		LEFT JOIN auth.role_permission rp ON rp.role_name = r.role_name
+		ORDER BY r.role_name, rp.permission_name`,
+	)
+	if err != nil {
+		return nil, errors.ErrReadingRole(err.Error())
+	}
+	defer rows.Close()
+
+	roles := []RolePermissions{}
+	for rows.Next() {
+		var roleName, description string
+		var permissionName sql.NullString
+		if err := rows.Scan(&roleName, &description, &permissionName); err != nil {
+			return nil, errors.ErrRoleScan(err.Error())
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1].RoleName != roleName {
+			roles = append(roles, RolePermissions{RoleName: roleName, RoleDescription: description, Permissions: []string{}})
+		}
+		if permissionName.Valid {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permissionName.String)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrRoleScan(err.Error())
+	}
+
+	return roles, nil
+}
+
+func (s *SQLRepository) GetRolePermissionNames(roleName string) ([]string, error) {
+	return s.queryNames("SELECT permission_name FROM auth.role_permission WHERE role_name = $1 ORDER BY permission_name", roleName)
+}
+
+// GetUserPermissionZones maps each permission the user's active assignments grant to the names of the zones it is limited to.
+// A permission granted by any assignment without zones maps to nil, meaning it applies everywhere.
+func (s *SQLRepository) GetUserPermissionZones(userId []uint8) (map[string][]string, error) {
+
+	rows, err := s.db.Query(
+		`SELECT rp.permission_name, z.zone_name
+		FROM auth.user_role ur
+		JOIN auth."role" r ON ur.role_id = r.role_id
+		JOIN auth.role_permission rp ON rp.role_name = r.role_name
+		LEFT JOIN auth.user_role_zone urz ON urz.user_id = ur.user_id AND urz.role_id = ur.role_id
+		LEFT JOIN treesense."zone" z ON z.zone_id = urz.zone_id
+		WHERE ur.user_id = $1 AND ur.valid_until > CURRENT_TIMESTAMP`,
+		userId,
 	)
+	if err != nil {
+		return nil, errors.ErrReadingPermission(err.Error())
+	}
+	defer rows.Close()
+
+	zones := map[string][]string{}
+	everywhere := map[string]bool{}
+	for rows.Next() {
+		var permissionName string
+		var zoneName sql.NullString
+		if err := rows.Scan(&permissionName, &zoneName); err != nil {
+			return nil, errors.ErrPermissionScan(err.Error())
+		}
+
+		if !zoneName.Valid {
+			everywhere[permissionName] = true
+			continue
+		}
+		zones[permissionName] = append(zones[permissionName], zoneName.String)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrPermissionScan(err.Error())
+	}
+
+	for permissionName := range everywhere {
+		zones[permissionName] = nil
+	}
+
+	return zones, nil
+}
+
+// GrantPermission reports false if the role already had the permission
+func (s *SQLRepository) GrantPermission(roleName string, permissionName string) (bool, error) {
+
+	result, err := s.db.Exec(
+		"INSERT INTO auth.role_permission (role_name, permission_name) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		roleName, permissionName,
+	)
+	if err != nil {
+		return false, errors.ErrCantUploadRole(err.Error())
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.ErrCantUploadRole(err.Error())
+	}
+
+	return rows == 1, nil
+}
+
+// RevokePermission reports false if the role didn't have the permission
+func (s *SQLRepository) RevokePermission(roleName string, permissionName string) (bool, error) {
+
+	result, err := s.db.Exec(
+		"DELETE FROM auth.role_permission WHERE role_name = $1 AND permission_name = $2",
+		roleName, permissionName,
+	)
+	if err != nil {
+		return false, errors.ErrCantUploadRole(err.Error())
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.ErrCantUploadRole(err.Error())
+	}
+
+	return rows == 1, nil
+}
+
+/// Assigments /// 
+// CreateRoleAssigment limits the assignment to the zones, by name, when there are any
+func (s *SQLRepository) CreateRoleAssigment(userId []uint8, roleId []uint8, by []uint8, valid_until time.Time, zoneNames []string) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadRole(err.Error())
+	}
+	defer tx.Rollback()
+
+	// A lapsed assignment the sweeper hasn't archived yet would block the new one
+	_, err = archiveRoleAssigments(tx, "ur.user_id = $1 AND ur.role_id = $2 AND ur.valid_until <= CURRENT_TIMESTAMP", "EXPIRED", nil, userId, roleId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO auth.\"user_role\" (user_id, role_id, created_by, updated_by, valid_until) VALUES ($1, $2, $3, $3, $4)",
+		userId, roleId, by, valid_until.UTC(),
+	)
+	if err != nil {
+		return errors.ErrCantUploadRole(err.Error())
+	}
+
+	if len(zoneNames) > 0 {
+		result, err := tx.Exec(
+			`INSERT INTO auth.user_role_zone (user_id, role_id, zone_id)
+			SELECT $1, $2, zone_id FROM treesense."zone" WHERE zone_name = ANY($3)`,
+			userId, roleId, pq.Array(zoneNames),
+		)
+		if err != nil {
+			return errors.ErrCantUploadRole(err.Error())
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return errors.ErrCantUploadRole(err.Error())
+		}
+		if rows != int64(len(zoneNames)) {
+			return errors.ErrZoneNotFound
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadRole(err.Error())
+	}
+
+	return nil
+}
+
+// DeleteRoleAssigment revokes the assignment, keeping it in the history.
+// keepLastHolder refuses to revoke the role from the last user holding it, with ErrLastAdmin.
+func (s *SQLRepository) DeleteRoleAssigment(userId []uint8, roleId []uint8, by []uint8, keepLastHolder bool) error {
+
+	tx, err := s.db.Begin()
 	if err != nil {
 		return errors.ErrCantDeleteRole(err.Error())
 	}
+	defer tx.Rollback()
+
+	if keepLastHolder {
+		// Locking the holders makes concurrent revokes wait and count again, so two of them can't both pass
+		holders, err := countRoleHoldersForUpdate(tx, roleId)
+		if err != nil {
+			return err
+		}
+		if holders <= 1 {
+			return errors.ErrLastAdmin
+		}
+	}
+
+	_, err = archiveRoleAssigments(tx, "ur.user_id = $1 AND ur.role_id = $2", "REVOKED", by, userId, roleId)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantDeleteRole(err.Error())
+	}
 
 	return nil
 }
 
+// RenewRoleAssigment moves valid_until of an active assignment, reporting false if there is none
+func (s *SQLRepository) RenewRoleAssigment(userId []uint8, roleId []uint8, by []uint8, validUntil time.Time) (bool, error) {
+
+	result, err := s.db.Exec(
+		`UPDATE auth.user_role SET valid_until = $4, updated_by = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND role_id = $2 AND valid_until > CURRENT_TIMESTAMP`,
+		userId, roleId, by, validUntil.UTC(),
+	)
+	if err != nil {
+		return false, errors.ErrCantUploadRole(err.Error())
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.ErrCantUploadRole(err.Error())
+	}
+
+	return rows == 1, nil
+}
+
+// GetExpiringRoleAssigments lists the active assignments that lapse before the given time, soonest first
+func (s *SQLRepository) GetExpiringRoleAssigments(before time.Time) ([]RoleExpiration, error) {
+
+	rows, err := s.db.Query(
+		`SELECT u.user_id, u.user_name, u.email, r.role_name, ur.valid_until
+		FROM auth.user_role ur
+		JOIN auth."user" u ON ur.user_id = u.user_id
+		JOIN auth."role" r ON ur.role_id = r.role_id
+		WHERE ur.valid_until > CURRENT_TIMESTAMP AND ur.valid_until <= $1
+		ORDER BY ur.valid_until, u.email`,
+		before.UTC(),
+	)
+	if err != nil {
+		return nil, errors.ErrReadingRole(err.Error())
+	}
+	defer rows.Close()
+
+	expirations := []RoleExpiration{}
+	for rows.Next() {
+		var expiration RoleExpiration
+		err := rows.Scan(&expiration.UserId, &expiration.UserName, &expiration.Email, &expiration.RoleName, &expiration.ValidUntil)
+		if err != nil {
+			return nil, errors.ErrRoleScan(err.Error())
+		}
+		expirations = append(expirations, expiration)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrRoleScan(err.Error())
+	}
+
+	return expirations, nil
+}
+
+// ExtendLastRoleAssigment keeps the role from lapsing: when no assignment of it lasts beyond horizon,
+// the one that lasts the longest is moved to validUntil. It reports whether one was extended.
+func (s *SQLRepository) ExtendLastRoleAssigment(roleId []uint8, horizon time.Time, validUntil time.Time) (bool, error) {
+
+	result, err := s.db.Exec(
+		`UPDATE auth.user_role SET valid_until = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE (user_id, role_id) = (
+			SELECT user_id, role_id FROM auth.user_role WHERE role_id = $1 ORDER BY valid_until DESC LIMIT 1
+		)
+		AND NOT EXISTS (SELECT 1 FROM auth.user_role WHERE role_id = $1 AND valid_until > $2)`,
+		roleId, horizon.UTC(), validUntil.UTC(),
+	)
+	if err != nil {
+		return false, errors.ErrCantUploadRole(err.Error())
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.ErrCantUploadRole(err.Error())
+	}
+
+	return rows == 1, nil
+}
+
+// ArchiveExpiredRoleAssigments moves every lapsed assignment to the history, returning how many there were
+func (s *SQLRepository) ArchiveExpiredRoleAssigments() (int64, error) {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, errors.ErrCantDeleteRole(err.Error())
+	}
+	defer tx.Rollback()
+
+	archived, err := archiveRoleAssigments(tx, "ur.valid_until <= CURRENT_TIMESTAMP", "EXPIRED", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.ErrCantDeleteRole(err.Error())
+	}
+
+	return archived, nil
+}
+
 /// Aux Function ///
+
+// archiveRoleAssigments copies the assignments matching condition to the history and deletes them.
+// The condition's placeholders start at $1 and take args; the reason and ended_by are appended after them.
+func archiveRoleAssigments(tx *sql.Tx, condition string, reason string, by []uint8, args ...interface{}) (int64, error) {
+
+	reasonArg := fmt.Sprintf("$%d", len(args)+1)
+	byArg := fmt.Sprintf("$%d", len(args)+2)
+
+	_, err := tx.Exec(
+		`INSERT INTO auth.user_role_history (user_id, role_id, role_name, created_at, created_by, valid_until, end_reason, ended_by)
+		SELECT ur.user_id, ur.role_id, r.role_name, ur.created_at, ur.created_by, ur.valid_until, `+reasonArg+`, `+byArg+`
+		FROM auth.user_role ur JOIN auth.role r ON ur.role_id = r.role_id WHERE `+condition,
+		append(args, reason, by)...,
+	)
+	if err != nil {
+		return 0, errors.ErrCantDeleteRole(err.Error())
+	}
+
+	result, err := tx.Exec("DELETE FROM auth.user_role ur WHERE "+condition, args...)
+	if err != nil {
+		return 0, errors.ErrCantDeleteRole(err.Error())
+	}
+
+	archived, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.ErrCantDeleteRole(err.Error())
+	}
+
+	return archived, nil
+}
+
+// countRoleHoldersForUpdate counts the users with an active assignment of the role, locking those assignments
+func countRoleHoldersForUpdate(tx *sql.Tx, roleId []uint8) (int, error) {
+
+	rows, err := tx.Query(
+		"SELECT user_id FROM auth.user_role WHERE role_id = $1 AND valid_until > CURRENT_TIMESTAMP ORDER BY user_id FOR UPDATE",
+		roleId,
+	)
+	if err != nil {
+		return 0, errors.ErrReadingRole(err.Error())
+	}
+	defer rows.Close()
+
+	holders := 0
+	for rows.Next() {
+		holders++
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, errors.ErrReadingRole(err.Error())
+	}
+
+	return holders, nil
+}
+
+func (s *SQLRepository) queryNames(query string, args ...interface{}) ([]string, error) {
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.ErrReadingPermission(err.Error())
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, errors.ErrPermissionScan(err.Error())
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrPermissionScan(err.Error())
+	}
+
+	return names, nil
+}
+
 func scanRowIntoRole(row scannable) (*Role, error) {
 
 	role := new(Role)
@@ -165,6 +646,7 @@ func scanRowIntoRoleAssigment(row scannable) (*RoleAssigment, error) {
 		&roleAssigment.RoleDescription,
 		&roleAssigment.ValidUntil,
 		&roleAssigment.AssignedBy,
+		pq.Array(&roleAssigment.Zones),
 	)
 
 	if err != nil {
@@ -1,6 +1,10 @@
 package roles
 
 import (
+	"log"
+	"slices"
+	"time"
+
 	"github.com/PabloPei/TreeSense-Backend/internal/errors"
 	"github.com/PabloPei/TreeSense-Backend/internal/users"
 )
@@ -38,6 +42,120 @@ func (s *Service) GetRoles() ([]Role, error) {
 
 }
 
+func (s *Service) UpdateRole(payload UpdateRolePayload) error {
+
+	role, err := s.repository.GetRoleByName(payload.RoleName)
+	if err != nil {
+		return errors.ErrRoleNotFound
+	}
+
+	roleName := role.RoleName
+	if payload.NewRoleName != "" && payload.NewRoleName != role.RoleName {
+		// The last admin protection finds the role by its name
+		if role.RoleName == AdminRole {
+			return errors.ErrAdminRoleRename
+		}
+		if _, err := s.repository.GetRoleByName(payload.NewRoleName); err == nil {
+			return errors.ErrRoleAlreadyExist(payload.NewRoleName)
+		}
+		roleName = payload.NewRoleName
+	}
+
+	description := role.RoleDescription
+	if payload.RoleDescription != "" {
+		description = payload.RoleDescription
+	}
+
+	return s.repository.UpdateRole(role.RoleId, roleName, description)
+}
+
+// DeleteRole refuses roles that are still assigned, so nobody loses access without an explicit revoke
+func (s *Service) DeleteRole(payload DeleteRolePayload) error {
+
+	role, err := s.repository.GetRoleByName(payload.RoleName)
+	if err != nil {
+		return errors.ErrRoleNotFound
+	}
+
+	if role.RoleName == AdminRole {
+		return errors.ErrAdminRoleDelete
+	}
+
+	return s.repository.DeleteRole(role.RoleId)
+}
+
+/// Permissions ///
+
+func (s *Service) GetPermissionMatrix() (*PermissionMatrix, error) {
+
+	permissions, err := s.repository.GetPermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.repository.GetRolePermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	return &PermissionMatrix{Permissions: permissions, Roles: roles}, nil
+}
+
+// GrantPermission only hands out permissions the granter holds, so CONFIG alone can't add MANAGE to the granter's own role
+func (s *Service) GrantPermission(payload RolePermissionPayload, by []uint8) error {
+
+	role, permission, err := s.getRoleAndPermission(payload)
+	if err != nil {
+		return err
+	}
+
+	// the role carries the permission into every zone its holders work in, so a zone limited grant isn't enough
+	ownZones, err := s.repository.GetUserPermissionZones(by)
+	if err != nil {
+		return err
+	}
+	grantedZones, ok := ownZones[permission.PermissionName]
+	if !ok {
+		return errors.ErrPermissionEscalation(permission.PermissionName)
+	}
+	if grantedZones != nil {
+		return errors.ErrPermissionZoneEscalation(permission.PermissionName)
+	}
+
+	granted, err := s.repository.GrantPermission(role.RoleName, permission.PermissionName)
+	if err != nil {
+		return err
+	}
+	if !granted {
+		return errors.ErrRolePermissionExist
+	}
+
+	return nil
+}
+
+// RevokePermission leaves ADMIN alone: without MANAGE or CONFIG nobody could assign roles or fix the matrix again
+func (s *Service) RevokePermission(payload RolePermissionPayload) error {
+
+	role, permission, err := s.getRoleAndPermission(payload)
+	if err != nil {
+		return err
+	}
+
+	if role.RoleName == AdminRole {
+		return errors.ErrAdminRolePermission
+	}
+
+	revoked, err := s.repository.RevokePermission(role.RoleName, permission.PermissionName)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return errors.ErrRolePermissionNotExist
+	}
+
+	return nil
+}
+
 func (s *Service) GetUserRoles(email string) ([]RoleAssigment, error) {
 
 	user, err := s.userRepository.GetUserByEmail(email)
@@ -82,6 +200,10 @@ func (s *Service) UserHasRole(roleName string, userId []uint8)(bool, error){
 
 func (s *Service) CreateRoleAssigment(payload CreateUserRoleAssigmentPayload, email string, by []uint8) error {
 
+	if !payload.ValidUntil.After(time.Now()) {
+		return errors.ErrRoleValidUntilInPast
+	}
+
 	role, err := s.repository.GetRoleByName(payload.RoleName)
 
 	if err != nil {
@@ -93,17 +215,24 @@ func (s *Service) CreateRoleAssigment(payload CreateUserRoleAssigmentPayload, em
 		return errors.ErrUserNotFound
 	}
 
+	if err := s.checkEscalation(role, payload.Zones, by); err != nil {
+		return err
+	}
+
 	userRoles, err := s.repository.GetUserRoles(user.UserId)
+	if err != nil {
+		return err
+	}
 
 	for _, userRole := range userRoles {
 		if userRole.RoleName == role.RoleName {
 			return errors.ErrRoleAssigmentExist
 		}
 	}
-	return s.repository.CreateRoleAssigment(user.UserId, role.RoleId, by, payload.ValidUntil)
+	return s.repository.CreateRoleAssigment(user.UserId, role.RoleId, by, payload.ValidUntil, payload.Zones)
 }
 
-func (s *Service) DeleteRoleAssigment(payload DeleteUserRoleAssigmentPayload, email string) error {
+func (s *Service) DeleteRoleAssigment(payload DeleteUserRoleAssigmentPayload, email string, by []uint8) error {
 
 	role, err := s.repository.GetRoleByName(payload.RoleName)
 
@@ -116,19 +245,186 @@ func (s *Service) DeleteRoleAssigment(payload DeleteUserRoleAssigmentPayload, em
 		return errors.ErrUserNotFound
 	}
 
-	userRoles, err := s.repository.GetUserRoles(user.UserId)
+	assigment, err := s.getRoleAssigment(user.UserId, role.RoleName)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkEscalation(role, assigment.Zones, by); err != nil {
+		return err
+	}
+
+	return s.repository.DeleteRoleAssigment(user.UserId, role.RoleId, by, role.RoleName == AdminRole)
+
+}
+
+func (s *Service) RenewRoleAssigment(payload RenewUserRoleAssigmentPayload, email string, by []uint8) error {
+
+	if !payload.ValidUntil.After(time.Now()) {
+		return errors.ErrRoleValidUntilInPast
+	}
+
+	role, err := s.repository.GetRoleByName(payload.RoleName)
+	if err != nil {
+		return errors.ErrRoleNotFound
+	}
+
+	user, err := s.userRepository.GetUserByEmail(email)
+	if err != nil {
+		return errors.ErrUserNotFound
+	}
+
+	assigment, err := s.getRoleAssigment(user.UserId, role.RoleName)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkEscalation(role, assigment.Zones, by); err != nil {
+		return err
+	}
+
+	renewed, err := s.repository.RenewRoleAssigment(user.UserId, role.RoleId, by, payload.ValidUntil)
+	if err != nil {
+		return err
+	}
+	if !renewed {
+		return errors.ErrRoleAssigmentNotExist
+	}
+
+	return nil
+}
+
+// GetExpiringRoleAssigments lists the assignments that lapse within the given number of days
+func (s *Service) GetExpiringRoleAssigments(days int) ([]RoleExpiration, error) {
+	return s.repository.GetExpiringRoleAssigments(time.Now().AddDate(0, 0, days))
+}
+
+// RunExpirySweeper archives lapsed assignments every interval. It blocks, so run it in its own goroutine.
+// The last ADMIN assignment is extended instead, so the system is never left without an admin.
+func (s *Service) RunExpirySweeper(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.keepLastAdmin(interval); err != nil {
+			log.Println(err)
+		}
+
+		archived, err := s.repository.ArchiveExpiredRoleAssigments()
+		if err != nil {
+			log.Println(err)
+		} else if archived > 0 {
+			log.Printf("Archived %d expired role assigments", archived)
+		}
+
+		<-ticker.C
+	}
+}
+
+/// Aux Functions ///
+
+// keepLastAdmin extends the last ADMIN assignment past the next sweep when every ADMIN assignment lapses before it
+func (s *Service) keepLastAdmin(interval time.Duration) error {
+
+	role, err := s.repository.GetRoleByName(AdminRole)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	extended, err := s.repository.ExtendLastRoleAssigment(role.RoleId, now.Add(interval), now.Add(2*interval))
+	if err != nil {
+		return err
+	}
+	if extended {
+		log.Printf("Extended the last %s role assigment until %s", AdminRole, now.Add(2*interval).Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func (s *Service) getRoleAndPermission(payload RolePermissionPayload) (*Role, *Permission, error) {
+
+	role, err := s.repository.GetRoleByName(payload.RoleName)
+	if err != nil {
+		return nil, nil, errors.ErrRoleNotFound
+	}
+
+	permission, err := s.repository.GetPermissionByName(payload.PermissionName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return role, permission, nil
+}
+
+// getRoleAssigment finds the user's active assignment of the role
+func (s *Service) getRoleAssigment(userId []uint8, roleName string) (*RoleAssigment, error) {
+
+	userRoles, err := s.repository.GetUserRoles(userId)
+	if err != nil {
+		return nil, err
+	}
 
-	roleAssigned := false
 	for _, userRole := range userRoles {
-		if userRole.RoleName == role.RoleName {
-			roleAssigned = true
-			break
+		if userRole.RoleName == roleName {
+			return &userRole, nil
 		}
 	}
-	if !roleAssigned {
-		return errors.ErrRoleAssigmentNotExist
+
+	return nil, errors.ErrRoleAssigmentNotExist
+}
+
+// checkEscalation only lets a user grant, renew or revoke roles whose permissions they hold themselves,
+// and only within the zones they hold them in. zones are the names the assignment is limited to, empty for everywhere.
+func (s *Service) checkEscalation(role *Role, zones []string, by []uint8) error {
+
+	rolePermissions, err := s.repository.GetRolePermissionNames(role.RoleName)
+	if err != nil {
+		return err
+	}
+
+	ownZones, err := s.repository.GetUserPermissionZones(by)
+	if err != nil {
+		return err
+	}
+
+	missing := []string{}
+	outside := []string{}
+	for _, permission := range rolePermissions {
+		grantedZones, ok := ownZones[permission]
+		if !ok {
+			missing = append(missing, permission)
+			continue
+		}
+		if grantedZones != nil && !coversZones(grantedZones, zones) {
+			outside = append(outside, permission)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.ErrRoleEscalation(missing)
+	}
+	if len(outside) > 0 {
+		return errors.ErrRoleZoneEscalation(outside)
+	}
+
+	return nil
+}
+
+// coversZones tells whether every zone is among the granted ones. No zones means everywhere, which no zone list covers.
+func coversZones(granted []string, zones []string) bool {
+
+	if len(zones) == 0 {
+		return false
 	}
 
-	return s.repository.DeleteRoleAssigment(user.UserId, role.RoleId)
+	for _, zone := range zones {
+		if !slices.Contains(granted, zone) {
+			return false
+		}
+	}
 
-}
\ No newline at end of file
+	return true
+}
@@ -0,0 +1,432 @@
+package roles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/users"
+)
+
+// fakeRoleRepository keeps roles and assignments in maps. Users are identified by their email, which is also their id.
+// Methods the service under test doesn't call are left to the embedded nil interface.
+type fakeRoleRepository struct {
+	RoleRepository
+	permissions map[string][]string            // role name -> permissions
+	assignments map[string]map[string]bool     // user id -> role names
+	zones       map[string]map[string][]string // user id -> role name -> zone names, none for everywhere
+}
+
+func newFakeRoleRepository(assignments map[string][]string) *fakeRoleRepository {
+
+	repository := &fakeRoleRepository{
+		permissions: map[string][]string{
+			"ADMIN":       {"CONFIG", "DELETE", "EDIT", "MANAGE", "READ", "SURVEY"},
+			"MANAGER":     {"MANAGE", "READ"},
+			"EDITOR":      {"EDIT", "READ"},
+			"VIEWER":      {"READ"},
+			"FIELD AGENT": {"SURVEY"},
+		},
+		assignments: map[string]map[string]bool{},
+		zones:       map[string]map[string][]string{},
+	}
+
+	for user, roleNames := range assignments {
+		repository.assignments[user] = map[string]bool{}
+		for _, roleName := range roleNames {
+			repository.assignments[user][roleName] = true
+		}
+	}
+
+	return repository
+}
+
+func (f *fakeRoleRepository) GetRoleByName(roleName string) (*Role, error) {
+	if _, ok := f.permissions[roleName]; !ok {
+		return nil, errors.ErrRoleNotFound
+	}
+	return &Role{RoleId: []uint8(roleName), RoleName: roleName}, nil
+}
+
+func (f *fakeRoleRepository) GetUserRoles(userId []uint8) ([]RoleAssigment, error) {
+	assigments := []RoleAssigment{}
+	for roleName := range f.assignments[string(userId)] {
+		assigments = append(assigments, RoleAssigment{RoleId: []uint8(roleName), RoleName: roleName, Zones: f.zones[string(userId)][roleName]})
+	}
+	return assigments, nil
+}
+
+func (f *fakeRoleRepository) GetRolePermissionNames(roleName string) ([]string, error) {
+	return f.permissions[roleName], nil
+}
+
+func (f *fakeRoleRepository) GetUserPermissionZones(userId []uint8) (map[string][]string, error) {
+	zones := map[string][]string{}
+	everywhere := map[string]bool{}
+	for roleName := range f.assignments[string(userId)] {
+		roleZones := f.zones[string(userId)][roleName]
+		for _, permission := range f.permissions[roleName] {
+			if len(roleZones) == 0 {
+				everywhere[permission] = true
+				continue
+			}
+			zones[permission] = append(zones[permission], roleZones...)
+		}
+	}
+	for permission := range everywhere {
+		zones[permission] = nil
+	}
+	return zones, nil
+}
+
+func (f *fakeRoleRepository) countRoleHolders(roleId []uint8) int {
+	holders := 0
+	for _, roleNames := range f.assignments {
+		if roleNames[string(roleId)] {
+			holders++
+		}
+	}
+	return holders
+}
+
+func (f *fakeRoleRepository) CreateRoleAssigment(userId []uint8, roleId []uint8, by []uint8, validUntil time.Time, zoneNames []string) error {
+	if f.assignments[string(userId)] == nil {
+		f.assignments[string(userId)] = map[string]bool{}
+	}
+	f.assignments[string(userId)][string(roleId)] = true
+	if len(zoneNames) > 0 {
+		if f.zones[string(userId)] == nil {
+			f.zones[string(userId)] = map[string][]string{}
+		}
+		f.zones[string(userId)][string(roleId)] = zoneNames
+	}
+	return nil
+}
+
+func (f *fakeRoleRepository) DeleteRoleAssigment(userId []uint8, roleId []uint8, by []uint8, keepLastHolder bool) error {
+	if keepLastHolder && f.countRoleHolders(roleId) <= 1 {
+		return errors.ErrLastAdmin
+	}
+	delete(f.assignments[string(userId)], string(roleId))
+	return nil
+}
+
+func (f *fakeRoleRepository) RenewRoleAssigment(userId []uint8, roleId []uint8, by []uint8, validUntil time.Time) (bool, error) {
+	return f.assignments[string(userId)][string(roleId)], nil
+}
+
+func (f *fakeRoleRepository) GetPermissionByName(permissionName string) (*Permission, error) {
+	for _, permissions := range f.permissions {
+		for _, permission := range permissions {
+			if permission == permissionName {
+				return &Permission{PermissionName: permissionName}, nil
+			}
+		}
+	}
+	return nil, errors.ErrPermissionNotFound
+}
+
+func (f *fakeRoleRepository) GrantPermission(roleName string, permissionName string) (bool, error) {
+	for _, permission := range f.permissions[roleName] {
+		if permission == permissionName {
+			return false, nil
+		}
+	}
+	f.permissions[roleName] = append(f.permissions[roleName], permissionName)
+	return true, nil
+}
+
+func (f *fakeRoleRepository) RevokePermission(roleName string, permissionName string) (bool, error) {
+	for i, permission := range f.permissions[roleName] {
+		if permission == permissionName {
+			f.permissions[roleName] = append(f.permissions[roleName][:i:i], f.permissions[roleName][i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeUserRepository knows every email listed in it
+type fakeUserRepository struct {
+	users.UserRepository
+	emails []string
+}
+
+func (f *fakeUserRepository) GetUserByEmail(email string) (*users.User, error) {
+	for _, known := range f.emails {
+		if known == email {
+			return &users.User{UserId: []uint8(email), Email: email}, nil
+		}
+	}
+	return nil, errors.ErrUserNotFound
+}
+
+var testEmails = []string{"admin@treesense.com", "admin2@treesense.com", "manager@treesense.com", "agent@treesense.com"}
+
+func newTestService(assignments map[string][]string) (*Service, *fakeRoleRepository) {
+	repository := newFakeRoleRepository(assignments)
+	return NewService(repository, &fakeUserRepository{emails: testEmails}), repository
+}
+
+// assertErrorCode fails unless err has the code of want, or both are nil
+func assertErrorCode(t *testing.T, err error, want error) {
+	t.Helper()
+
+	if want == nil {
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return
+	}
+
+	if err == nil {
+		t.Fatalf("expected %s, got no error", errors.AsError(want).Code)
+	}
+	if got, expected := errors.AsError(err).Code, errors.AsError(want).Code; got != expected {
+		t.Fatalf("expected %s, got %s (%v)", expected, got, err)
+	}
+}
+
+func TestCreateRoleAssigment(t *testing.T) {
+
+	assignments := map[string][]string{
+		"admin@treesense.com":   {"ADMIN"},
+		"manager@treesense.com": {"MANAGER"},
+		"agent@treesense.com":   {"FIELD AGENT"},
+	}
+	future := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		by         string
+		email      string
+		roleName   string
+		validUntil time.Time
+		want       error
+	}{
+		{"manager grants a role within their permissions", "manager@treesense.com", "agent@treesense.com", "VIEWER", future, nil},
+		{"manager grants their own role", "manager@treesense.com", "agent@treesense.com", "MANAGER", future, nil},
+		{"manager can't grant a role with more permissions", "manager@treesense.com", "agent@treesense.com", "EDITOR", future, errors.ErrRoleEscalation(nil)},
+		{"manager can't grant themselves ADMIN", "manager@treesense.com", "manager@treesense.com", "ADMIN", future, errors.ErrRoleEscalation(nil)},
+		{"field agent can't grant themselves ADMIN", "agent@treesense.com", "agent@treesense.com", "ADMIN", future, errors.ErrRoleEscalation(nil)},
+		{"admin grants ADMIN", "admin@treesense.com", "agent@treesense.com", "ADMIN", future, nil},
+		{"role already assigned", "admin@treesense.com", "manager@treesense.com", "MANAGER", future, errors.ErrRoleAssigmentExist},
+		{"unknown role", "admin@treesense.com", "agent@treesense.com", "GARDENER", future, errors.ErrRoleNotFound},
+		{"unknown user", "admin@treesense.com", "nobody@treesense.com", "VIEWER", future, errors.ErrUserNotFound},
+		{"valid until in the past", "admin@treesense.com", "agent@treesense.com", "VIEWER", time.Now().Add(-time.Hour), errors.ErrRoleValidUntilInPast},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			service, repository := newTestService(assignments)
+			payload := CreateUserRoleAssigmentPayload{RoleName: test.roleName, ValidUntil: test.validUntil}
+
+			err := service.CreateRoleAssigment(payload, test.email, []uint8(test.by))
+			assertErrorCode(t, err, test.want)
+
+			if test.want == nil && !repository.assignments[test.email][test.roleName] {
+				t.Fatalf("expected %s to hold %s", test.email, test.roleName)
+			}
+		})
+	}
+}
+
+func TestDeleteRoleAssigment(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		assignments map[string][]string
+		by          string
+		email       string
+		roleName    string
+		want        error
+	}{
+		{
+			name:        "admin can't remove ADMIN from themselves as the last admin",
+			assignments: map[string][]string{"admin@treesense.com": {"ADMIN"}},
+			by:          "admin@treesense.com",
+			email:       "admin@treesense.com",
+			roleName:    "ADMIN",
+			want:        errors.ErrLastAdmin,
+		},
+		{
+			name:        "admin removes ADMIN while another admin remains",
+			assignments: map[string][]string{"admin@treesense.com": {"ADMIN"}, "admin2@treesense.com": {"ADMIN"}},
+			by:          "admin@treesense.com",
+			email:       "admin2@treesense.com",
+			roleName:    "ADMIN",
+			want:        nil,
+		},
+		{
+			name:        "manager can't remove ADMIN",
+			assignments: map[string][]string{"admin@treesense.com": {"ADMIN"}, "admin2@treesense.com": {"ADMIN"}, "manager@treesense.com": {"MANAGER"}},
+			by:          "manager@treesense.com",
+			email:       "admin2@treesense.com",
+			roleName:    "ADMIN",
+			want:        errors.ErrRoleEscalation(nil),
+		},
+		{
+			name:        "manager removes a role within their permissions",
+			assignments: map[string][]string{"manager@treesense.com": {"MANAGER"}, "agent@treesense.com": {"VIEWER"}},
+			by:          "manager@treesense.com",
+			email:       "agent@treesense.com",
+			roleName:    "VIEWER",
+			want:        nil,
+		},
+		{
+			name:        "role not assigned",
+			assignments: map[string][]string{"admin@treesense.com": {"ADMIN"}},
+			by:          "admin@treesense.com",
+			email:       "agent@treesense.com",
+			roleName:    "VIEWER",
+			want:        errors.ErrRoleAssigmentNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			service, repository := newTestService(test.assignments)
+			payload := DeleteUserRoleAssigmentPayload{RoleName: test.roleName}
+
+			err := service.DeleteRoleAssigment(payload, test.email, []uint8(test.by))
+			assertErrorCode(t, err, test.want)
+
+			if test.want == nil && repository.assignments[test.email][test.roleName] {
+				t.Fatalf("expected %s to no longer hold %s", test.email, test.roleName)
+			}
+		})
+	}
+}
+
+func TestRenewRoleAssigment(t *testing.T) {
+
+	assignments := map[string][]string{
+		"admin@treesense.com":   {"ADMIN"},
+		"manager@treesense.com": {"MANAGER"},
+		"agent@treesense.com":   {"EDITOR"},
+	}
+	future := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		by       string
+		email    string
+		roleName string
+		want     error
+	}{
+		{"admin renews any role", "admin@treesense.com", "agent@treesense.com", "EDITOR", nil},
+		{"manager can't renew a role with more permissions", "manager@treesense.com", "agent@treesense.com", "EDITOR", errors.ErrRoleEscalation(nil)},
+		{"role not assigned", "admin@treesense.com", "agent@treesense.com", "VIEWER", errors.ErrRoleAssigmentNotExist},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			service, _ := newTestService(assignments)
+			payload := RenewUserRoleAssigmentPayload{RoleName: test.roleName, ValidUntil: future}
+
+			err := service.RenewRoleAssigment(payload, test.email, []uint8(test.by))
+			assertErrorCode(t, err, test.want)
+		})
+	}
+}
+
+func TestGrantPermission(t *testing.T) {
+
+	assignments := map[string][]string{
+		"admin@treesense.com":   {"ADMIN"},
+		"manager@treesense.com": {"MANAGER"},
+		"zoned@treesense.com":   {"MANAGER"},
+	}
+
+	tests := []struct {
+		name       string
+		by         string
+		roleName   string
+		permission string
+		want       error
+	}{
+		{"admin grants any permission", "admin@treesense.com", "VIEWER", "EDIT", nil},
+		{"manager grants a permission they hold", "manager@treesense.com", "VIEWER", "MANAGE", nil},
+		{"manager can't grant a permission they lack", "manager@treesense.com", "MANAGER", "CONFIG", errors.ErrPermissionEscalation("")},
+		{"permission already granted", "admin@treesense.com", "VIEWER", "READ", errors.ErrRolePermissionExist},
+		{"zone limited manager can't grant a permission", "zoned@treesense.com", "VIEWER", "MANAGE", errors.ErrPermissionZoneEscalation("")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			service, repository := newTestService(assignments)
+			repository.zones["zoned@treesense.com"] = map[string][]string{"MANAGER": {"Palermo"}}
+			payload := RolePermissionPayload{RoleName: test.roleName, PermissionName: test.permission}
+
+			err := service.GrantPermission(payload, []uint8(test.by))
+			assertErrorCode(t, err, test.want)
+		})
+	}
+}
+
+func TestAdminRoleIsProtected(t *testing.T) {
+
+	service, repository := newTestService(map[string][]string{"admin@treesense.com": {"ADMIN"}})
+
+	for _, permission := range []string{"MANAGE", "CONFIG", "READ"} {
+		err := service.RevokePermission(RolePermissionPayload{RoleName: AdminRole, PermissionName: permission})
+		assertErrorCode(t, err, errors.ErrAdminRolePermission)
+	}
+	if len(repository.permissions[AdminRole]) != 6 {
+		t.Fatalf("expected ADMIN to keep its permissions, got %v", repository.permissions[AdminRole])
+	}
+
+	err := service.DeleteRole(DeleteRolePayload{RoleName: AdminRole})
+	assertErrorCode(t, err, errors.ErrAdminRoleDelete)
+
+	err = service.RevokePermission(RolePermissionPayload{RoleName: "EDITOR", PermissionName: "EDIT"})
+	assertErrorCode(t, err, nil)
+}
+
+func TestRoleAssigmentZones(t *testing.T) {
+
+	future := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		roleName string
+		zones    []string
+		want     error
+	}{
+		{"zone limited manager grants a role in their zone", "EDITOR", []string{"Palermo"}, nil},
+		{"zone limited manager can't grant a role everywhere", "EDITOR", nil, errors.ErrRoleZoneEscalation(nil)},
+		{"zone limited manager can't grant a role in another zone", "EDITOR", []string{"Recoleta"}, errors.ErrRoleZoneEscalation(nil)},
+		{"zone limited manager can't add another zone", "EDITOR", []string{"Palermo", "Recoleta"}, errors.ErrRoleZoneEscalation(nil)},
+		{"permissions held everywhere cover any zone", "VIEWER", nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// MANAGE and READ everywhere, EDIT only in Palermo
+			service, repository := newTestService(map[string][]string{"manager@treesense.com": {"MANAGER", "EDITOR"}})
+			repository.zones["manager@treesense.com"] = map[string][]string{"EDITOR": {"Palermo"}}
+
+			payload := CreateUserRoleAssigmentPayload{RoleName: test.roleName, ValidUntil: future, Zones: test.zones}
+
+			err := service.CreateRoleAssigment(payload, "agent@treesense.com", []uint8("manager@treesense.com"))
+			assertErrorCode(t, err, test.want)
+		})
+	}
+
+	t.Run("zone limited manager can't revoke an assignment that applies everywhere", func(t *testing.T) {
+
+		service, repository := newTestService(map[string][]string{
+			"manager@treesense.com": {"MANAGER", "EDITOR"},
+			"agent@treesense.com":   {"EDITOR"},
+		})
+		repository.zones["manager@treesense.com"] = map[string][]string{"EDITOR": {"Palermo"}}
+
+		err := service.DeleteRoleAssigment(DeleteUserRoleAssigmentPayload{RoleName: "EDITOR"}, "agent@treesense.com", []uint8("manager@treesense.com"))
+		assertErrorCode(t, err, errors.ErrRoleZoneEscalation(nil))
+	})
+}
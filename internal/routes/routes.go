@@ -0,0 +1,69 @@
+package routes
+
+import (
+	"io"
+	"time"
+
+	"github.com/PabloPei/TreeSense-Backend/pkg/geo"
+)
+
+type Route struct {
+	RouteId    []uint8      `json:"routeId"`
+	UserId     []uint8      `json:"userId"`
+	StartAt    time.Time    `json:"startAt"`
+	EndAt      *time.Time   `json:"endAt"`
+	Length     float64      `json:"length"` // meters
+	PointCount int          `json:"pointCount"`
+	Points     []RoutePoint `json:"points,omitempty"`
+}
+
+type RoutePoint struct {
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+// RouteTree is the subset of a tree exported alongside its route
+type RouteTree struct {
+	TreeId      []uint8   `json:"treeId"`
+	Species     string    `json:"species"`
+	State       string    `json:"state"`
+	Description string    `json:"description"`
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type RouteRepository interface {
+	CreateRoute(userId []uint8, startAt time.Time) ([]uint8, error)
+	GetRouteById(routeId []uint8) (*Route, error)
+	GetOpenRouteByUserId(userId []uint8) (*Route, error)
+	GetRoutesByUserId(userId []uint8) ([]Route, error)
+	GetRoutePoints(routeId []uint8) ([]RoutePoint, error)
+	AddRoutePoints(routeId []uint8, points []RoutePoint) error
+	CloseRoute(routeId []uint8, endAt time.Time) error
+	ImportRoute(userId []uint8, points []RoutePoint) ([]uint8, error)
+	GetRouteTrees(routeId []uint8) ([]RouteTree, error)
+}
+
+type RouteService interface {
+	StartRoute(userId []uint8) (*Route, error)
+	AddRoutePoints(routeId []uint8, payload addRoutePointsPayload, userId []uint8) error
+	CloseRoute(routeId []uint8, userId []uint8) error
+	GetRoute(routeId []uint8, userId []uint8) (*Route, error)
+	GetRoutesByUser(userId []uint8) ([]Route, error)
+	ValidateOpenRoute(routeId []uint8, userId []uint8) error
+	ImportGPX(file io.Reader, userId []uint8) (*Route, error)
+	GetRouteGPX(routeId []uint8) (*geo.GPX, error)
+	GetRouteKML(routeId []uint8) (*geo.KML, error)
+}
+
+type addRoutePointsPayload struct {
+	Points []routePointPayload `json:"points" validate:"required,min=1,dive"`
+}
+
+type routePointPayload struct {
+	Latitude   float64   `json:"latitude" validate:"lat"`
+	Longitude  float64   `json:"longitude" validate:"lon"`
+	RecordedAt time.Time `json:"recordedAt" validate:"required"`
+}
@@ -0,0 +1,219 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/middlewares"
+	"github.com/PabloPei/TreeSense-Backend/pkg/geo"
+	"github.com/PabloPei/TreeSense-Backend/utils"
+	"github.com/gorilla/mux"
+)
+
+const maxImportSize = 10 << 20 // 10 MB
+
+type Handler struct {
+	service RouteService
+}
+
+func NewHandler(service RouteService) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middlewares.Middleware) {
+
+	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleStartRoute)).Methods("POST")
+	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleGetRoutesCurrentUser)).Methods("GET")
+	router.HandleFunc("/import", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleImportGPX)).Methods("POST")
+	router.HandleFunc("/{routeId}/export", middleware.RequireAuthAndPermission([]string{"READ"}, false)(h.handleExportRoute)).Methods("GET")
+	router.HandleFunc("/{routeId}", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleGetRoute)).Methods("GET")
+	router.HandleFunc("/{routeId}/points", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleAddRoutePoints)).Methods("POST")
+	router.HandleFunc("/{routeId}/close", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleCloseRoute)).Methods("POST")
+}
+
+func (h *Handler) handleStartRoute(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	route, err := h.service.StartRoute(userId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, route)
+}
+
+func (h *Handler) handleGetRoutesCurrentUser(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	routes, err := h.service.GetRoutesByUser(userId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"routes": routes})
+}
+
+func (h *Handler) handleGetRoute(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	routeId, ok := mux.Vars(r)["routeId"]
+	if !ok || !utils.IsUUID(routeId) {
+		utils.WriteError(w, errors.ErrRouteNotFound)
+		return
+	}
+
+	route, err := h.service.GetRoute([]uint8(routeId), userId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, route)
+}
+
+func (h *Handler) handleAddRoutePoints(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	routeId, ok := mux.Vars(r)["routeId"]
+	if !ok || !utils.IsUUID(routeId) {
+		utils.WriteError(w, errors.ErrRouteNotFound)
+		return
+	}
+
+	var payload addRoutePointsPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err = h.service.AddRoutePoints([]uint8(routeId), payload, userId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusCreated, "Route points added successfully")
+}
+
+func (h *Handler) handleCloseRoute(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	routeId, ok := mux.Vars(r)["routeId"]
+	if !ok || !utils.IsUUID(routeId) {
+		utils.WriteError(w, errors.ErrRouteNotFound)
+		return
+	}
+
+	err = h.service.CloseRoute([]uint8(routeId), userId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Route closed successfully")
+}
+
+// Expects a multipart form with the GPX track in the "file" field
+func (h *Handler) handleImportGPX(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		utils.WriteError(w, errors.ErrInvalidaPayload(err.Error()))
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		utils.WriteError(w, errors.ErrInvalidaPayload("missing gpx file"))
+		return
+	}
+	defer file.Close()
+
+	route, err := h.service.ImportGPX(file, userId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, route)
+}
+
+// format=gpx (default) or format=kml
+func (h *Handler) handleExportRoute(w http.ResponseWriter, r *http.Request) {
+
+	routeId, ok := mux.Vars(r)["routeId"]
+	if !ok || !utils.IsUUID(routeId) {
+		utils.WriteError(w, errors.ErrRouteNotFound)
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "gpx":
+		gpx, err := h.service.GetRouteGPX([]uint8(routeId))
+		if err != nil {
+			utils.WriteError(w, err)
+			return
+		}
+
+		// The status is already sent, so a failed write can only be logged
+		utils.WriteExportHeaders(w, geo.GPXContentType, fmt.Sprintf("route-%s.gpx", routeId))
+		if err := geo.EncodeGPX(w, gpx); err != nil {
+			log.Println(err)
+		}
+
+	case "kml":
+		kml, err := h.service.GetRouteKML([]uint8(routeId))
+		if err != nil {
+			utils.WriteError(w, err)
+			return
+		}
+
+		utils.WriteExportHeaders(w, geo.KMLContentType, fmt.Sprintf("route-%s.kml", routeId))
+		if err := geo.EncodeKML(w, kml); err != nil {
+			log.Println(err)
+		}
+
+	default:
+		utils.WriteError(w, errors.ErrUnsupportedExportFormat(format))
+	}
+}
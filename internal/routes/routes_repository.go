@@ -0,0 +1,247 @@
+package routes
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+)
+
+// Postgres SQL Repository
+type SQLRepository struct {
+	db *sql.DB
+}
+
+func NewSQLRepository(db *sql.DB) *SQLRepository {
+	return &SQLRepository{db: db}
+}
+
+type scannable interface {
+	Scan(dest ...interface{}) error
+}
+
+// Route columns in scanRowIntoRoute order, length is measured over the geography in meters
+const routeColumns = `r.route_id, r.user_id, r.start_at, r.end_at,
+	COALESCE(ST_Length(r.route::geography), 0) AS length,
+	(SELECT count(*) FROM treesense."route_point" p WHERE p.route_id = r.route_id) AS point_count`
+
+func (s *SQLRepository) CreateRoute(userId []uint8, startAt time.Time) ([]uint8, error) {
+
+	var routeId []uint8
+	err := s.db.QueryRow(
+		"INSERT INTO treesense.\"route\" (user_id, start_at) VALUES ($1, $2) RETURNING route_id",
+		userId, startAt.UTC(),
+	).Scan(&routeId)
+
+	if err != nil {
+		return nil, errors.ErrCantUploadRoute(err.Error())
+	}
+
+	return routeId, nil
+}
+
+func (s *SQLRepository) GetRouteById(routeId []uint8) (*Route, error) {
+	row := s.db.QueryRow("SELECT "+routeColumns+" FROM treesense.\"route\" r WHERE r.route_id = $1", routeId)
+	return scanRowIntoRoute(row)
+}
+
+func (s *SQLRepository) GetOpenRouteByUserId(userId []uint8) (*Route, error) {
+	row := s.db.QueryRow("SELECT "+routeColumns+" FROM treesense.\"route\" r WHERE r.user_id = $1 AND r.end_at IS NULL", userId)
+	return scanRowIntoRoute(row)
+}
+
+func (s *SQLRepository) GetRoutesByUserId(userId []uint8) ([]Route, error) {
+
+	rows, err := s.db.Query("SELECT "+routeColumns+" FROM treesense.\"route\" r WHERE r.user_id = $1 ORDER BY r.start_at DESC", userId)
+	if err != nil {
+		return nil, errors.ErrRouteScan(err.Error())
+	}
+
+	defer rows.Close()
+
+	var routes []Route
+
+	for rows.Next() {
+		route, err := scanRowIntoRoute(rows)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, *route)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrRouteScan(err.Error())
+	}
+
+	return routes, nil
+}
+
+func (s *SQLRepository) GetRoutePoints(routeId []uint8) ([]RoutePoint, error) {
+
+	rows, err := s.db.Query(
+		"SELECT ST_Y(location), ST_X(location), recorded_at FROM treesense.\"route_point\" WHERE route_id = $1 ORDER BY recorded_at, route_point_id",
+		routeId,
+	)
+	if err != nil {
+		return nil, errors.ErrRouteScan(err.Error())
+	}
+
+	defer rows.Close()
+
+	var points []RoutePoint
+
+	for rows.Next() {
+		var point RoutePoint
+		if err := rows.Scan(&point.Latitude, &point.Longitude, &point.RecordedAt); err != nil {
+			return nil, errors.ErrRouteScan(err.Error())
+		}
+		points = append(points, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrRouteScan(err.Error())
+	}
+
+	return points, nil
+}
+
+func (s *SQLRepository) AddRoutePoints(routeId []uint8, points []RoutePoint) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadRoute(err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := insertRoutePoints(tx, routeId, points); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadRoute(err.Error())
+	}
+
+	return nil
+}
+
+// ImportRoute creates an already closed route spanning the recorded times of its points
+func (s *SQLRepository) ImportRoute(userId []uint8, points []RoutePoint) ([]uint8, error) {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, errors.ErrCantUploadRoute(err.Error())
+	}
+	defer tx.Rollback()
+
+	var routeId []uint8
+	err = tx.QueryRow(
+		"INSERT INTO treesense.\"route\" (user_id, start_at, end_at) VALUES ($1, $2, $3) RETURNING route_id",
+		userId, points[0].RecordedAt.UTC(), points[len(points)-1].RecordedAt.UTC(),
+	).Scan(&routeId)
+	if err != nil {
+		return nil, errors.ErrCantUploadRoute(err.Error())
+	}
+
+	if err := insertRoutePoints(tx, routeId, points); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.ErrCantUploadRoute(err.Error())
+	}
+
+	return routeId, nil
+}
+
+func (s *SQLRepository) GetRouteTrees(routeId []uint8) ([]RouteTree, error) {
+
+	rows, err := s.db.Query(
+		"SELECT tree_id, COALESCE(species, ''), COALESCE(state, ''), COALESCE(description, ''), ST_Y(location), ST_X(location), created_at FROM treesense.\"tree\" WHERE route_id = $1 ORDER BY created_at",
+		routeId,
+	)
+	if err != nil {
+		return nil, errors.ErrTreeScan(err.Error())
+	}
+
+	defer rows.Close()
+
+	var trees []RouteTree
+
+	for rows.Next() {
+		var tree RouteTree
+		err := rows.Scan(&tree.TreeId, &tree.Species, &tree.State, &tree.Description, &tree.Latitude, &tree.Longitude, &tree.CreatedAt)
+		if err != nil {
+			return nil, errors.ErrTreeScan(err.Error())
+		}
+		trees = append(trees, tree)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrTreeScan(err.Error())
+	}
+
+	return trees, nil
+}
+
+func (s *SQLRepository) CloseRoute(routeId []uint8, endAt time.Time) error {
+
+	_, err := s.db.Exec("UPDATE treesense.\"route\" SET end_at = $1 WHERE route_id = $2", endAt.UTC(), routeId)
+	if err != nil {
+		return errors.ErrCantUploadRoute(err.Error())
+	}
+
+	return nil
+}
+
+/// Aux Function ///
+
+// insertRoutePoints stores the points and rebuilds the route LineString once it has at least two of them
+func insertRoutePoints(tx *sql.Tx, routeId []uint8, points []RoutePoint) error {
+
+	stmt, err := tx.Prepare("INSERT INTO treesense.\"route_point\" (route_id, location, recorded_at) VALUES ($1, ST_SetSRID(ST_MakePoint($2, $3), 4326), $4)")
+	if err != nil {
+		return errors.ErrCantUploadRoute(err.Error())
+	}
+	defer stmt.Close()
+
+	for _, point := range points {
+		if _, err := stmt.Exec(routeId, point.Longitude, point.Latitude, point.RecordedAt.UTC()); err != nil {
+			return errors.ErrCantUploadRoute(err.Error())
+		}
+	}
+
+	_, err = tx.Exec(`
+	UPDATE treesense."route" SET route = (
+		SELECT CASE WHEN count(*) >= 2 THEN ST_MakeLine(location ORDER BY recorded_at, route_point_id) END
+		FROM treesense."route_point"
+		WHERE route_id = $1
+	)
+	WHERE route_id = $1
+	`, routeId)
+	if err != nil {
+		return errors.ErrCantUploadRoute(err.Error())
+	}
+
+	return nil
+}
+
+func scanRowIntoRoute(row scannable) (*Route, error) {
+
+	route := new(Route)
+	err := row.Scan(
+		&route.RouteId,
+		&route.UserId,
+		&route.StartAt,
+		&route.EndAt,
+		&route.Length,
+		&route.PointCount,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrRouteNotFound
+		}
+		return nil, errors.ErrRouteScan(err.Error())
+	}
+	return route, nil
+}
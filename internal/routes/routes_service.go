@@ -0,0 +1,283 @@
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/pkg/geo"
+	"github.com/PabloPei/TreeSense-Backend/utils"
+)
+
+const exportCreator = "TreeSense"
+
+type Service struct {
+	repository RouteRepository
+}
+
+func NewService(repository RouteRepository) *Service {
+	return &Service{repository: repository}
+}
+
+// StartRoute opens a new route for the user, only one route can be open at a time
+func (s *Service) StartRoute(userId []uint8) (*Route, error) {
+
+	_, err := s.repository.GetOpenRouteByUserId(userId)
+	if err == nil {
+		return nil, errors.ErrRouteAlreadyOpen
+	} else if err != errors.ErrRouteNotFound {
+		return nil, err
+	}
+
+	routeId, err := s.repository.CreateRoute(userId, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRoute(routeId, userId)
+}
+
+func (s *Service) AddRoutePoints(routeId []uint8, payload addRoutePointsPayload, userId []uint8) error {
+
+	if err := s.ValidateOpenRoute(routeId, userId); err != nil {
+		return err
+	}
+
+	points := make([]RoutePoint, 0, len(payload.Points))
+	for _, point := range payload.Points {
+		points = append(points, RoutePoint{
+			Latitude:   point.Latitude,
+			Longitude:  point.Longitude,
+			RecordedAt: point.RecordedAt,
+		})
+	}
+
+	return s.repository.AddRoutePoints(routeId, points)
+}
+
+func (s *Service) CloseRoute(routeId []uint8, userId []uint8) error {
+
+	if err := s.ValidateOpenRoute(routeId, userId); err != nil {
+		return err
+	}
+
+	return s.repository.CloseRoute(routeId, time.Now())
+}
+
+func (s *Service) GetRoute(routeId []uint8, userId []uint8) (*Route, error) {
+
+	route, err := s.getOwnedRoute(routeId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := s.repository.GetRoutePoints(routeId)
+	if err != nil {
+		return nil, err
+	}
+
+	route.Points = points
+	if route.Points == nil {
+		route.Points = []RoutePoint{}
+	}
+
+	convertRouteTimes(route)
+
+	return route, nil
+}
+
+func (s *Service) GetRoutesByUser(userId []uint8) ([]Route, error) {
+
+	routes, err := s.repository.GetRoutesByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if routes == nil {
+		routes = []Route{}
+	}
+
+	for i := range routes {
+		convertRouteTimes(&routes[i])
+	}
+
+	return routes, nil
+}
+
+// ValidateOpenRoute checks that the route exists, belongs to the user and has not been closed
+func (s *Service) ValidateOpenRoute(routeId []uint8, userId []uint8) error {
+
+	route, err := s.getOwnedRoute(routeId, userId)
+	if err != nil {
+		return err
+	}
+
+	if route.EndAt != nil {
+		return errors.ErrRouteClosed
+	}
+
+	return nil
+}
+
+// ImportGPX creates a closed route from the track points of a GPX file
+func (s *Service) ImportGPX(file io.Reader, userId []uint8) (*Route, error) {
+
+	gpx, err := geo.DecodeGPX(file)
+	if err != nil {
+		return nil, errors.ErrInvalidGPX(err.Error())
+	}
+
+	trackPoints := gpx.TrackPoints()
+	if len(trackPoints) < 2 {
+		return nil, errors.ErrInvalidaPayload("gpx tracks must have at least two points")
+	}
+
+	points := make([]RoutePoint, 0, len(trackPoints))
+	for i, trackPoint := range trackPoints {
+		if trackPoint.Time == nil {
+			return nil, errors.ErrGPXPointWithoutTime(i + 1)
+		}
+
+		point := RoutePoint{Latitude: trackPoint.Latitude, Longitude: trackPoint.Longitude, RecordedAt: *trackPoint.Time}
+		if err := utils.Validate.Struct(routePointPayload(point)); err != nil {
+			return nil, errors.ErrGPXPointOutOfRange(i + 1)
+		}
+		points = append(points, point)
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].RecordedAt.Before(points[j].RecordedAt)
+	})
+
+	routeId, err := s.repository.ImportRoute(userId, points)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRoute(routeId, userId)
+}
+
+// GetRouteGPX exports the route as a track and its trees as waypoints
+func (s *Service) GetRouteGPX(routeId []uint8) (*geo.GPX, error) {
+
+	route, trees, err := s.getRouteExport(routeId)
+	if err != nil {
+		return nil, err
+	}
+
+	gpx := geo.NewGPX(exportCreator)
+
+	segment := geo.GPXTrackSegment{Points: make([]geo.GPXWaypoint, 0, len(route.Points))}
+	for _, point := range route.Points {
+		recordedAt := point.RecordedAt
+		segment.Points = append(segment.Points, geo.GPXWaypoint{
+			Latitude:  point.Latitude,
+			Longitude: point.Longitude,
+			Time:      &recordedAt,
+		})
+	}
+	gpx.Tracks = []geo.GPXTrack{{Name: string(route.RouteId), Segments: []geo.GPXTrackSegment{segment}}}
+
+	for _, tree := range trees {
+		createdAt := tree.CreatedAt
+		gpx.Waypoints = append(gpx.Waypoints, geo.GPXWaypoint{
+			Latitude:    tree.Latitude,
+			Longitude:   tree.Longitude,
+			Time:        &createdAt,
+			Name:        tree.Species,
+			Description: treeExportDescription(tree),
+		})
+	}
+
+	return gpx, nil
+}
+
+// GetRouteKML exports the route as a LineString placemark and its trees as point placemarks
+func (s *Service) GetRouteKML(routeId []uint8) (*geo.KML, error) {
+
+	route, trees, err := s.getRouteExport(routeId)
+	if err != nil {
+		return nil, err
+	}
+
+	kml := geo.NewKML(fmt.Sprintf("Route %s", route.RouteId))
+
+	if len(route.Points) >= 2 {
+		coordinates := make([][2]float64, 0, len(route.Points))
+		for _, point := range route.Points {
+			coordinates = append(coordinates, [2]float64{point.Longitude, point.Latitude})
+		}
+		kml.Document.Placemarks = append(kml.Document.Placemarks, geo.NewKMLLineStringPlacemark(string(route.RouteId), coordinates))
+	}
+
+	for _, tree := range trees {
+		placemark := geo.NewKMLPointPlacemark(tree.Species, treeExportDescription(tree), tree.Longitude, tree.Latitude)
+		placemark.TimeStamp = &geo.KMLTimeStamp{When: tree.CreatedAt}
+		kml.Document.Placemarks = append(kml.Document.Placemarks, placemark)
+	}
+
+	return kml, nil
+}
+
+// Aux Functions
+
+// Exports are not limited to the owner so stakeholders can download any route
+func (s *Service) getRouteExport(routeId []uint8) (*Route, []RouteTree, error) {
+
+	route, err := s.repository.GetRouteById(routeId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	route.Points, err = s.repository.GetRoutePoints(routeId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trees, err := s.repository.GetRouteTrees(routeId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return route, trees, nil
+}
+
+func treeExportDescription(tree RouteTree) string {
+
+	if tree.Description == "" {
+		return tree.State
+	}
+
+	return fmt.Sprintf("%s - %s", tree.State, tree.Description)
+}
+
+func (s *Service) getOwnedRoute(routeId []uint8, userId []uint8) (*Route, error) {
+
+	route, err := s.repository.GetRouteById(routeId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(route.UserId, userId) {
+		return nil, errors.ErrRouteNotOwned
+	}
+
+	return route, nil
+}
+
+// Convert timestamps to Argentina's time zone
+func convertRouteTimes(route *Route) {
+
+	route.StartAt = utils.ConvertUTCToArgentina(route.StartAt)
+	if route.EndAt != nil {
+		endAt := utils.ConvertUTCToArgentina(*route.EndAt)
+		route.EndAt = &endAt
+	}
+
+	for i := range route.Points {
+		route.Points[i].RecordedAt = utils.ConvertUTCToArgentina(route.Points[i].RecordedAt)
+	}
+}
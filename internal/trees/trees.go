@@ -1,6 +1,7 @@
 package trees
 
 import (
+	"io"
 	"time"
 )
 
@@ -9,7 +10,8 @@ type Tree struct {
 	RouteId     []uint8   `json:"routeId"`
 	Species     string    `json:"species"`
 	State       string    `json:"state"`
-	Location    string    `json:"location"`
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
 	Age         int       `json:"age"`
 	Height      float64   `json:"height"`
 	Diameter    float64   `json:"diameter"`
@@ -21,40 +23,211 @@ type Tree struct {
 	UpdatedAt   time.Time `json:"updatedAt"`
 }
 
+type NearbyTree struct {
+	Tree
+	Distance float64 `json:"distance"`
+}
+
+type TreePage struct {
+	Trees      []Tree `json:"trees"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type ImportRowResult struct {
+	Line     int              `json:"line"`
+	Accepted bool             `json:"accepted"`
+	Errors   []ImportRowError `json:"errors,omitempty"`
+}
+
+// ImportRowError is one reason a row was rejected, shaped like the error responses of the API
+type ImportRowError struct {
+	Code    string      `json:"code"`
+	Error   string      `json:"error"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+type ImportReport struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Imported int               `json:"imported"`
+	Rows     []ImportRowResult `json:"rows"`
+}
+
+// Columns expected in the CSV header, named after the createTreePayload JSON fields
+var importColumns = []string{"routeId", "species", "state", "latitude", "longitude", "age", "height", "diameter", "photoUrl", "description"}
+
 type TreeSpecies struct {
-	TreeSpeciesId string `json:"treeSpeciesId"`
-	Description   string `json:"description"`
+	TreeSpeciesId  string            `json:"treeSpeciesId"`
+	ScientificName string            `json:"scientificName"`
+	CommonName     string            `json:"commonName"`  // in the request language
+	CommonNames    map[string]string `json:"commonNames"` // language code -> common name
+	Family         string            `json:"family"`
+	Genus          string            `json:"genus"`
+	Native         bool              `json:"native"`
+	MaxHeight      float64           `json:"maxHeight"` // meters, 0 if unknown
+	GrowthRate     string            `json:"growthRate"`
+	Description    string            `json:"description"`  // in the request language when translated
+	Descriptions   map[string]string `json:"descriptions"` // language code -> description
+	RetiredAt      *time.Time        `json:"retiredAt"`    // nil while the species can be used in new surveys
 }
 
 type TreeState struct {
-	TreeStateId []uint8 `json:"treeStateId"`
-	Description string  `json:"description"`
+	TreeStateId  string            `json:"treeStateId"`
+	Severity     int               `json:"severity"`     // 0 is the healthiest state
+	Color        string            `json:"color"`        // hex hint for maps, e.g. #2E7D32
+	Label        string            `json:"label"`        // in the request language
+	Labels       map[string]string `json:"labels"`       // language code -> label
+	Description  string            `json:"description"`  // in the request language when translated
+	Descriptions map[string]string `json:"descriptions"` // language code -> description
 }
 
 type TreeRepository interface {
 	GetTreeById(treeId []uint8) (*Tree, error)
 	GetTreeStateById(stateId string) (*TreeState, error)
+	GetTreeStates() ([]TreeState, error)
+	CreateTreeState(state TreeState) error
+	UpdateTreeState(state TreeState) error
+	DeleteTreeState(stateId string) error
+	IsTreeStateInUse(stateId string) (bool, error)
 	GetSpeciesById(speciesId string) (*TreeSpecies, error)
 	CreateTree(tree Tree) error
-	GetSpecies() ([]TreeSpecies, error)
-	GetTreesByUserId(id []uint8) ([]Tree, error)
+	GetSpecies(includeRetired bool) ([]TreeSpecies, error)
+	CreateSpecies(species TreeSpecies) error
+	UpdateSpecies(species TreeSpecies) error
+	RetireSpecies(speciesId string) error
+	QueryTrees(query TreeQuery) ([]Tree, error)
+	UpdateTree(tree Tree, inspected bool) error
+	DeleteTree(treeId []uint8) error
+	GetTreesNearby(latitude float64, longitude float64, radius float64, limit int) ([]NearbyTree, error)
+	CreateTrees(trees []Tree) error
+}
+
+// ZoneChecker tells whether a point lies inside any of the zones, given by id
+type ZoneChecker interface {
+	ZonesContain(zoneIds []string, latitude float64, longitude float64) (bool, error)
 }
 
+// Methods taking zones only act on locations inside them; nil zones means anywhere
 type TreeService interface {
-	CreateTree(tree createTreePayload, userId []uint8) error
-	GetSpecies() ([]TreeSpecies, error)
-	GetTreesByUser(userId []uint8) ([]Tree, error)
+	CreateTree(tree createTreePayload, userId []uint8, zones []string) error
+	GetSpecies(includeRetired bool, language string) ([]TreeSpecies, error)
+	CreateSpecies(payload createSpeciesPayload) error
+	UpdateSpecies(speciesId string, payload updateSpeciesPayload) error
+	RetireSpecies(speciesId string) error
+	GetTreeStates(language string) ([]TreeState, error)
+	CreateTreeState(payload createTreeStatePayload) error
+	UpdateTreeState(stateId string, payload updateTreeStatePayload) error
+	DeleteTreeState(stateId string) error
+	QueryTrees(query TreeQuery, cursor string) (*TreePage, error)
+	GetTree(treeId []uint8) (*Tree, error)
+	UpdateTree(treeId []uint8, payload updateTreePayload, userId []uint8, zones []string) error
+	DeleteTree(treeId []uint8, zones []string) error
+	GetTreesNearby(query nearbyTreesQuery) ([]NearbyTree, error)
+	ImportTrees(file io.Reader, userId []uint8, zones []string, allOrNothing bool, language string) (*ImportReport, error)
 }
 
 type createTreePayload struct {
-	//RouteId []uint8 `json:"routeId" validate:"required"`
-	Species     string  `json:"species" validate:"required"`
-	State       string  `json:"state" validate:"required"`
-	Latitude    float64 `json:"latitude" validate:"required"`
-	Longitude   float64 `json:"longitude" validate:"required"`
-	Age         int     `json:"age" validate:"required"`
-	Height      float64 `json:"height" validate:"required"`
-	Diameter    float64 `json:"diameter" validate:"required"`
-	PhotoUrl    string  `json:"photoUrl" validate:"required,uri"`
-	Description string  `json:"description" validate:"required"`
+	RouteId     string   `json:"routeId" validate:"required,uuid"`
+	Species     string   `json:"species" validate:"required"`
+	State       string   `json:"state" validate:"required"`
+	Latitude    *float64 `json:"latitude" validate:"required,lat"` // pointers so 0 is a valid coordinate
+	Longitude   *float64 `json:"longitude" validate:"required,lon"`
+	Age         *int     `json:"age" validate:"required,min=0"`
+	Height      float64  `json:"height" validate:"required,tree_height"`
+	Diameter    float64  `json:"diameter" validate:"required,tree_diameter"`
+	PhotoUrl    string   `json:"photoUrl" validate:"omitempty,uri"`
+	Description string   `json:"description" validate:"required"`
+}
+
+type updateTreePayload struct {
+	Species     *string  `json:"species" validate:"omitempty"`
+	State       *string  `json:"state" validate:"omitempty"`
+	Latitude    *float64 `json:"latitude" validate:"required_with=Longitude,omitempty,lat"`
+	Longitude   *float64 `json:"longitude" validate:"required_with=Latitude,omitempty,lon"`
+	Age         *int     `json:"age" validate:"omitempty,min=0"`
+	Height      *float64 `json:"height" validate:"omitempty,tree_height"`
+	Diameter    *float64 `json:"diameter" validate:"omitempty,tree_diameter"`
+	PhotoUrl    *string  `json:"photoUrl" validate:"omitempty,uri"`
+	Description *string  `json:"description" validate:"omitempty"`
+}
+
+type createSpeciesPayload struct {
+	TreeSpeciesId  string            `json:"treeSpeciesId" validate:"omitempty,max=100"` // defaults to the scientific name
+	ScientificName string            `json:"scientificName" validate:"required,max=150"`
+	CommonNames    map[string]string `json:"commonNames" validate:"omitempty,dive,keys,required,max=10,endkeys,required,max=150"`
+	Descriptions   map[string]string `json:"descriptions" validate:"omitempty,dive,keys,required,max=10,endkeys,required"` // each language needs a common name
+	Family         string            `json:"family" validate:"omitempty,max=100"`
+	Genus          string            `json:"genus" validate:"omitempty,max=100"`
+	Native         bool              `json:"native"`
+	MaxHeight      float64           `json:"maxHeight" validate:"omitempty,tree_height"`
+	GrowthRate     string            `json:"growthRate" validate:"omitempty,oneof=SLOW MEDIUM FAST"`
+	Description    string            `json:"description"`
+}
+
+type updateSpeciesPayload struct {
+	ScientificName *string            `json:"scientificName" validate:"omitempty,min=1,max=150"`
+	CommonNames    *map[string]string `json:"commonNames" validate:"omitempty,dive,keys,required,max=10,endkeys,required,max=150"` // replaces every common name when present
+	Descriptions   *map[string]string `json:"descriptions" validate:"omitempty,dive,keys,required,max=10,endkeys,required"`        // replaces every translated description when present
+	Family         *string            `json:"family" validate:"omitempty,max=100"`
+	Genus          *string            `json:"genus" validate:"omitempty,max=100"`
+	Native         *bool              `json:"native"`
+	MaxHeight      *float64           `json:"maxHeight" validate:"omitempty,tree_height"`
+	GrowthRate     *string            `json:"growthRate" validate:"omitempty,oneof=SLOW MEDIUM FAST"`
+	Description    *string            `json:"description"`
+}
+
+type createTreeStatePayload struct {
+	TreeStateId  string            `json:"treeStateId" validate:"required,max=100"`
+	Severity     int               `json:"severity" validate:"min=0"`
+	Color        string            `json:"color" validate:"omitempty,hexcolor,len=7"`
+	Labels       map[string]string `json:"labels" validate:"omitempty,dive,keys,required,max=10,endkeys,required,max=100"`
+	Description  string            `json:"description"`
+	Descriptions map[string]string `json:"descriptions" validate:"omitempty,dive,keys,required,max=10,endkeys,required"` // each language needs a label
+}
+
+type updateTreeStatePayload struct {
+	Severity     *int               `json:"severity" validate:"omitempty,min=0"`
+	Color        *string            `json:"color" validate:"omitempty,hexcolor,len=7"`
+	Labels       *map[string]string `json:"labels" validate:"omitempty,dive,keys,required,max=10,endkeys,required,max=100"` // replaces every label when present
+	Description  *string            `json:"description"`
+	Descriptions *map[string]string `json:"descriptions" validate:"omitempty,dive,keys,required,max=10,endkeys,required"` // replaces every translated description when present
+}
+
+type nearbyTreesQuery struct {
+	Latitude  float64 `validate:"lat"`
+	Longitude float64 `validate:"lon"`
+	Radius    float64 `validate:"gt=0,max=50000"` // meters
+	Limit     int     `validate:"min=1,max=500"`  // closest trees first
+}
+
+type BoundingBox struct {
+	MinLongitude float64 `validate:"lon"`
+	MinLatitude  float64 `validate:"lat"`
+	MaxLongitude float64 `validate:"lon,gtfield=MinLongitude"`
+	MaxLatitude  float64 `validate:"lat,gtfield=MinLatitude"`
+}
+
+// TreeQuery holds every listing filter; nil or empty fields are not applied
+type TreeQuery struct {
+	Species     string
+	State       string
+	CreatedBy   []uint8
+	From        *time.Time
+	To          *time.Time
+	MinHeight   *float64
+	MaxHeight   *float64
+	MinDiameter *float64
+	MaxDiameter *float64
+	BoundingBox *BoundingBox
+	SortBy      string `validate:"omitempty,oneof=createdAt height diameter age"`
+	Descending  bool
+	After       *TreeCursor
+	Limit       int `validate:"min=0,max=500"` // 0 means no limit
+}
+
+// TreeCursor points at the last tree of a page for keyset pagination
+type TreeCursor struct {
+	SortBy string `json:"s"`
+	Value  string `json:"v"`
+	TreeId string `json:"id"`
 }
@@ -2,14 +2,24 @@ package trees
 
 import (
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/i18n"
 	"github.com/PabloPei/TreeSense-Backend/internal/middlewares"
+	"github.com/PabloPei/TreeSense-Backend/pkg/geo"
 	"github.com/PabloPei/TreeSense-Backend/utils"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
+const (
+	maxImportSize   = 10 << 20 // 10 MB
+	defaultPageSize = 100
+)
+
 type Handler struct {
 	service TreeService
 }
@@ -21,8 +31,21 @@ func NewHandler(service TreeService) *Handler {
 func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middlewares.Middleware) {
 
 	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleCreateTree)).Methods("POST")
-	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleGetTreesCurrentUser)).Methods("GET")
+	router.HandleFunc("", middleware.RequireAuthAndAnyPermission([]string{"READ", "SURVEY"}, false)(h.handleGetTrees)).Methods("GET")
+	router.HandleFunc("/import", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleImportTrees)).Methods("POST")
 	router.HandleFunc("/species", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleGetSpecies)).Methods("GET")
+	router.HandleFunc("/species", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleCreateSpecies)).Methods("POST")
+	router.HandleFunc("/species/{speciesId}", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleUpdateSpecies)).Methods("PUT", "PATCH")
+	router.HandleFunc("/species/{speciesId}/retire", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleRetireSpecies)).Methods("POST")
+	router.HandleFunc("/states", middleware.RequireAuthAndPermission([]string{"SURVEY"}, false)(h.handleGetTreeStates)).Methods("GET")
+	router.HandleFunc("/states", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleCreateTreeState)).Methods("POST")
+	router.HandleFunc("/states/{stateId}", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleUpdateTreeState)).Methods("PUT", "PATCH")
+	router.HandleFunc("/states/{stateId}", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleDeleteTreeState)).Methods("DELETE")
+	router.HandleFunc("/geojson", middleware.RequireAuthAndAnyPermission([]string{"READ", "SURVEY"}, false)(h.handleGetTreesGeoJSON)).Methods("GET")
+	router.HandleFunc("/nearby", middleware.RequireAuthAndAnyPermission([]string{"READ", "SURVEY"}, false)(h.handleGetTreesNearby)).Methods("GET")
+	router.HandleFunc("/{treeId}", middleware.RequireAuthAndAnyPermission([]string{"READ", "SURVEY"}, false)(h.handleGetTree)).Methods("GET")
+	router.HandleFunc("/{treeId}", middleware.RequireAuthAndPermission([]string{"EDIT"}, false)(h.handleUpdateTree)).Methods("PUT", "PATCH")
+	router.HandleFunc("/{treeId}", middleware.RequireAuthAndPermission([]string{"DELETE"}, false)(h.handleDeleteTree)).Methods("DELETE")
 
 }
 
@@ -30,59 +53,559 @@ func (h *Handler) handleCreateTree(w http.ResponseWriter, r *http.Request) {
 
 	userId, err := middlewares.GetUserIDFromContext(r.Context())
 	if err != nil {
-		utils.WriteError(w, http.StatusUnauthorized, errors.ErrJWTInvalidToken)
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
 		return
 	}
 
 	var tree createTreePayload
 	if err := utils.ParseJSON(r, &tree); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
 	if err := utils.Validate.Struct(tree); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrInvalidaPayload(validationErrors.Error()))
+		utils.WriteError(w, utils.ValidationError(err))
 		return
 	}
 
-	err = h.service.CreateTree(tree, userId)
+	err = h.service.CreateTree(tree, userId, middlewares.GetZonesFromContext(r.Context()))
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, map[string]string{
-		"message": "Tree created successfully",
-	})
+	utils.WriteMessage(w, http.StatusCreated, "Tree created successfully")
 }
 
-func (h *Handler) handleGetTreesCurrentUser(w http.ResponseWriter, r *http.Request) {
+// Expects a multipart form with the CSV in the "file" field. allOrNothing=true rejects the whole file on any invalid row.
+func (h *Handler) handleImportTrees(w http.ResponseWriter, r *http.Request) {
 
 	userId, err := middlewares.GetUserIDFromContext(r.Context())
 	if err != nil {
-		utils.WriteError(w, http.StatusUnauthorized, errors.ErrJWTInvalidToken)
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
 		return
 	}
 
-	trees, err := h.service.GetTreesByUser(userId)
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		utils.WriteError(w, errors.ErrInvalidaPayload(err.Error()))
+		return
+	}
 
+	file, _, err := r.FormFile("file")
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, errors.ErrInvalidaPayload("missing csv file"))
 		return
 	}
+	defer file.Close()
 
-	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"trees": trees})
+	allOrNothing, _ := strconv.ParseBool(r.FormValue("allOrNothing"))
+
+	report, err := h.service.ImportTrees(file, userId, middlewares.GetZonesFromContext(r.Context()), allOrNothing, i18n.LanguageFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if report.Imported == 0 && report.Rejected > 0 {
+		utils.WriteJSON(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, report)
 }
 
+// Without createdBy or bbox the listing defaults to the trees of the current user
+func (h *Handler) handleGetTrees(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	params := r.URL.Query()
+
+	query, err := parseTreeQuery(params)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if !params.Has("limit") {
+		query.Limit = defaultPageSize
+	}
+
+	if err := utils.Validate.Struct(query); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	if query.CreatedBy == nil && query.BoundingBox == nil {
+		query.CreatedBy = userId
+	}
+
+	page, err := h.service.QueryTrees(*query, params.Get("cursor"))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// Retired species are hidden unless includeRetired=true
 func (h *Handler) handleGetSpecies(w http.ResponseWriter, r *http.Request) {
 
-	species, err := h.service.GetSpecies()
+	includeRetired := r.URL.Query().Get("includeRetired") == "true"
+
+	species, err := h.service.GetSpecies(includeRetired, i18n.LanguageFromContext(r.Context()))
 
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, species)
 }
+
+func (h *Handler) handleCreateSpecies(w http.ResponseWriter, r *http.Request) {
+
+	var species createSpeciesPayload
+	if err := utils.ParseJSON(r, &species); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(species); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err := h.service.CreateSpecies(species)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusCreated, "Tree species created successfully")
+}
+
+func (h *Handler) handleUpdateSpecies(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	speciesId, ok := vars["speciesId"]
+	if !ok {
+		utils.WriteError(w, errors.ErrTreeSpeciesNotFound)
+		return
+	}
+
+	var species updateSpeciesPayload
+	if err := utils.ParseJSON(r, &species); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(species); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err := h.service.UpdateSpecies(speciesId, species)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Tree species updated successfully")
+}
+
+// Retiring is a soft delete: trees already surveyed keep the species
+func (h *Handler) handleRetireSpecies(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	speciesId, ok := vars["speciesId"]
+	if !ok {
+		utils.WriteError(w, errors.ErrTreeSpeciesNotFound)
+		return
+	}
+
+	err := h.service.RetireSpecies(speciesId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Tree species retired successfully")
+}
+
+// States are returned from healthiest to most severe
+func (h *Handler) handleGetTreeStates(w http.ResponseWriter, r *http.Request) {
+
+	states, err := h.service.GetTreeStates(i18n.LanguageFromContext(r.Context()))
+
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, states)
+}
+
+func (h *Handler) handleCreateTreeState(w http.ResponseWriter, r *http.Request) {
+
+	var state createTreeStatePayload
+	if err := utils.ParseJSON(r, &state); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(state); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err := h.service.CreateTreeState(state)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusCreated, "Tree state created successfully")
+}
+
+func (h *Handler) handleUpdateTreeState(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	stateId, ok := vars["stateId"]
+	if !ok {
+		utils.WriteError(w, errors.ErrTreeStateNotFound)
+		return
+	}
+
+	var state updateTreeStatePayload
+	if err := utils.ParseJSON(r, &state); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(state); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err := h.service.UpdateTreeState(stateId, state)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Tree state updated successfully")
+}
+
+func (h *Handler) handleDeleteTreeState(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	stateId, ok := vars["stateId"]
+	if !ok {
+		utils.WriteError(w, errors.ErrTreeStateNotFound)
+		return
+	}
+
+	err := h.service.DeleteTreeState(stateId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Tree state deleted successfully")
+}
+
+func (h *Handler) handleGetTree(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	treeId, ok := vars["treeId"]
+	if !ok || !utils.IsUUID(treeId) {
+		utils.WriteError(w, errors.ErrTreeNotFound)
+		return
+	}
+
+	tree, err := h.service.GetTree([]uint8(treeId))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tree)
+}
+
+func (h *Handler) handleUpdateTree(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	vars := mux.Vars(r)
+	treeId, ok := vars["treeId"]
+	if !ok || !utils.IsUUID(treeId) {
+		utils.WriteError(w, errors.ErrTreeNotFound)
+		return
+	}
+
+	var tree updateTreePayload
+	if err := utils.ParseJSON(r, &tree); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(tree); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err = h.service.UpdateTree([]uint8(treeId), tree, userId, middlewares.GetZonesFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Tree updated successfully")
+}
+
+func (h *Handler) handleDeleteTree(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	treeId, ok := vars["treeId"]
+	if !ok || !utils.IsUUID(treeId) {
+		utils.WriteError(w, errors.ErrTreeNotFound)
+		return
+	}
+
+	err := h.service.DeleteTree([]uint8(treeId), middlewares.GetZonesFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Tree deleted successfully")
+}
+
+func (h *Handler) handleGetTreesNearby(w http.ResponseWriter, r *http.Request) {
+
+	query, err := parseNearbyQuery(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(query); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	trees, err := h.service.GetTreesNearby(*query)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"trees": trees})
+}
+
+// Accepts the listing filters, or lat/lon/radius for a nearby export. Without filters the whole inventory is exported.
+func (h *Handler) handleGetTreesGeoJSON(w http.ResponseWriter, r *http.Request) {
+
+	params := r.URL.Query()
+
+	if params.Has("lat") || params.Has("lon") || params.Has("radius") {
+		query, err := parseNearbyQuery(params)
+		if err != nil {
+			utils.WriteError(w, err)
+			return
+		}
+
+		if err := utils.Validate.Struct(query); err != nil {
+			utils.WriteError(w, utils.ValidationError(err))
+			return
+		}
+
+		trees, err := h.service.GetTreesNearby(*query)
+		if err != nil {
+			utils.WriteError(w, err)
+			return
+		}
+
+		utils.WriteJSONAs(w, geo.GeoJSONContentType, http.StatusOK, nearbyTreesToFeatureCollection(trees))
+		return
+	}
+
+	query, err := parseTreeQuery(params)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(query); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	// The export is never paginated
+	query.Limit = 0
+
+	page, err := h.service.QueryTrees(*query, "")
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSONAs(w, geo.GeoJSONContentType, http.StatusOK, treesToFeatureCollection(page.Trees))
+}
+
+// Aux Functions
+
+func parseTreeQuery(params url.Values) (*TreeQuery, error) {
+
+	var err error
+	query := &TreeQuery{
+		Species:    params.Get("species"),
+		State:      params.Get("state"),
+		SortBy:     params.Get("sort"),
+		Descending: true,
+	}
+
+	if createdBy := params.Get("createdBy"); createdBy != "" {
+		if !utils.IsUUID(createdBy) {
+			return nil, errors.ErrInvalidaPayload("createdBy must be a user id")
+		}
+		query.CreatedBy = []uint8(createdBy)
+	}
+
+	if query.From, err = parseOptionalTime(params, "from", false); err != nil {
+		return nil, err
+	}
+	if query.To, err = parseOptionalTime(params, "to", true); err != nil {
+		return nil, err
+	}
+	if query.MinHeight, err = parseOptionalFloat(params, "minHeight"); err != nil {
+		return nil, err
+	}
+	if query.MaxHeight, err = parseOptionalFloat(params, "maxHeight"); err != nil {
+		return nil, err
+	}
+	if query.MinDiameter, err = parseOptionalFloat(params, "minDiameter"); err != nil {
+		return nil, err
+	}
+	if query.MaxDiameter, err = parseOptionalFloat(params, "maxDiameter"); err != nil {
+		return nil, err
+	}
+
+	if params.Has("bbox") {
+		if query.BoundingBox, err = parseBoundingBox(params.Get("bbox")); err != nil {
+			return nil, err
+		}
+	}
+
+	switch params.Get("order") {
+	case "", "desc":
+	case "asc":
+		query.Descending = false
+	default:
+		return nil, errors.ErrInvalidaPayload("order must be asc or desc")
+	}
+
+	if params.Has("limit") {
+		if query.Limit, err = strconv.Atoi(params.Get("limit")); err != nil {
+			return nil, errors.ErrInvalidaPayload("limit must be an integer")
+		}
+	}
+
+	return query, nil
+}
+
+func parseOptionalFloat(params url.Values, name string) (*float64, error) {
+
+	if !params.Has(name) {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseFloat(params.Get(name), 64)
+	if err != nil {
+		return nil, errors.ErrInvalidNumberParam(name)
+	}
+
+	return &value, nil
+}
+
+// Dates are accepted as RFC 3339 timestamps or plain YYYY-MM-DD days. endOfDay makes a plain day inclusive.
+func parseOptionalTime(params url.Values, name string, endOfDay bool) (*time.Time, error) {
+
+	if !params.Has(name) {
+		return nil, nil
+	}
+
+	if value, err := time.Parse(time.RFC3339, params.Get(name)); err == nil {
+		return &value, nil
+	}
+
+	if value, err := time.Parse(time.DateOnly, params.Get(name)); err == nil {
+		if endOfDay {
+			value = value.Add(24*time.Hour - time.Microsecond)
+		}
+		return &value, nil
+	}
+
+	return nil, errors.ErrInvalidDateParam(name)
+}
+
+func parseNearbyQuery(params url.Values) (*nearbyTreesQuery, error) {
+
+	var query nearbyTreesQuery
+	var err error
+
+	if query.Latitude, err = strconv.ParseFloat(params.Get("lat"), 64); err != nil {
+		return nil, errors.ErrInvalidaPayload("lat must be a number")
+	}
+	if query.Longitude, err = strconv.ParseFloat(params.Get("lon"), 64); err != nil {
+		return nil, errors.ErrInvalidaPayload("lon must be a number")
+	}
+	if query.Radius, err = strconv.ParseFloat(params.Get("radius"), 64); err != nil {
+		return nil, errors.ErrInvalidaPayload("radius must be a number")
+	}
+
+	query.Limit = defaultPageSize
+	if params.Has("limit") {
+		if query.Limit, err = strconv.Atoi(params.Get("limit")); err != nil {
+			return nil, errors.ErrInvalidaPayload("limit must be an integer")
+		}
+	}
+
+	return &query, nil
+}
+
+// bbox follows the minLon,minLat,maxLon,maxLat order
+func parseBoundingBox(bbox string) (*BoundingBox, error) {
+
+	parts := strings.Split(bbox, ",")
+	if len(parts) != 4 {
+		return nil, errors.ErrInvalidaPayload("bbox must be minLon,minLat,maxLon,maxLat")
+	}
+
+	values := make([]float64, 4)
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, errors.ErrInvalidaPayload("bbox must be minLon,minLat,maxLon,maxLat")
+		}
+		values[i] = value
+	}
+
+	return &BoundingBox{
+		MinLongitude: values[0],
+		MinLatitude:  values[1],
+		MaxLongitude: values[2],
+		MaxLatitude:  values[3],
+	}, nil
+}
@@ -2,6 +2,9 @@ package trees
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/PabloPei/TreeSense-Backend/internal/errors"
 )
@@ -19,10 +22,47 @@ type scannable interface {
 	Scan(dest ...interface{}) error
 }
 
+type sortColumn struct {
+	expression string
+	cast       string
+}
+
+// Sortable fields of TreeQuery. Nullable measures are coalesced so keyset comparisons stay well defined
+var treeSortColumns = map[string]sortColumn{
+	"createdAt": {expression: "created_at", cast: "timestamp"},
+	"height":    {expression: "COALESCE(height, 0)", cast: "float8"},
+	"diameter":  {expression: "COALESCE(diameter, 0)", cast: "float8"},
+	"age":       {expression: "COALESCE(age, 0)", cast: "int"},
+}
+
+// Tree columns in scanRowIntoTree order, with the location decoded into latitude/longitude
+const treeColumns = "tree_id, route_id, species, state, ST_Y(location) AS latitude, ST_X(location) AS longitude, age, height, diameter, COALESCE(photo_url, '') AS photo_url, description, created_by, updated_by, created_at, updated_at"
+
+// Species columns in scanRowIntoTreeSpecies order, with the common names and descriptions folded into JSON objects keyed by language
+const speciesColumns = `s.tree_species_id, s.scientific_name, COALESCE(s.family, ''), COALESCE(s.genus, ''), s.native, COALESCE(s.max_height, 0), COALESCE(s.growth_rate, ''), COALESCE(s.description, ''), s.retired_at,
+	COALESCE((SELECT json_object_agg(n.language_code, n.common_name) FROM treesense."tree_species_name" n WHERE n.tree_species_id = s.tree_species_id), '{}'),
+	COALESCE((SELECT json_object_agg(n.language_code, n.description) FROM treesense."tree_species_name" n WHERE n.tree_species_id = s.tree_species_id AND n.description IS NOT NULL), '{}')`
+
+// Tree state columns in scanRowIntoTreeState order, with the labels and descriptions folded into JSON objects keyed by language
+const stateColumns = `ts.tree_state_id, ts.severity, COALESCE(ts.color, ''), COALESCE(ts.description, ''),
+	COALESCE((SELECT json_object_agg(l.language_code, l.label) FROM treesense."tree_state_label" l WHERE l.tree_state_id = ts.tree_state_id), '{}'),
+	COALESCE((SELECT json_object_agg(l.language_code, l.description) FROM treesense."tree_state_label" l WHERE l.tree_state_id = ts.tree_state_id AND l.description IS NOT NULL), '{}')`
+
+// The survey itself is recorded as the first inspection of the tree
+const insertTreeStatement = `
+	WITH new_tree AS (
+		INSERT INTO treesense."tree" (route_id, species, state, age, height, diameter, photo_url, description, location, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, ST_SetSRID(ST_MakePoint($9, $10), 4326), $11)
+		RETURNING tree_id, state, height, diameter, created_by, created_at
+	)
+	INSERT INTO treesense."tree_inspection" (tree_id, state, height, diameter, inspected_by, inspected_at)
+	SELECT tree_id, state, height, diameter, created_by, created_at FROM new_tree
+	`
+
 func (s *SQLRepository) CreateTree(tree Tree) error {
 	_, err := s.db.Exec(
-		"INSERT INTO treesense.\"tree\" (species, state, age, height, diameter, photo_url, description, location, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7, ST_GeomFromText($8, 4326), $9)",
-		tree.Species, tree.State, tree.Age, tree.Height, tree.Diameter, tree.PhotoUrl, tree.Description, tree.Location, tree.CreatedBy,
+		insertTreeStatement,
+		tree.RouteId, tree.Species, tree.State, tree.Age, tree.Height, tree.Diameter, tree.PhotoUrl, tree.Description, tree.Longitude, tree.Latitude, tree.CreatedBy,
 	)
 	if err != nil {
 		return errors.ErrCantUploadTree(err.Error())
@@ -31,19 +71,232 @@ func (s *SQLRepository) CreateTree(tree Tree) error {
 	return nil
 }
 
+func (s *SQLRepository) CreateTrees(trees []Tree) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadTree(err.Error())
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(insertTreeStatement)
+	if err != nil {
+		return errors.ErrCantUploadTree(err.Error())
+	}
+	defer stmt.Close()
+
+	for _, tree := range trees {
+		_, err := stmt.Exec(tree.RouteId, tree.Species, tree.State, tree.Age, tree.Height, tree.Diameter, tree.PhotoUrl, tree.Description, tree.Longitude, tree.Latitude, tree.CreatedBy)
+		if err != nil {
+			return errors.ErrCantUploadTree(err.Error())
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadTree(err.Error())
+	}
+
+	return nil
+}
+
+// UpdateTree corrects the tree in place. Changed state or measurements are recorded as a new inspection,
+// since the latest one mirrors them and the older observations must stay as they were.
+func (s *SQLRepository) UpdateTree(tree Tree, inspected bool) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUpdateTree(err.Error())
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE treesense.\"tree\" SET species = $1, state = $2, age = $3, height = $4, diameter = $5, photo_url = NULLIF($6, ''), description = $7, location = ST_SetSRID(ST_MakePoint($8, $9), 4326), updated_by = $10, updated_at = CURRENT_TIMESTAMP WHERE tree_id = $11",
+		tree.Species, tree.State, tree.Age, tree.Height, tree.Diameter, tree.PhotoUrl, tree.Description, tree.Longitude, tree.Latitude, tree.UpdatedBy, tree.TreeId,
+	)
+	if err != nil {
+		return errors.ErrCantUpdateTree(err.Error())
+	}
+
+	if inspected {
+		_, err = tx.Exec(
+			"INSERT INTO treesense.\"tree_inspection\" (tree_id, state, height, diameter, inspected_by) VALUES ($1, $2, $3, $4, $5)",
+			tree.TreeId, tree.State, tree.Height, tree.Diameter, tree.UpdatedBy,
+		)
+		if err != nil {
+			return errors.ErrCantUpdateTree(err.Error())
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUpdateTree(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) DeleteTree(treeId []uint8) error {
+	result, err := s.db.Exec("DELETE FROM treesense.\"tree\" WHERE tree_id = $1", treeId)
+	if err != nil {
+		return errors.ErrCantDeleteTree(err.Error())
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.ErrCantDeleteTree(err.Error())
+	}
+
+	if affected == 0 {
+		return errors.ErrTreeNotFound
+	}
+
+	return nil
+}
+
 func (s *SQLRepository) GetTreeStateById(stateId string) (*TreeState, error) {
-	row := s.db.QueryRow("SELECT * FROM treesense.\"tree_state\" where tree_state_id = $1", stateId)
+	row := s.db.QueryRow("SELECT "+stateColumns+" FROM treesense.\"tree_state\" ts WHERE ts.tree_state_id = $1", stateId)
 	return scanRowIntoTreeState(row)
 }
 
-func (s *SQLRepository) GetSpeciesById(stateId string) (*TreeSpecies, error) {
-	row := s.db.QueryRow("SELECT * FROM treesense.\"tree_species\" where tree_species_id = $1", stateId)
+func (s *SQLRepository) GetTreeStates() ([]TreeState, error) {
+
+	rows, err := s.db.Query("SELECT " + stateColumns + " FROM treesense.\"tree_state\" ts ORDER BY ts.severity, ts.tree_state_id")
+	if err != nil {
+		return nil, errors.ErrReadingTreeState(err.Error())
+	}
+	defer rows.Close()
+
+	var states []TreeState
+
+	for rows.Next() {
+		state, err := scanRowIntoTreeState(rows)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, *state)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrTreeStateScan(err.Error())
+	}
+
+	return states, nil
+}
+
+func (s *SQLRepository) CreateTreeState(state TreeState) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadTreeState(err.Error())
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT INTO treesense.\"tree_state\" (tree_state_id, severity, color, description) VALUES ($1, $2, NULLIF($3, ''), $4)",
+		state.TreeStateId, state.Severity, state.Color, state.Description,
+	)
+	if err != nil {
+		return errors.ErrCantUploadTreeState(err.Error())
+	}
+
+	if err := insertStateLabels(tx, state.TreeStateId, state.Labels, state.Descriptions); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadTreeState(err.Error())
+	}
+
+	return nil
+}
+
+// UpdateTreeState overwrites the state attributes and replaces its labels
+func (s *SQLRepository) UpdateTreeState(state TreeState) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadTreeState(err.Error())
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"UPDATE treesense.\"tree_state\" SET severity = $1, color = NULLIF($2, ''), description = $3 WHERE tree_state_id = $4",
+		state.Severity, state.Color, state.Description, state.TreeStateId,
+	)
+	if err != nil {
+		return errors.ErrCantUploadTreeState(err.Error())
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.ErrCantUploadTreeState(err.Error())
+	}
+	if rows == 0 {
+		return errors.ErrTreeStateNotFound
+	}
+
+	_, err = tx.Exec("DELETE FROM treesense.\"tree_state_label\" WHERE tree_state_id = $1", state.TreeStateId)
+	if err != nil {
+		return errors.ErrCantUploadTreeState(err.Error())
+	}
+
+	if err := insertStateLabels(tx, state.TreeStateId, state.Labels, state.Descriptions); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadTreeState(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) DeleteTreeState(stateId string) error {
+
+	result, err := s.db.Exec("DELETE FROM treesense.\"tree_state\" WHERE tree_state_id = $1", stateId)
+	if err != nil {
+		return errors.ErrCantDeleteTreeState(err.Error())
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.ErrCantDeleteTreeState(err.Error())
+	}
+	if rows == 0 {
+		return errors.ErrTreeStateNotFound
+	}
+
+	return nil
+}
+
+// IsTreeStateInUse reports whether any tree or inspection references the state
+func (s *SQLRepository) IsTreeStateInUse(stateId string) (bool, error) {
+
+	var inUse bool
+	err := s.db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM treesense.\"tree\" WHERE state = $1) OR EXISTS (SELECT 1 FROM treesense.\"tree_inspection\" WHERE state = $1)",
+		stateId,
+	).Scan(&inUse)
+	if err != nil {
+		return false, errors.ErrReadingTreeState(err.Error())
+	}
+
+	return inUse, nil
+}
+
+func (s *SQLRepository) GetSpeciesById(speciesId string) (*TreeSpecies, error) {
+	row := s.db.QueryRow("SELECT "+speciesColumns+" FROM treesense.\"tree_species\" s WHERE s.tree_species_id = $1", speciesId)
 	return scanRowIntoTreeSpecies(row)
 }
 
-func (s *SQLRepository) GetSpecies() ([]TreeSpecies, error) {
+func (s *SQLRepository) GetSpecies(includeRetired bool) ([]TreeSpecies, error) {
+
+	query := "SELECT " + speciesColumns + " FROM treesense.\"tree_species\" s"
+	if !includeRetired {
+		query += " WHERE s.retired_at IS NULL"
+	}
+	query += " ORDER BY s.scientific_name"
 
-	rows, err := s.db.Query("SELECT * FROM treesense.\"tree_species\"")
+	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, errors.ErrReadingSpecies(err.Error())
 	}
@@ -66,9 +319,164 @@ func (s *SQLRepository) GetSpecies() ([]TreeSpecies, error) {
 	return treeSpecies, nil
 }
 
-func (s *SQLRepository) GetTreesByUserId(id []uint8) ([]Tree, error) {
-	rows, err := s.db.Query("SELECT * FROM treesense.\"tree\" WHERE created_by = $1", id)
+func (s *SQLRepository) CreateSpecies(species TreeSpecies) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadSpecies(err.Error())
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT INTO treesense.\"tree_species\" (tree_species_id, scientific_name, family, genus, native, max_height, growth_rate, description) VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, NULLIF($6, 0), NULLIF($7, ''), $8)",
+		species.TreeSpeciesId, species.ScientificName, species.Family, species.Genus, species.Native, species.MaxHeight, species.GrowthRate, species.Description,
+	)
+	if err != nil {
+		return errors.ErrCantUploadSpecies(err.Error())
+	}
+
+	if err := insertCommonNames(tx, species.TreeSpeciesId, species.CommonNames, species.Descriptions); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadSpecies(err.Error())
+	}
+
+	return nil
+}
+
+// UpdateSpecies overwrites the species attributes and replaces its common names
+func (s *SQLRepository) UpdateSpecies(species TreeSpecies) error {
 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadSpecies(err.Error())
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"UPDATE treesense.\"tree_species\" SET scientific_name = $1, family = NULLIF($2, ''), genus = NULLIF($3, ''), native = $4, max_height = NULLIF($5, 0), growth_rate = NULLIF($6, ''), description = $7 WHERE tree_species_id = $8",
+		species.ScientificName, species.Family, species.Genus, species.Native, species.MaxHeight, species.GrowthRate, species.Description, species.TreeSpeciesId,
+	)
+	if err != nil {
+		return errors.ErrCantUploadSpecies(err.Error())
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.ErrCantUploadSpecies(err.Error())
+	}
+	if rows == 0 {
+		return errors.ErrTreeSpeciesNotFound
+	}
+
+	_, err = tx.Exec("DELETE FROM treesense.\"tree_species_name\" WHERE tree_species_id = $1", species.TreeSpeciesId)
+	if err != nil {
+		return errors.ErrCantUploadSpecies(err.Error())
+	}
+
+	if err := insertCommonNames(tx, species.TreeSpeciesId, species.CommonNames, species.Descriptions); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadSpecies(err.Error())
+	}
+
+	return nil
+}
+
+// RetireSpecies keeps the species for existing trees but hides it from new surveys. Retiring twice keeps the first date.
+func (s *SQLRepository) RetireSpecies(speciesId string) error {
+
+	result, err := s.db.Exec(
+		"UPDATE treesense.\"tree_species\" SET retired_at = COALESCE(retired_at, CURRENT_TIMESTAMP) WHERE tree_species_id = $1",
+		speciesId,
+	)
+	if err != nil {
+		return errors.ErrCantUploadSpecies(err.Error())
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.ErrCantUploadSpecies(err.Error())
+	}
+	if rows == 0 {
+		return errors.ErrTreeSpeciesNotFound
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) QueryTrees(query TreeQuery) ([]Tree, error) {
+
+	var conditions []string
+	var args []interface{}
+
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if query.Species != "" {
+		conditions = append(conditions, "species = "+arg(query.Species))
+	}
+	if query.State != "" {
+		conditions = append(conditions, "state = "+arg(query.State))
+	}
+	if query.CreatedBy != nil {
+		conditions = append(conditions, "created_by = "+arg(query.CreatedBy))
+	}
+	if query.From != nil {
+		conditions = append(conditions, "created_at >= "+arg(query.From.UTC()))
+	}
+	if query.To != nil {
+		conditions = append(conditions, "created_at <= "+arg(query.To.UTC()))
+	}
+	if query.MinHeight != nil {
+		conditions = append(conditions, "height >= "+arg(*query.MinHeight))
+	}
+	if query.MaxHeight != nil {
+		conditions = append(conditions, "height <= "+arg(*query.MaxHeight))
+	}
+	if query.MinDiameter != nil {
+		conditions = append(conditions, "diameter >= "+arg(*query.MinDiameter))
+	}
+	if query.MaxDiameter != nil {
+		conditions = append(conditions, "diameter <= "+arg(*query.MaxDiameter))
+	}
+	if box := query.BoundingBox; box != nil {
+		conditions = append(conditions, fmt.Sprintf("location && ST_MakeEnvelope(%s, %s, %s, %s, 4326)",
+			arg(box.MinLongitude), arg(box.MinLatitude), arg(box.MaxLongitude), arg(box.MaxLatitude)))
+	}
+
+	sort, ok := treeSortColumns[query.SortBy]
+	if !ok {
+		sort = treeSortColumns["createdAt"]
+	}
+
+	direction, comparator := "ASC", ">"
+	if query.Descending {
+		direction, comparator = "DESC", "<"
+	}
+
+	// tree_id breaks ties so the cursor always points at a single row
+	if query.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, tree_id) %s (%s::%s, %s::uuid)",
+			sort.expression, comparator, arg(query.After.Value), sort.cast, arg(query.After.TreeId)))
+	}
+
+	statement := "SELECT " + treeColumns + " FROM treesense.\"tree\""
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += fmt.Sprintf(" ORDER BY %s %s, tree_id %s", sort.expression, direction, direction)
+	if query.Limit > 0 {
+		statement += " LIMIT " + arg(query.Limit)
+	}
+
+	rows, err := s.db.Query(statement, args...)
 	if err != nil {
 		return nil, errors.ErrTreeScan(err.Error())
 	}
@@ -92,20 +500,63 @@ func (s *SQLRepository) GetTreesByUserId(id []uint8) ([]Tree, error) {
 	return trees, nil
 }
 
+func (s *SQLRepository) GetTreesNearby(latitude float64, longitude float64, radius float64, limit int) ([]NearbyTree, error) {
+
+	// geography casts make distances and radius work in meters instead of degrees
+	query := `
+	SELECT
+		` + treeColumns + `,
+		ST_Distance(t.location::geography, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography) AS distance
+	FROM
+		treesense."tree" t
+	WHERE
+		ST_DWithin(t.location::geography, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3)
+	ORDER BY
+		distance, t.tree_id
+	LIMIT $4
+	`
+
+	rows, err := s.db.Query(query, longitude, latitude, radius, limit)
+	if err != nil {
+		return nil, errors.ErrTreeScan(err.Error())
+	}
+
+	defer rows.Close()
+
+	var trees []NearbyTree
+
+	for rows.Next() {
+		var distance float64
+		tree, err := scanRowIntoTree(rows, &distance)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, NearbyTree{Tree: *tree, Distance: distance})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrTreeScan(err.Error())
+	}
+
+	return trees, nil
+}
+
 func (s *SQLRepository) GetTreeById(id []uint8) (*Tree, error) {
-	row := s.db.QueryRow("SELECT * FROM treesense.\"tree\" WHERE tree_id = $1", id)
+	row := s.db.QueryRow("SELECT "+treeColumns+" FROM treesense.\"tree\" WHERE tree_id = $1", id)
 	return scanRowIntoTree(row)
 }
 
-func scanRowIntoTree(row scannable) (*Tree, error) {
+// extra receives any computed columns selected after the tree columns
+func scanRowIntoTree(row scannable, extra ...interface{}) (*Tree, error) {
 
 	tree := new(Tree)
-	err := row.Scan(
+	dest := []interface{}{
 		&tree.TreeId,
 		&tree.RouteId,
 		&tree.Species,
 		&tree.State,
-		&tree.Location,
+		&tree.Latitude,
+		&tree.Longitude,
 		&tree.Age,
 		&tree.Height,
 		&tree.Diameter,
@@ -115,7 +566,8 @@ func scanRowIntoTree(row scannable) (*Tree, error) {
 		&tree.UpdatedBy,
 		&tree.CreatedAt,
 		&tree.UpdatedAt,
-	)
+	}
+	err := row.Scan(append(dest, extra...)...)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -126,36 +578,97 @@ func scanRowIntoTree(row scannable) (*Tree, error) {
 	return tree, nil
 }
 
-func scanRowIntoTreeState(row *sql.Row) (*TreeState, error) {
+func scanRowIntoTreeState(row scannable) (*TreeState, error) {
 
 	treeState := new(TreeState)
+	var labels, descriptions []byte
 	err := row.Scan(
 		&treeState.TreeStateId,
+		&treeState.Severity,
+		&treeState.Color,
 		&treeState.Description,
+		&labels,
+		&descriptions,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.ErrTreeStateNotFound
 		}
-		return nil, errors.ErrTreeScan(err.Error())
+		return nil, errors.ErrTreeStateScan(err.Error())
+	}
+
+	if err := json.Unmarshal(labels, &treeState.Labels); err != nil {
+		return nil, errors.ErrTreeStateScan(err.Error())
+	}
+	if err := json.Unmarshal(descriptions, &treeState.Descriptions); err != nil {
+		return nil, errors.ErrTreeStateScan(err.Error())
 	}
+
 	return treeState, nil
 }
 
 func scanRowIntoTreeSpecies(row scannable) (*TreeSpecies, error) {
 
 	treeSpecies := new(TreeSpecies)
+	var commonNames, descriptions []byte
 	err := row.Scan(
 		&treeSpecies.TreeSpeciesId,
+		&treeSpecies.ScientificName,
+		&treeSpecies.Family,
+		&treeSpecies.Genus,
+		&treeSpecies.Native,
+		&treeSpecies.MaxHeight,
+		&treeSpecies.GrowthRate,
 		&treeSpecies.Description,
+		&treeSpecies.RetiredAt,
+		&commonNames,
+		&descriptions,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.ErrTreeSpeciesNotFound
 		}
-		return nil, errors.ErrTreeScan(err.Error())
+		return nil, errors.ErrTreeSpeciesScan(err.Error())
+	}
+
+	if err := json.Unmarshal(commonNames, &treeSpecies.CommonNames); err != nil {
+		return nil, errors.ErrTreeSpeciesScan(err.Error())
 	}
+	if err := json.Unmarshal(descriptions, &treeSpecies.Descriptions); err != nil {
+		return nil, errors.ErrTreeSpeciesScan(err.Error())
+	}
+
 	return treeSpecies, nil
 }
+
+func insertStateLabels(tx *sql.Tx, stateId string, labels map[string]string, descriptions map[string]string) error {
+
+	for language, label := range labels {
+		_, err := tx.Exec(
+			"INSERT INTO treesense.\"tree_state_label\" (tree_state_id, language_code, label, description) VALUES ($1, $2, $3, NULLIF($4, ''))",
+			stateId, language, label, descriptions[language],
+		)
+		if err != nil {
+			return errors.ErrCantUploadTreeState(err.Error())
+		}
+	}
+
+	return nil
+}
+
+func insertCommonNames(tx *sql.Tx, speciesId string, commonNames map[string]string, descriptions map[string]string) error {
+
+	for language, name := range commonNames {
+		_, err := tx.Exec(
+			"INSERT INTO treesense.\"tree_species_name\" (tree_species_id, language_code, common_name, description) VALUES ($1, $2, $3, NULLIF($4, ''))",
+			speciesId, language, name, descriptions[language],
+		)
+		if err != nil {
+			return errors.ErrCantUploadSpecies(err.Error())
+		}
+	}
+
+	return nil
+}
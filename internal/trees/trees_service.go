@@ -1,74 +1,712 @@
 package trees
 
-// TODO: El tree service tiene que trer el rout service para verificar que existan y que corresponda al usuario
 import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/i18n"
+	"github.com/PabloPei/TreeSense-Backend/internal/routes"
+	"github.com/PabloPei/TreeSense-Backend/pkg/geo"
 	"github.com/PabloPei/TreeSense-Backend/utils"
 )
 
+// treeCursorTimeLayout keeps the microseconds postgres stores so no tree is skipped between pages
+const treeCursorTimeLayout = "2006-01-02T15:04:05.999999"
+
 type Service struct {
-	repository TreeRepository
+	repository   TreeRepository
+	routeService routes.RouteService
+	zoneChecker  ZoneChecker
 }
 
-func NewService(repository TreeRepository) *Service {
-	return &Service{repository: repository}
+func NewService(repository TreeRepository, routeService routes.RouteService, zoneChecker ZoneChecker) *Service {
+	return &Service{repository: repository, routeService: routeService, zoneChecker: zoneChecker}
 }
 
-func (s *Service) CreateTree(payload createTreePayload, userId []uint8) error {
+func (s *Service) CreateTree(payload createTreePayload, userId []uint8, zones []string) error {
 
-	//TODO validar ruta
-	_, err := s.repository.GetTreeStateById(payload.State)
+	tree, err := s.buildTree(payload, userId, zones)
 	if err != nil {
-		return errors.ErrTreeStateNotFound
+		return err
 	}
 
-	_, err = s.repository.GetSpeciesById(payload.Species)
+	return s.repository.CreateTree(*tree)
+}
+
+// ImportTrees runs every CSV row through the CreateTree checks and inserts the valid ones in a single transaction.
+// With allOrNothing set, a single rejected row discards the whole file. Row errors are reported in language.
+func (s *Service) ImportTrees(file io.Reader, userId []uint8, zones []string, allOrNothing bool, language string) (*ImportReport, error) {
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
 	if err != nil {
-		return errors.ErrTreeSpeciesNotFound
+		return nil, errors.ErrInvalidCSVHeader(err.Error())
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for _, name := range importColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.ErrMissingCSVColumn(name)
+		}
+	}
+
+	report := &ImportReport{Rows: []ImportRowResult{}}
+	var trees []Tree
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			line := 0
+			if parseError, ok := err.(*csv.ParseError); ok {
+				line = parseError.StartLine
+			}
+			report.addRejected(line, []error{errors.ErrInvalidCSVRow(err.Error())}, language)
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		payload, rowErrors := parseImportRow(record, columns)
+		if len(rowErrors) == 0 {
+			if err := utils.Validate.Struct(payload); err != nil {
+				rowErrors = append(rowErrors, utils.ValidationError(err))
+			}
+		}
+
+		if len(rowErrors) == 0 {
+			tree, err := s.buildTree(*payload, userId, zones)
+			if err != nil {
+				rowErrors = append(rowErrors, err)
+			} else {
+				trees = append(trees, *tree)
+			}
+		}
+
+		if len(rowErrors) > 0 {
+			report.addRejected(line, rowErrors, language)
+			continue
+		}
+
+		report.Accepted++
+		report.Rows = append(report.Rows, ImportRowResult{Line: line, Accepted: true})
+	}
+
+	if len(trees) == 0 || (allOrNothing && report.Rejected > 0) {
+		return report, nil
+	}
+
+	if err := s.repository.CreateTrees(trees); err != nil {
+		return nil, err
+	}
+
+	report.Imported = len(trees)
+
+	return report, nil
+}
+
+// GetSpecies returns the catalog with the common name and description resolved to language
+func (s *Service) GetSpecies(includeRetired bool, language string) ([]TreeSpecies, error) {
+
+	species, err := s.repository.GetSpecies(includeRetired)
+	if err != nil {
+		return nil, err
+	}
+
+	if species == nil {
+		species = []TreeSpecies{}
+	}
+
+	for i := range species {
+		if species[i].RetiredAt != nil {
+			retiredAt := utils.ConvertUTCToArgentina(*species[i].RetiredAt)
+			species[i].RetiredAt = &retiredAt
+		}
+		species[i].CommonName = species[i].CommonNames[language]
+		if description, ok := species[i].Descriptions[language]; ok {
+			species[i].Description = description
+		}
+	}
+
+	return species, nil
+}
+
+func (s *Service) CreateSpecies(payload createSpeciesPayload) error {
+
+	if language := untranslatedLanguage(payload.CommonNames, payload.Descriptions); language != "" {
+		return errors.ErrUntranslatedCommonName(language)
+	}
+
+	speciesId := payload.TreeSpeciesId
+	if speciesId == "" {
+		speciesId = payload.ScientificName
+	}
+
+	_, err := s.repository.GetSpeciesById(speciesId)
+	if err == nil {
+		return errors.ErrTreeSpeciesAlreadyExist(speciesId)
+	}
+	if err != errors.ErrTreeSpeciesNotFound {
+		return err
+	}
+
+	return s.repository.CreateSpecies(TreeSpecies{
+		TreeSpeciesId:  speciesId,
+		ScientificName: payload.ScientificName,
+		CommonNames:    payload.CommonNames,
+		Descriptions:   payload.Descriptions,
+		Family:         payload.Family,
+		Genus:          payload.Genus,
+		Native:         payload.Native,
+		MaxHeight:      payload.MaxHeight,
+		GrowthRate:     payload.GrowthRate,
+		Description:    payload.Description,
+	})
+}
+
+// UpdateSpecies changes the catalog entry only; trees keep referencing it by its id
+func (s *Service) UpdateSpecies(speciesId string, payload updateSpeciesPayload) error {
+
+	species, err := s.repository.GetSpeciesById(speciesId)
+	if err != nil {
+		return err
+	}
+
+	if payload.ScientificName != nil {
+		species.ScientificName = *payload.ScientificName
+	}
+	if payload.CommonNames != nil {
+		species.CommonNames = *payload.CommonNames
+	}
+	if payload.Descriptions != nil {
+		species.Descriptions = *payload.Descriptions
+	}
+	if payload.Family != nil {
+		species.Family = *payload.Family
+	}
+	if payload.Genus != nil {
+		species.Genus = *payload.Genus
+	}
+	if payload.Native != nil {
+		species.Native = *payload.Native
+	}
+	if payload.MaxHeight != nil {
+		species.MaxHeight = *payload.MaxHeight
+	}
+	if payload.GrowthRate != nil {
+		species.GrowthRate = *payload.GrowthRate
+	}
+	if payload.Description != nil {
+		species.Description = *payload.Description
+	}
+
+	if language := untranslatedLanguage(species.CommonNames, species.Descriptions); language != "" {
+		return errors.ErrUntranslatedCommonName(language)
+	}
+
+	return s.repository.UpdateSpecies(*species)
+}
+
+func (s *Service) RetireSpecies(speciesId string) error {
+
+	return s.repository.RetireSpecies(speciesId)
+
+}
+
+// GetTreeStates returns the states with the label and description resolved to language
+func (s *Service) GetTreeStates(language string) ([]TreeState, error) {
+
+	states, err := s.repository.GetTreeStates()
+	if err != nil {
+		return nil, err
+	}
+
+	if states == nil {
+		states = []TreeState{}
+	}
+
+	for i := range states {
+		states[i].Label = states[i].Labels[language]
+		if description, ok := states[i].Descriptions[language]; ok {
+			states[i].Description = description
+		}
+	}
+
+	return states, nil
+}
+
+func (s *Service) CreateTreeState(payload createTreeStatePayload) error {
+
+	if language := untranslatedLanguage(payload.Labels, payload.Descriptions); language != "" {
+		return errors.ErrUntranslatedLabel(language)
+	}
+
+	_, err := s.repository.GetTreeStateById(payload.TreeStateId)
+	if err == nil {
+		return errors.ErrTreeStateAlreadyExist(payload.TreeStateId)
+	}
+	if err != errors.ErrTreeStateNotFound {
+		return err
+	}
+
+	return s.repository.CreateTreeState(TreeState{
+		TreeStateId:  payload.TreeStateId,
+		Severity:     payload.Severity,
+		Color:        payload.Color,
+		Labels:       payload.Labels,
+		Description:  payload.Description,
+		Descriptions: payload.Descriptions,
+	})
+}
+
+func (s *Service) UpdateTreeState(stateId string, payload updateTreeStatePayload) error {
+
+	state, err := s.repository.GetTreeStateById(stateId)
+	if err != nil {
+		return err
+	}
+
+	if payload.Severity != nil {
+		state.Severity = *payload.Severity
+	}
+	if payload.Color != nil {
+		state.Color = *payload.Color
+	}
+	if payload.Labels != nil {
+		state.Labels = *payload.Labels
+	}
+	if payload.Description != nil {
+		state.Description = *payload.Description
+	}
+	if payload.Descriptions != nil {
+		state.Descriptions = *payload.Descriptions
+	}
+
+	if language := untranslatedLanguage(state.Labels, state.Descriptions); language != "" {
+		return errors.ErrUntranslatedLabel(language)
+	}
+
+	return s.repository.UpdateTreeState(*state)
+}
+
+// DeleteTreeState only removes states nobody has used, so the tree history stays consistent
+func (s *Service) DeleteTreeState(stateId string) error {
+
+	if _, err := s.repository.GetTreeStateById(stateId); err != nil {
+		return err
+	}
+
+	inUse, err := s.repository.IsTreeStateInUse(stateId)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return errors.ErrTreeStateInUse
+	}
+
+	return s.repository.DeleteTreeState(stateId)
+}
+
+// QueryTrees returns one page of trees. cursor is the nextCursor of the previous page, empty for the first one.
+func (s *Service) QueryTrees(query TreeQuery, cursor string) (*TreePage, error) {
+
+	if query.SortBy == "" {
+		query.SortBy = "createdAt"
+	}
+
+	if cursor != "" {
+		after, err := decodeTreeCursor(cursor)
+		if err != nil || after.SortBy != query.SortBy {
+			return nil, errors.ErrInvalidaPayload("invalid cursor")
+		}
+		query.After = after
+	}
+
+	// One extra row tells whether there is a next page
+	limit := query.Limit
+	if limit > 0 {
+		query.Limit = limit + 1
+	}
+
+	trees, err := s.repository.QueryTrees(query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TreePage{Trees: trees}
+	if page.Trees == nil {
+		page.Trees = []Tree{}
+	}
+
+	if limit > 0 && len(trees) > limit {
+		page.Trees = trees[:limit]
+		page.NextCursor = encodeTreeCursor(query.SortBy, page.Trees[limit-1])
+	}
+
+	// Convert timestamps to Argentina's time zone
+	for i := range page.Trees {
+		page.Trees[i].CreatedAt = utils.ConvertUTCToArgentina(page.Trees[i].CreatedAt)
+		page.Trees[i].UpdatedAt = utils.ConvertUTCToArgentina(page.Trees[i].UpdatedAt)
+	}
+
+	return page, nil
+}
+
+func (s *Service) GetTree(treeId []uint8) (*Tree, error) {
+
+	tree, err := s.repository.GetTreeById(treeId)
+	if err != nil {
+		return nil, err
+	}
+
+	tree.CreatedAt = utils.ConvertUTCToArgentina(tree.CreatedAt)
+	tree.UpdatedAt = utils.ConvertUTCToArgentina(tree.UpdatedAt)
+
+	return tree, nil
+}
+
+func (s *Service) UpdateTree(treeId []uint8, payload updateTreePayload, userId []uint8, zones []string) error {
+
+	tree, err := s.repository.GetTreeById(treeId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkZones(zones, tree.Latitude, tree.Longitude); err != nil {
+		return err
+	}
+
+	// State and measurements are observations, a change to them goes into the inspection history
+	inspected := false
+
+	if payload.State != nil && *payload.State != tree.State {
+		if _, err := s.repository.GetTreeStateById(*payload.State); err != nil {
+			return errors.ErrTreeStateNotFound
+		}
+		tree.State = *payload.State
+		inspected = true
+	}
+
+	// Trees already surveyed with a retired species keep it, but can't be moved to one
+	if payload.Species != nil && *payload.Species != tree.Species {
+		if err := s.validateActiveSpecies(*payload.Species); err != nil {
+			return err
+		}
+		tree.Species = *payload.Species
+	}
+
+	// A tree can't be moved out of the zones either
+	if payload.Latitude != nil && payload.Longitude != nil {
+		if err := s.checkZones(zones, *payload.Latitude, *payload.Longitude); err != nil {
+			return err
+		}
+		tree.Latitude = *payload.Latitude
+		tree.Longitude = *payload.Longitude
+	}
+
+	if payload.Age != nil {
+		tree.Age = *payload.Age
+	}
+	if payload.Height != nil && *payload.Height != tree.Height {
+		tree.Height = *payload.Height
+		inspected = true
+	}
+	if payload.Diameter != nil && *payload.Diameter != tree.Diameter {
+		tree.Diameter = *payload.Diameter
+		inspected = true
+	}
+	if payload.PhotoUrl != nil {
+		tree.PhotoUrl = *payload.PhotoUrl
+	}
+	if payload.Description != nil {
+		tree.Description = *payload.Description
+	}
+
+	tree.UpdatedBy = userId
+
+	return s.repository.UpdateTree(*tree, inspected)
+}
+
+func (s *Service) DeleteTree(treeId []uint8, zones []string) error {
+
+	tree, err := s.repository.GetTreeById(treeId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkZones(zones, tree.Latitude, tree.Longitude); err != nil {
+		return err
+	}
+
+	return s.repository.DeleteTree(treeId)
+}
+
+func (s *Service) GetTreesNearby(query nearbyTreesQuery) ([]NearbyTree, error) {
+
+	trees, err := s.repository.GetTreesNearby(query.Latitude, query.Longitude, query.Radius, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if trees == nil {
+		trees = []NearbyTree{}
+	}
+
+	for i := range trees {
+		trees[i].CreatedAt = utils.ConvertUTCToArgentina(trees[i].CreatedAt)
+		trees[i].UpdatedAt = utils.ConvertUTCToArgentina(trees[i].UpdatedAt)
+	}
+
+	return trees, nil
+}
+
+// Aux Functions
+
+// buildTree applies the route, zone, species and state checks shared by single and bulk creation
+func (s *Service) buildTree(payload createTreePayload, userId []uint8, zones []string) (*Tree, error) {
+
+	if err := s.checkZones(zones, *payload.Latitude, *payload.Longitude); err != nil {
+		return nil, err
 	}
 
-	location := fmt.Sprintf("POINT(%f %f)", payload.Longitude, payload.Latitude)
+	routeId := []uint8(payload.RouteId)
+	if err := s.routeService.ValidateOpenRoute(routeId, userId); err != nil {
+		return nil, err
+	}
 
-	tree := Tree{
-		//RouteId:     payload.RouteId,
+	_, err := s.repository.GetTreeStateById(payload.State)
+	if err != nil {
+		return nil, errors.ErrTreeStateNotFound
+	}
+
+	if err := s.validateActiveSpecies(payload.Species); err != nil {
+		return nil, err
+	}
+
+	return &Tree{
+		RouteId:     routeId,
 		Species:     payload.Species,
 		State:       payload.State,
-		Location:    location,
-		Age:         payload.Age,
+		Latitude:    *payload.Latitude,
+		Longitude:   *payload.Longitude,
+		Age:         *payload.Age,
 		Height:      payload.Height,
 		Diameter:    payload.Diameter,
 		PhotoUrl:    payload.PhotoUrl,
 		Description: payload.Description,
 		CreatedBy:   userId,
+	}, nil
+}
+
+func (s *Service) checkZones(zones []string, latitude float64, longitude float64) error {
+	return CheckZones(s.zoneChecker, zones, latitude, longitude)
+}
+
+// CheckZones rejects locations outside the zones; nil zones means anywhere
+func CheckZones(zoneChecker ZoneChecker, zones []string, latitude float64, longitude float64) error {
+
+	if zones == nil {
+		return nil
+	}
+
+	inside, err := zoneChecker.ZonesContain(zones, latitude, longitude)
+	if err != nil {
+		return err
+	}
+	if !inside {
+		return errors.ErrOutsideZone
+	}
+
+	return nil
+}
+
+// validateActiveSpecies checks the species exists and has not been retired from new surveys
+func (s *Service) validateActiveSpecies(speciesId string) error {
+
+	species, err := s.repository.GetSpeciesById(speciesId)
+	if err != nil {
+		return errors.ErrTreeSpeciesNotFound
+	}
+
+	if species.RetiredAt != nil {
+		return errors.ErrTreeSpeciesRetired
+	}
+
+	return nil
+}
+
+// untranslatedLanguage returns a language that has a description but no name, since both share one row per language
+func untranslatedLanguage(names map[string]string, descriptions map[string]string) string {
+
+	for language := range descriptions {
+		if _, ok := names[language]; !ok {
+			return language
+		}
+	}
+
+	return ""
+}
+
+func parseImportRow(record []string, columns map[string]int) (*createTreePayload, []error) {
+
+	var rowErrors []error
+
+	value := func(name string) string {
+		index := columns[name]
+		if index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	number := func(name string) float64 {
+		n, err := strconv.ParseFloat(value(name), 64)
+		if err != nil {
+			rowErrors = append(rowErrors, errors.ErrInvalidNumberParam(name))
+		}
+		return n
+	}
+
+	age, err := strconv.Atoi(value("age"))
+	if err != nil {
+		rowErrors = append(rowErrors, errors.ErrInvalidIntegerParam("age"))
+	}
+
+	latitude := number("latitude")
+	longitude := number("longitude")
+
+	payload := &createTreePayload{
+		RouteId:     value("routeId"),
+		Species:     value("species"),
+		State:       value("state"),
+		Latitude:    &latitude,
+		Longitude:   &longitude,
+		Age:         &age,
+		Height:      number("height"),
+		Diameter:    number("diameter"),
+		PhotoUrl:    value("photoUrl"),
+		Description: value("description"),
 	}
 
-	return s.repository.CreateTree(tree)
+	return payload, rowErrors
 }
 
-func (s *Service) GetSpecies() ([]TreeSpecies, error) {
+func encodeTreeCursor(sortBy string, tree Tree) string {
 
-	return s.repository.GetSpecies()
+	var value string
+	switch sortBy {
+	case "height":
+		value = strconv.FormatFloat(tree.Height, 'g', -1, 64)
+	case "diameter":
+		value = strconv.FormatFloat(tree.Diameter, 'g', -1, 64)
+	case "age":
+		value = strconv.Itoa(tree.Age)
+	default:
+		value = tree.CreatedAt.Format(treeCursorTimeLayout)
+	}
+
+	cursor, _ := json.Marshal(TreeCursor{SortBy: sortBy, Value: value, TreeId: string(tree.TreeId)})
 
+	return base64.RawURLEncoding.EncodeToString(cursor)
 }
 
-func (s *Service) GetTreesByUser(userId []uint8) ([]Tree, error) {
+func decodeTreeCursor(cursor string) (*TreeCursor, error) {
 
-	trees, err := s.repository.GetTreesByUserId(userId)
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
 	if err != nil {
 		return nil, err
 	}
 
-	if trees == nil {
-		trees = []Tree{}
+	after := new(TreeCursor)
+	if err := json.Unmarshal(raw, after); err != nil {
+		return nil, err
 	}
 
-	// Convert timestamps to Argentina's time zone
-	for i := range trees {
-		trees[i].CreatedAt = utils.ConvertUTCToArgentina(trees[i].CreatedAt)
-		trees[i].UpdatedAt = utils.ConvertUTCToArgentina(trees[i].UpdatedAt)
+	if !utils.IsUUID(after.TreeId) {
+		return nil, fmt.Errorf("invalid cursor tree id %q", after.TreeId)
 	}
 
-	return trees, nil
+	// the value is cast to the sort column in the query, so a malformed one would fail there instead of here
+	switch after.SortBy {
+	case "height", "diameter":
+		_, err = strconv.ParseFloat(after.Value, 64)
+	case "age":
+		_, err = strconv.Atoi(after.Value)
+	default:
+		_, err = time.Parse(treeCursorTimeLayout, after.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// addRejected records the row with its errors translated, as WriteError would render them
+func (r *ImportReport) addRejected(line int, rowErrors []error, language string) {
+
+	translated := make([]ImportRowError, len(rowErrors))
+	for i, err := range rowErrors {
+		e := errors.AsError(err)
+
+		details := e.Details
+		if fields, ok := details.([]errors.FieldError); ok {
+			details = i18n.TranslateFieldErrors(language, fields)
+		}
+
+		translated[i] = ImportRowError{Code: e.Code, Error: i18n.TranslateError(language, e), Details: details}
+	}
+
+	r.Rejected++
+	r.Rows = append(r.Rows, ImportRowResult{Line: line, Accepted: false, Errors: translated})
+}
+
+func treeFeature(tree Tree) geo.Feature {
+
+	return geo.NewPointFeature(string(tree.TreeId), tree.Longitude, tree.Latitude, map[string]interface{}{
+		"species":  tree.Species,
+		"state":    tree.State,
+		"height":   tree.Height,
+		"diameter": tree.Diameter,
+		"age":      tree.Age,
+		"photoUrl": tree.PhotoUrl,
+	})
+}
+
+func treesToFeatureCollection(trees []Tree) geo.FeatureCollection {
+
+	features := make([]geo.Feature, 0, len(trees))
+	for _, tree := range trees {
+		features = append(features, treeFeature(tree))
+	}
+
+	return geo.NewFeatureCollection(features)
+}
+
+func nearbyTreesToFeatureCollection(trees []NearbyTree) geo.FeatureCollection {
+
+	features := make([]geo.Feature, 0, len(trees))
+	for _, tree := range trees {
+		feature := treeFeature(tree.Tree)
+		feature.Properties["distance"] = tree.Distance
+		features = append(features, feature)
+	}
+
+	return geo.NewFeatureCollection(features)
 }
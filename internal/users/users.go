@@ -13,30 +13,96 @@ type User struct {
 	LanguageCode string    `json:"languageCode"`
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	TwoFactorEnabled bool `json:"twoFactorEnabled"`
 }
 
+// Session identifies a login session and its current refresh token
+type Session struct {
+	SessionId string
+	TokenId   string
+	UserId    []uint8
+	TwoFactor bool
+}
+
+// TOTP is a user's authenticator. It only guards logins once confirmed.
+type TOTP struct {
+	UserId       []uint8
+	Secret       string
+	ConfirmedAt  *time.Time
+	LastUsedStep *int64
+}
+
+// Purposes of the single-use tokens
+const (
+	TokenPurposePasswordReset     = "PASSWORD_RESET"
+	TokenPurposeEmailVerification = "EMAIL_VERIFICATION"
+	TokenPurposeTwoFactorLogin    = "TWO_FACTOR_LOGIN"
+)
+
 type UserRepository interface {
 	GetUserByEmail(email string) (*User, error)
 	CreateUser(User) error
 	UploadPhoto(photo string, email string) error
 	GetUserById(id []uint8) (*User, error)
+	LanguageExists(languageCode string) (bool, error)
+	UpdateLanguage(userId []uint8, languageCode string) error
+	CreateUserToken(userId []uint8, tokenHash string, purpose string, ttl time.Duration) error
+	ResetPassword(tokenHash string, hashedPassword string) error
+	VerifyEmail(tokenHash string) error
+	CheckUserToken(tokenHash string, purpose string) ([]uint8, error)
+	FailUserTokenAttempt(tokenHash string, maxAttempts int) error
+	ConsumeUserToken(tokenHash string, purpose string) error
+	CreateSession(userId []uint8, ttl time.Duration, twoFactor bool) (*Session, error)
+	RotateSession(tokenId string, ttl time.Duration) (*Session, error)
+	IsSessionActive(sessionId string) (bool, error)
+	RevokeSession(userId []uint8, sessionId string) error
+	RevokeAllSessions(userId []uint8) error
+	GetTOTP(userId []uint8) (*TOTP, error)
+	SaveTOTPSecret(userId []uint8, secret string) error
+	ConfirmTOTP(userId []uint8, step int64, recoveryCodeHashes []string) error
+	UseTOTPStep(userId []uint8, step int64) (bool, error)
+	ReplaceRecoveryCodes(userId []uint8, recoveryCodeHashes []string) error
+	UseRecoveryCode(userId []uint8, codeHash string) (bool, error)
+	DeleteTOTP(userId []uint8) error
+}
+
+// LoginGuard throttles password guessing per account and per client address
+type LoginGuard interface {
+	Check(email string, address string) error
+	RegisterFailure(email string, address string) error
+	RegisterSuccess(email string) error
 }
 
 type UserService interface {
 	RegisterUser(payload RegisterUserPayload) error
-	LogInUser(user LogInUserPayload) (string, string, error)
+	LogInUser(user LogInUserPayload, address string) (*LogInResponse, error)
+	LogInTwoFactor(payload TwoFactorLogInPayload, address string) (*LogInResponse, error)
 	GetUserPublicByEmail(email string) (*UserPublicPayload, error)
-	RefreshToken(userId []uint8) (string, error)
+	RefreshToken(tokenId string) (string, string, error)
+	Logout(userId []uint8, sessionId string) error
+	LogoutAll(userId []uint8) error
+	IsSessionActive(sessionId string) (bool, error)
 	UploadPhoto(payload UploadPhotoPayload, email string) error
 	UserExist(userId []uint8) (bool, error)
 	GetUserPublicById(userId []uint8) (*UserPublicPayload, error)
+	GetUserLanguage(userId []uint8) (string, error)
+	UpdateLanguage(userId []uint8, payload UpdateLanguagePayload) error
+	RequestPasswordReset(payload ForgotPasswordPayload) error
+	ResetPassword(payload ResetPasswordPayload) error
+	RequestEmailVerification(userId []uint8) error
+	VerifyEmail(payload VerifyEmailPayload) error
+	EnrollTwoFactor(userId []uint8) (*TwoFactorEnrollment, error)
+	ConfirmTwoFactor(userId []uint8, payload TwoFactorCodePayload) (*RecoveryCodes, error)
+	RegenerateRecoveryCodes(userId []uint8, payload TwoFactorCodePayload) (*RecoveryCodes, error)
+	DisableTwoFactor(userId []uint8, payload TwoFactorCodePayload) error
 }
 
 type RegisterUserPayload struct {
 	UserName string `json:"userName" validate:"required"`
 	Email    string `json:"email" validate:"required,email"`
 	Photo string `json:"photo" validate:"omitempty,base64"`
-	Password string `json:"password" validate:"required,min=3,max=130"`
+	Password string `json:"password" validate:"required,min=8,max=130"`
 }
 
 type LogInUserPayload struct {
@@ -48,10 +114,55 @@ type UploadPhotoPayload struct {
 	Photo string `json:"photo" validate:"omitempty,base64"`
 }
 
+type UpdateLanguagePayload struct {
+	LanguageCode string `json:"languageCode" validate:"required,max=10"`
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=130"`
+}
+
+type VerifyEmailPayload struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// LogInResponse carries the tokens, or the challenge token of the second step when the user has 2FA enabled
+type LogInResponse struct {
+	AccessToken       string `json:"accessToken,omitempty"`
+	RefreshToken      string `json:"refreshToken,omitempty"`
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	TwoFactorToken    string `json:"twoFactorToken,omitempty"`
+}
+
+type TwoFactorLogInPayload struct {
+	TwoFactorToken string `json:"twoFactorToken" validate:"required"`
+	Code           string `json:"code" validate:"required"` // a TOTP code or a recovery code
+}
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
 type UserPublicPayload struct {
 	UserName string  `json:"userName" validate:"required"`
 	Email    string  `json:"email" validate:"required,email"`
 	UserId   []uint8 `json:"userId"`
 	Photo string `json:"photo"`
 	LanguageCode string    `json:"languageCode"`
+	EmailVerified bool `json:"emailVerified"`
+	TwoFactorEnabled bool `json:"twoFactorEnabled"`
 }
@@ -3,10 +3,11 @@ package users
 import (
 	"net/http"
 
+	"github.com/PabloPei/TreeSense-Backend/conf"
 	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/i18n"
 	"github.com/PabloPei/TreeSense-Backend/internal/middlewares"
 	"github.com/PabloPei/TreeSense-Backend/utils"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
@@ -20,11 +21,22 @@ func NewHandler(service UserService) *Handler {
 
 func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middlewares.Middleware) {
 
-	//logout se aplica desde el frontend
 	router.HandleFunc("/register", h.handleUserRegister).Methods("POST")
 	router.HandleFunc("/login", h.handleLogin).Methods("POST")
+	router.HandleFunc("/login/2fa", h.handleLoginTwoFactor).Methods("POST")
 	router.HandleFunc("/refresh-token", middleware.RequireAuthAndPermission([]string{}, true)(h.handleRefreshToken)).Methods("POST")
+	router.HandleFunc("/logout", middleware.RequireAuthAndPermission([]string{}, false)(h.handleLogout)).Methods("POST")
+	router.HandleFunc("/logout/all", middleware.RequireAuthAndPermission([]string{}, false)(h.handleLogoutAll)).Methods("POST")
 	router.HandleFunc("/photo/{email}", middleware.RequireAuthAndPermission([]string{}, false)(h.handleUserPhoto)).Methods("POST", "PUT")
+	router.HandleFunc("/password/forgot", h.handleForgotPassword).Methods("POST")
+	router.HandleFunc("/password/reset", h.handleResetPassword).Methods("POST")
+	router.HandleFunc("/email/verify", h.handleVerifyEmail).Methods("POST")
+	router.HandleFunc("/email/verification", middleware.RequireAuthAndPermission([]string{}, false)(h.handleRequestEmailVerification)).Methods("POST")
+	router.HandleFunc("/2fa/enroll", middleware.RequireAuthAndPermission([]string{}, false)(h.handleEnrollTwoFactor)).Methods("POST")
+	router.HandleFunc("/2fa/confirm", middleware.RequireAuthAndPermission([]string{}, false)(h.handleConfirmTwoFactor)).Methods("POST")
+	router.HandleFunc("/2fa/recovery-codes", middleware.RequireAuthAndPermission([]string{}, false)(h.handleRegenerateRecoveryCodes)).Methods("POST")
+	router.HandleFunc("/2fa", middleware.RequireAuthAndPermission([]string{}, false)(h.handleDisableTwoFactor)).Methods("DELETE")
+	router.HandleFunc("/language", middleware.RequireAuthAndPermission([]string{}, false)(h.handleUpdateLanguage)).Methods("PUT")
 	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{}, false)(h.handleGetCurrentUser)).Methods("GET")
 	router.HandleFunc("/{email}", middleware.RequireAuthAndPermission([]string{"MANAGE"}, false)(h.handleGetUser)).Methods("GET")
 }
@@ -33,25 +45,22 @@ func (h *Handler) handleUserRegister(w http.ResponseWriter, r *http.Request) {
 
 	var user RegisterUserPayload
 	if err := utils.ParseJSON(r, &user); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
 	if err := utils.Validate.Struct(user); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrInvalidaPayload(validationErrors.Error()))
+		utils.WriteError(w, utils.ValidationError(err))
 		return
 	}
 
 	err := h.service.RegisterUser(user)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, map[string]string{
-		"message": "User registered successfully",
-	})
+	utils.WriteMessage(w, http.StatusCreated, "User registered successfully")
 }
 
 func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -59,45 +68,102 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 	var user LogInUserPayload
 
 	if err := utils.ParseJSON(r, &user); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
 	if err := utils.Validate.Struct(user); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrInvalidaPayload(validationErrors.Error()))
+		utils.WriteError(w, utils.ValidationError(err))
 		return
 	}
 
-	token, refreshToken, err := h.service.LogInUser(user)
-	if err == errors.ErrInvalidCredentials || err == errors.ErrUserNotFound {
-		utils.WriteError(w, http.StatusUnauthorized, err)
+	response, err := h.service.LogInUser(user, utils.GetClientIP(r, conf.LoginGuardConfig.TrustProxyHeaders))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handleLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+
+	var payload TwoFactorLogInPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
 		return
-	} else if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+
+	response, err := h.service.LogInTwoFactor(payload, utils.GetClientIP(r, conf.LoginGuardConfig.TrustProxyHeaders))
+	if err != nil {
+		utils.WriteError(w, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, map[string]string{"accessToken": token, "refreshToken": refreshToken})
+	utils.WriteJSON(w, http.StatusOK, response)
 }
 
 func (h *Handler) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
 
+	tokenId, err := middlewares.GetTokenIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	accessToken, refreshToken, err := h.service.RefreshToken(tokenId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"accessToken": accessToken, "refreshToken": refreshToken})
+
+}
+
+func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) {
+
 	userId, err := middlewares.GetUserIDFromContext(r.Context())
 	if err != nil {
-		utils.WriteError(w, http.StatusUnauthorized, errors.ErrJWTInvalidToken)
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	sessionId, err := middlewares.GetSessionIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	err = h.service.Logout(userId, sessionId)
+	if err != nil {
+		utils.WriteError(w, err)
 		return
 	}
 
-	newAccessToken, err := h.service.RefreshToken(userId)
+	utils.WriteMessage(w, http.StatusOK, "Logged out successfully")
+}
+
+func (h *Handler) handleLogoutAll(w http.ResponseWriter, r *http.Request) {
 
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
 	if err != nil {
-		utils.WriteError(w, http.StatusUnauthorized, err)
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, map[string]string{"accessToken": newAccessToken})
+	err = h.service.LogoutAll(userId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
 
+	utils.WriteMessage(w, http.StatusOK, "Logged out from all devices")
 }
 
 func (h *Handler) handleGetUser(w http.ResponseWriter, r *http.Request) {
@@ -105,13 +171,13 @@ func (h *Handler) handleGetUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	email, ok := vars["email"]
 	if !ok {
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrUserNotFound)
+		utils.WriteError(w, errors.ErrUserNotFound)
 		return
 	}
 
 	userPublic, err := h.service.GetUserPublicByEmail(email)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, err)
 		return
 	}
 
@@ -122,13 +188,13 @@ func (h *Handler) handleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
 
 	userId, err := middlewares.GetUserIDFromContext(r.Context())
 	if err != nil {
-		utils.WriteError(w, http.StatusUnauthorized, errors.ErrJWTInvalidToken)
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
 		return
 	}
 
 	userPublic, err := h.service.GetUserPublicById(userId)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, err)
 		return
 	}
 
@@ -141,29 +207,239 @@ func (h *Handler) handleUserPhoto(w http.ResponseWriter, r *http.Request) {
 	email, ok := vars["email"]
 
 	if !ok {
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrInvalidaPayload("missing email"))
+		utils.WriteError(w, errors.ErrInvalidaPayload("missing email"))
 		return
 	}
 
 	var payload UploadPhotoPayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
 		return
 	}
 
 	if err := utils.Validate.Struct(payload); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, errors.ErrInvalidaPayload(validationErrors.Error()))
+		utils.WriteError(w, utils.ValidationError(err))
 		return
 	}
 
 	err := h.service.UploadPhoto(payload, email)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Photo uploaded successfully")
+}
+
+func (h *Handler) handleUpdateLanguage(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	var payload UpdateLanguagePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err = h.service.UpdateLanguage(userId, payload)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	// Answer in the language just chosen
+	utils.WriteMessage(i18n.WithWriterLanguage(w, payload.LanguageCode), http.StatusOK, "Language updated successfully")
+}
+
+func (h *Handler) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+
+	var payload ForgotPasswordPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err := h.service.RequestPasswordReset(payload)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusAccepted, "If the email is registered, a password reset link has been sent")
+}
+
+func (h *Handler) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+
+	var payload ResetPasswordPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err := h.service.ResetPassword(payload)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Password reset successfully")
+}
+
+func (h *Handler) handleRequestEmailVerification(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	err = h.service.RequestEmailVerification(userId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusAccepted, "Verification email sent")
+}
+
+func (h *Handler) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+
+	var payload VerifyEmailPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err := h.service.VerifyEmail(payload)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Email verified successfully")
+}
+
+func (h *Handler) handleEnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	enrollment, err := h.service.EnrollTwoFactor(userId)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, enrollment)
+}
+
+func (h *Handler) handleConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	var payload TwoFactorCodePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	codes, err := h.service.ConfirmTwoFactor(userId, payload)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, codes)
+}
+
+func (h *Handler) handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	var payload TwoFactorCodePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(userId, payload)
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, codes)
+}
+
+func (h *Handler) handleDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	var payload TwoFactorCodePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err = h.service.DisableTwoFactor(userId, payload)
+	if err != nil {
+		utils.WriteError(w, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, map[string]string{
-		"message": "Photo uploaded successfully",
-	})
+	utils.WriteMessage(w, http.StatusOK, "Two-factor authentication disabled")
 }
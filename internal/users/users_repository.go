@@ -3,9 +3,14 @@ package users
 import (
 	"database/sql"
 	"encoding/base64"
+	"time"
+
 	"github.com/PabloPei/TreeSense-Backend/internal/errors"
 )
 
+const userColumns = `user_id, user_name, email, password, photo, language_code, created_at, updated_at, email_verified_at,
+	EXISTS (SELECT 1 FROM auth.user_totp t WHERE t.user_id = auth."user".user_id AND t.confirmed_at IS NOT NULL)`
+
 // Postgres SQL Repository
 type SQLRepository struct {
 	db *sql.DB
@@ -42,15 +47,509 @@ func (s *SQLRepository) UploadPhoto(photo string, email string) error {
 }
 
 func (s *SQLRepository) GetUserByEmail(email string) (*User, error) {
-	row := s.db.QueryRow("SELECT * FROM auth.\"user\" WHERE email = $1", email)
+	row := s.db.QueryRow("SELECT "+userColumns+" FROM auth.\"user\" WHERE email = $1", email)
 	return scanRowIntoUser(row)
 }
 
 func (s *SQLRepository) GetUserById(id []uint8) (*User, error) {
-	row := s.db.QueryRow("SELECT * FROM auth.\"user\" WHERE user_id = $1", id)
+	row := s.db.QueryRow("SELECT "+userColumns+" FROM auth.\"user\" WHERE user_id = $1", id)
 	return scanRowIntoUser(row)
 }
 
+func (s *SQLRepository) LanguageExists(languageCode string) (bool, error) {
+
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM conf.language WHERE code = $1)", languageCode).Scan(&exists)
+	if err != nil {
+		return false, errors.ErrCantUpdateLanguage(err.Error())
+	}
+
+	return exists, nil
+}
+
+func (s *SQLRepository) UpdateLanguage(userId []uint8, languageCode string) error {
+
+	_, err := s.db.Exec(
+		"UPDATE auth.\"user\" SET language_code = $1, updated_at = CURRENT_TIMESTAMP WHERE user_id = $2",
+		languageCode, userId,
+	)
+
+	if err != nil {
+		return errors.ErrCantUpdateLanguage(err.Error())
+	}
+
+	return nil
+}
+
+// CreateUserToken stores a new single-use token, invalidating the user's previous unused tokens with the same purpose
+func (s *SQLRepository) CreateUserToken(userId []uint8, tokenHash string, purpose string, ttl time.Duration) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadUserToken(err.Error())
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE auth.user_token SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL",
+		userId, purpose,
+	)
+	if err != nil {
+		return errors.ErrCantUploadUserToken(err.Error())
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO auth.user_token (token_hash, user_id, purpose, expires_at) VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4))",
+		tokenHash, userId, purpose, ttl.Seconds(),
+	)
+	if err != nil {
+		return errors.ErrCantUploadUserToken(err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadUserToken(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) ResetPassword(tokenHash string, hashedPassword string) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadUser(err.Error())
+	}
+	defer tx.Rollback()
+
+	userId, err := consumeUserToken(tx, tokenHash, TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE auth.\"user\" SET password = $1, updated_at = CURRENT_TIMESTAMP WHERE user_id = $2",
+		hashedPassword, userId,
+	)
+	if err != nil {
+		return errors.ErrCantUploadUser(err.Error())
+	}
+
+	// Whoever knew the old password may still hold a session
+	_, err = tx.Exec(
+		"UPDATE auth.session SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL",
+		userId,
+	)
+	if err != nil {
+		return errors.ErrCantUploadSession(err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadUser(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) VerifyEmail(tokenHash string) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadUser(err.Error())
+	}
+	defer tx.Rollback()
+
+	userId, err := consumeUserToken(tx, tokenHash, TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE auth.\"user\" SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP WHERE user_id = $1",
+		userId,
+	)
+	if err != nil {
+		return errors.ErrCantUploadUser(err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadUser(err.Error())
+	}
+
+	return nil
+}
+
+// CreateSession starts a login session, whose id is the jti of its first refresh token
+func (s *SQLRepository) CreateSession(userId []uint8, ttl time.Duration, twoFactor bool) (*Session, error) {
+
+	session := &Session{UserId: userId, TwoFactor: twoFactor}
+	err := s.db.QueryRow(
+		`INSERT INTO auth.session (jti, session_id, user_id, expires_at, two_factor)
+		SELECT id, id, $1, CURRENT_TIMESTAMP + make_interval(secs => $2), $3 FROM (SELECT gen_random_uuid() AS id) AS new_token
+		RETURNING jti, session_id`,
+		userId, ttl.Seconds(), twoFactor,
+	).Scan(&session.TokenId, &session.SessionId)
+
+	if err != nil {
+		return nil, errors.ErrCantUploadSession(err.Error())
+	}
+
+	return session, nil
+}
+
+// RotateSession exchanges a refresh token for a new one of the same session. Presenting a token that was already
+// exchanged means it was copied, so the whole session is revoked.
+func (s *SQLRepository) RotateSession(tokenId string, ttl time.Duration) (*Session, error) {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, errors.ErrCantUploadSession(err.Error())
+	}
+	defer tx.Rollback()
+
+	session := new(Session)
+	err = tx.QueryRow(
+		`UPDATE auth.session SET rotated_at = CURRENT_TIMESTAMP
+		WHERE jti = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING session_id, user_id, two_factor`,
+		tokenId,
+	).Scan(&session.SessionId, &session.UserId, &session.TwoFactor)
+
+	if err == sql.ErrNoRows {
+		return nil, revokeReusedSession(tx, tokenId)
+	}
+	if err != nil {
+		return nil, errors.ErrCantUploadSession(err.Error())
+	}
+
+	err = tx.QueryRow(
+		"INSERT INTO auth.session (session_id, user_id, expires_at, two_factor) VALUES ($1, $2, CURRENT_TIMESTAMP + make_interval(secs => $3), $4) RETURNING jti",
+		session.SessionId, session.UserId, ttl.Seconds(), session.TwoFactor,
+	).Scan(&session.TokenId)
+	if err != nil {
+		return nil, errors.ErrCantUploadSession(err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.ErrCantUploadSession(err.Error())
+	}
+
+	return session, nil
+}
+
+// IsSessionActive reports whether the session has a refresh token that can still be used
+func (s *SQLRepository) IsSessionActive(sessionId string) (bool, error) {
+
+	var active bool
+	err := s.db.QueryRow(
+		`SELECT EXISTS (
+			SELECT 1 FROM auth.session
+			WHERE session_id = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		)`,
+		sessionId,
+	).Scan(&active)
+
+	if err != nil {
+		return false, errors.ErrReadingSession(err.Error())
+	}
+
+	return active, nil
+}
+
+func (s *SQLRepository) RevokeSession(userId []uint8, sessionId string) error {
+
+	_, err := s.db.Exec(
+		"UPDATE auth.session SET revoked_at = CURRENT_TIMESTAMP WHERE session_id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		sessionId, userId,
+	)
+	if err != nil {
+		return errors.ErrCantUploadSession(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) RevokeAllSessions(userId []uint8) error {
+
+	_, err := s.db.Exec(
+		"UPDATE auth.session SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL",
+		userId,
+	)
+	if err != nil {
+		return errors.ErrCantUploadSession(err.Error())
+	}
+
+	return nil
+}
+
+// CheckUserToken returns the user of a token that can still be redeemed, without redeeming it
+func (s *SQLRepository) CheckUserToken(tokenHash string, purpose string) ([]uint8, error) {
+
+	var userId []uint8
+	err := s.db.QueryRow(
+		"SELECT user_id FROM auth.user_token WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP",
+		tokenHash, purpose,
+	).Scan(&userId)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrInvalidUserToken
+		}
+		return nil, errors.ErrCantUploadUserToken(err.Error())
+	}
+
+	return userId, nil
+}
+
+// FailUserTokenAttempt counts a failed attempt to redeem a token, invalidating it after maxAttempts
+func (s *SQLRepository) FailUserTokenAttempt(tokenHash string, maxAttempts int) error {
+
+	_, err := s.db.Exec(
+		`UPDATE auth.user_token SET attempts = attempts + 1,
+			used_at = CASE WHEN attempts + 1 >= $2 THEN CURRENT_TIMESTAMP ELSE used_at END
+		WHERE token_hash = $1 AND used_at IS NULL`,
+		tokenHash, maxAttempts,
+	)
+	if err != nil {
+		return errors.ErrCantUploadUserToken(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) ConsumeUserToken(tokenHash string, purpose string) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadUserToken(err.Error())
+	}
+	defer tx.Rollback()
+
+	if _, err := consumeUserToken(tx, tokenHash, purpose); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadUserToken(err.Error())
+	}
+
+	return nil
+}
+
+/// Two-factor authentication ///
+
+func (s *SQLRepository) GetTOTP(userId []uint8) (*TOTP, error) {
+
+	totp := &TOTP{UserId: userId}
+	err := s.db.QueryRow(
+		"SELECT secret, confirmed_at, last_used_step FROM auth.user_totp WHERE user_id = $1",
+		userId,
+	).Scan(&totp.Secret, &totp.ConfirmedAt, &totp.LastUsedStep)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrTwoFactorNotEnabled
+		}
+		return nil, errors.ErrReadingTwoFactor(err.Error())
+	}
+
+	return totp, nil
+}
+
+// SaveTOTPSecret starts an enrollment, replacing an unconfirmed secret but never a confirmed one
+func (s *SQLRepository) SaveTOTPSecret(userId []uint8, secret string) error {
+
+	result, err := s.db.Exec(
+		`INSERT INTO auth.user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = NULL, created_at = CURRENT_TIMESTAMP
+		WHERE auth.user_totp.confirmed_at IS NULL`,
+		userId, secret,
+	)
+	if err != nil {
+		return errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return errors.ErrTwoFactorAlreadyEnabled
+	}
+
+	return nil
+}
+
+// ConfirmTOTP enables two-factor login and issues the first recovery codes
+func (s *SQLRepository) ConfirmTOTP(userId []uint8, step int64, recoveryCodeHashes []string) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadTwoFactor(err.Error())
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"UPDATE auth.user_totp SET confirmed_at = CURRENT_TIMESTAMP, last_used_step = $2 WHERE user_id = $1 AND confirmed_at IS NULL",
+		userId, step,
+	)
+	if err != nil {
+		return errors.ErrCantUploadTwoFactor(err.Error())
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return errors.ErrTwoFactorAlreadyEnabled
+	}
+
+	if err := replaceRecoveryCodes(tx, userId, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the step of an accepted code. It fails if that step or a later one was already used.
+func (s *SQLRepository) UseTOTPStep(userId []uint8, step int64) (bool, error) {
+
+	result, err := s.db.Exec(
+		"UPDATE auth.user_totp SET last_used_step = $2 WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)",
+		userId, step,
+	)
+	if err != nil {
+		return false, errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	return rows == 1, nil
+}
+
+func (s *SQLRepository) ReplaceRecoveryCodes(userId []uint8, recoveryCodeHashes []string) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadTwoFactor(err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userId, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) UseRecoveryCode(userId []uint8, codeHash string) (bool, error) {
+
+	result, err := s.db.Exec(
+		"UPDATE auth.user_recovery_code SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL",
+		userId, codeHash,
+	)
+	if err != nil {
+		return false, errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	return rows == 1, nil
+}
+
+func (s *SQLRepository) DeleteTOTP(userId []uint8) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.ErrCantUploadTwoFactor(err.Error())
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM auth.user_recovery_code WHERE user_id = $1", userId); err != nil {
+		return errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	if _, err := tx.Exec("DELETE FROM auth.user_totp WHERE user_id = $1", userId); err != nil {
+		return errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userId []uint8, recoveryCodeHashes []string) error {
+
+	if _, err := tx.Exec("DELETE FROM auth.user_recovery_code WHERE user_id = $1", userId); err != nil {
+		return errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		_, err := tx.Exec("INSERT INTO auth.user_recovery_code (code_hash, user_id) VALUES ($1, $2)", codeHash, userId)
+		if err != nil {
+			return errors.ErrCantUploadTwoFactor(err.Error())
+		}
+	}
+
+	return nil
+}
+
+// revokeReusedSession explains why a refresh token couldn't be rotated, revoking its session if the token was reused
+func revokeReusedSession(tx *sql.Tx, tokenId string) error {
+
+	var sessionId string
+	var rotatedAt *time.Time
+	err := tx.QueryRow("SELECT session_id, rotated_at FROM auth.session WHERE jti = $1", tokenId).Scan(&sessionId, &rotatedAt)
+	if err == sql.ErrNoRows {
+		return errors.ErrJWTInvalidToken
+	}
+	if err != nil {
+		return errors.ErrReadingSession(err.Error())
+	}
+
+	if rotatedAt == nil {
+		// Revoked or expired
+		return errors.ErrSessionRevoked
+	}
+
+	_, err = tx.Exec("UPDATE auth.session SET revoked_at = CURRENT_TIMESTAMP WHERE session_id = $1 AND revoked_at IS NULL", sessionId)
+	if err != nil {
+		return errors.ErrCantUploadSession(err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.ErrCantUploadSession(err.Error())
+	}
+
+	return errors.ErrRefreshTokenReused
+}
+
+// consumeUserToken marks a usable token as used and returns its user, so a token can only be redeemed once
+func consumeUserToken(tx *sql.Tx, tokenHash string, purpose string) ([]uint8, error) {
+
+	var userId []uint8
+	err := tx.QueryRow(
+		"UPDATE auth.user_token SET used_at = CURRENT_TIMESTAMP WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP RETURNING user_id",
+		tokenHash, purpose,
+	).Scan(&userId)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrInvalidUserToken
+		}
+		return nil, errors.ErrCantUploadUserToken(err.Error())
+	}
+
+	return userId, nil
+}
+
 func scanRowIntoUser(row *sql.Row) (*User, error) {
 	user := new(User)
 
@@ -65,6 +564,8 @@ func scanRowIntoUser(row *sql.Row) (*User, error) {
 		&user.LanguageCode,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.EmailVerifiedAt,
+		&user.TwoFactorEnabled,
 	)
 
 	if err != nil {
@@ -1,16 +1,46 @@
 package users
 
 import (
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PabloPei/TreeSense-Backend/conf"
 	"github.com/PabloPei/TreeSense-Backend/internal/auth"
 	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/i18n"
+	"github.com/PabloPei/TreeSense-Backend/pkg/mail"
+	"github.com/PabloPei/TreeSense-Backend/pkg/totp"
+)
+
+const (
+	totpIssuer           = "TreeSense"
+	totpSkew             = 1 // steps of clock drift tolerated either way
+	recoveryCodesCount   = 10
+	twoFactorLogInTTL    = 5 * time.Minute
+	twoFactorMaxAttempts = 5
+)
+
+// Email texts, translated through the i18n catalogs
+const (
+	passwordResetSubject     = "Reset your TreeSense password"
+	passwordResetBody        = "Hello %s,\n\nWe received a request to reset your TreeSense password. Open the link below to choose a new one:\n\n%s\n\nThe link expires in %d minutes. If you didn't ask for it, you can ignore this email.\n"
+	emailVerificationSubject = "Verify your TreeSense email"
+	emailVerificationBody    = "Hello %s,\n\nPlease confirm your TreeSense email address by opening the link below:\n\n%s\n\nThe link expires in %d hours.\n"
 )
 
 type Service struct {
 	repository UserRepository
+	mailer     mail.Mailer
+	guard      LoginGuard
 }
 
-func NewService(repository UserRepository) *Service {
-	return &Service{repository: repository}
+func NewService(repository UserRepository, mailer mail.Mailer, guard LoginGuard) *Service {
+	return &Service{repository: repository, mailer: mailer, guard: guard}
 }
 
 func (s *Service) RegisterUser(payload RegisterUserPayload) error {
@@ -31,34 +61,106 @@ func (s *Service) RegisterUser(payload RegisterUserPayload) error {
 		Password: hashedPassword,
 	}
 
-	return s.repository.CreateUser(user)
+	if err := s.repository.CreateUser(user); err != nil {
+		return err
+	}
+
+	// The account is usable without the email, so a failed send is only logged; the user can ask for it again
+	created, err := s.repository.GetUserByEmail(payload.Email)
+	if err == nil {
+		err = s.sendEmailVerification(*created)
+	}
+	if err != nil {
+		log.Printf("can't send verification email to %s: %v", payload.Email, err)
+	}
+
+	return nil
 }
 
-func (s *Service) LogInUser(user LogInUserPayload) (string, string, error) {
+// LogInUser checks the password. Users with 2FA get a challenge token to redeem with LogInTwoFactor instead of the tokens.
+func (s *Service) LogInUser(user LogInUserPayload, address string) (*LogInResponse, error) {
+
+	if err := s.guard.Check(user.Email, address); err != nil {
+		return nil, err
+	}
 
 	u, err := s.repository.GetUserByEmail(user.Email)
 
 	if err != nil {
-		return "", "", errors.ErrInvalidCredentials
+		return nil, s.failLogIn(user.Email, address)
 	}
 
 	if !auth.ComparePasswords(u.Password, []byte(user.Password)) {
-		return "", "", errors.ErrInvalidCredentials
+		return nil, s.failLogIn(user.Email, address)
 	}
 
-	userJWT := createJWTPayload(*u)
+	if conf.AccountConfig.RequireVerifiedEmail && u.EmailVerifiedAt == nil {
+		return nil, errors.ErrEmailNotVerified
+	}
 
-	token, err := auth.CreateJWT(userJWT, false)
+	if u.TwoFactorEnabled {
+		token, err := s.createUserToken(u.UserId, TokenPurposeTwoFactorLogin, twoFactorLogInTTL)
+		if err != nil {
+			return nil, err
+		}
+
+		// The account's failures are only forgotten once the second step passes too
+		return &LogInResponse{TwoFactorRequired: true, TwoFactorToken: token}, nil
+	}
+
+	if err := s.guard.RegisterSuccess(u.Email); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(*u, false)
+}
+
+// LogInTwoFactor completes a login with a TOTP or recovery code
+func (s *Service) LogInTwoFactor(payload TwoFactorLogInPayload, address string) (*LogInResponse, error) {
+
+	tokenHash := auth.HashOpaqueToken(payload.TwoFactorToken)
+
+	userId, err := s.repository.CheckUserToken(tokenHash, TokenPurposeTwoFactorLogin)
 	if err != nil {
-		return "", "", errors.ErrJWTCreation
+		return nil, err
 	}
 
-	refreshToken, err := auth.CreateJWT(userJWT, true)
+	user, err := s.repository.GetUserById(userId)
 	if err != nil {
-		return "", "", errors.ErrJWTCreation
+		return nil, err
 	}
 
-	return token, refreshToken, nil
+	if err := s.guard.Check(user.Email, address); err != nil {
+		return nil, err
+	}
+
+	secret, err := s.repository.GetTOTP(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.verifyTwoFactorCode(*secret, payload.Code, true)
+	if err == errors.ErrInvalidTwoFactorCode {
+		if err := s.repository.FailUserTokenAttempt(tokenHash, twoFactorMaxAttempts); err != nil {
+			return nil, err
+		}
+		if err := s.guard.RegisterFailure(user.Email, address); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.ConsumeUserToken(tokenHash, TokenPurposeTwoFactorLogin); err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.RegisterSuccess(user.Email); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(*user, true)
 }
 
 func (s *Service) GetUserPublicByEmail(email string) (*UserPublicPayload, error) {
@@ -74,6 +176,8 @@ func (s *Service) GetUserPublicByEmail(email string) (*UserPublicPayload, error)
 		UserName: u.UserName,
 		LanguageCode: u.LanguageCode,
 		Photo: u.Photo,
+		EmailVerified: u.EmailVerifiedAt != nil,
+		TwoFactorEnabled: u.TwoFactorEnabled,
 	}, nil
 }
 
@@ -91,6 +195,8 @@ func (s *Service) GetUserPublicById(userId []uint8) (*UserPublicPayload, error)
 		UserName: u.UserName,
 		LanguageCode: u.LanguageCode,
 		Photo: u.Photo,
+		EmailVerified: u.EmailVerifiedAt != nil,
+		TwoFactorEnabled: u.TwoFactorEnabled,
 	}, nil
 }
 
@@ -105,23 +211,32 @@ func (s *Service) UserExist(userId []uint8) (bool, error) {
 
 }
 
-func (s *Service) RefreshToken(userId []uint8) (string, error) {
+// RefreshToken rotates the refresh token, returning a new access token and a new refresh token
+func (s *Service) RefreshToken(tokenId string) (string, string, error) {
 
-	user, err := s.repository.GetUserById(userId)
+	session, err := s.repository.RotateSession(tokenId, refreshTokenTTL())
+	if err != nil {
+		return "", "", err
+	}
 
+	user, err := s.repository.GetUserById(session.UserId)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	userJWT := createJWTPayload(*user)
+	return issueTokens(*user, *session)
+}
 
-	accessToken, err := auth.CreateJWT(userJWT, false)
+func (s *Service) Logout(userId []uint8, sessionId string) error {
+	return s.repository.RevokeSession(userId, sessionId)
+}
 
-	if err != nil {
-		return "", errors.ErrJWTCreation
-	}
+func (s *Service) LogoutAll(userId []uint8) error {
+	return s.repository.RevokeAllSessions(userId)
+}
 
-	return accessToken, nil
+func (s *Service) IsSessionActive(sessionId string) (bool, error) {
+	return s.repository.IsSessionActive(sessionId)
 }
 
 func (s *Service) UploadPhoto(payload UploadPhotoPayload, email string) error {
@@ -134,16 +249,345 @@ func (s *Service) UploadPhoto(payload UploadPhotoPayload, email string) error {
 	return s.repository.UploadPhoto(payload.Photo, email)
 }
 
+// GetUserLanguage returns the user's preferred language, failing if the user doesn't exist
+func (s *Service) GetUserLanguage(userId []uint8) (string, error) {
+
+	user, err := s.repository.GetUserById(userId)
+	if err != nil {
+		return "", err
+	}
+
+	return user.LanguageCode, nil
+}
+
+func (s *Service) UpdateLanguage(userId []uint8, payload UpdateLanguagePayload) error {
+
+	exists, err := s.repository.LanguageExists(payload.LanguageCode)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.ErrLanguageNotFound
+	}
+
+	return s.repository.UpdateLanguage(userId, payload.LanguageCode)
+}
+
+// RequestPasswordReset emails a reset link. It succeeds for unknown emails too, so the endpoint can't be used to find accounts
+func (s *Service) RequestPasswordReset(payload ForgotPasswordPayload) error {
+
+	user, err := s.repository.GetUserByEmail(payload.Email)
+	if err == errors.ErrUserNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Duration(conf.AccountConfig.PasswordResetTokenTTLInMinutes) * time.Minute
+	token, err := s.createUserToken(user.UserId, TokenPurposePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := buildLink("/reset-password", token)
+	body := fmt.Sprintf(i18n.T(user.LanguageCode, passwordResetBody), user.UserName, link, conf.AccountConfig.PasswordResetTokenTTLInMinutes)
+
+	return s.sendMail(user.Email, i18n.T(user.LanguageCode, passwordResetSubject), body)
+}
+
+func (s *Service) ResetPassword(payload ResetPasswordPayload) error {
+
+	hashedPassword, err := auth.HashPassword(payload.Password)
+	if err != nil {
+		return errors.ErrHashingPassword(err)
+	}
+
+	return s.repository.ResetPassword(auth.HashOpaqueToken(payload.Token), hashedPassword)
+}
+
+func (s *Service) RequestEmailVerification(userId []uint8) error {
+
+	user, err := s.repository.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return errors.ErrEmailAlreadyVerified
+	}
+
+	return s.sendEmailVerification(*user)
+}
+
+func (s *Service) VerifyEmail(payload VerifyEmailPayload) error {
+	return s.repository.VerifyEmail(auth.HashOpaqueToken(payload.Token))
+}
+
+// EnrollTwoFactor generates the secret for the user's authenticator app. 2FA is only enabled once a code is confirmed.
+func (s *Service) EnrollTwoFactor(userId []uint8) (*TwoFactorEnrollment, error) {
+
+	user, err := s.repository.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled {
+		return nil, errors.ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, errors.ErrCantUploadTwoFactor(err.Error())
+	}
+
+	if err := s.repository.SaveTOTPSecret(userId, secret); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables 2FA with a code from the app and returns the recovery codes, which are only shown this once
+func (s *Service) ConfirmTwoFactor(userId []uint8, payload TwoFactorCodePayload) (*RecoveryCodes, error) {
+
+	secret, err := s.repository.GetTOTP(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret.ConfirmedAt != nil {
+		return nil, errors.ErrTwoFactorAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(secret.Secret, payload.Code, time.Now(), totpSkew)
+	if !ok {
+		return nil, errors.ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.ConfirmTOTP(userId, step, hashes); err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code. It takes an app code, so a leaked recovery code can't renew itself.
+func (s *Service) RegenerateRecoveryCodes(userId []uint8, payload TwoFactorCodePayload) (*RecoveryCodes, error) {
+
+	secret, err := s.enabledTOTP(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyTwoFactorCode(*secret, payload.Code, false); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.ReplaceRecoveryCodes(userId, hashes); err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+func (s *Service) DisableTwoFactor(userId []uint8, payload TwoFactorCodePayload) error {
+
+	secret, err := s.enabledTOTP(userId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyTwoFactorCode(*secret, payload.Code, true); err != nil {
+		return err
+	}
+
+	return s.repository.DeleteTOTP(userId)
+}
+
 // Aux Functions
 
-func createJWTPayload(user User) auth.UserJWT {
+// failLogIn counts the failure and returns the error to answer with, which doesn't tell unknown emails apart
+func (s *Service) failLogIn(email string, address string) error {
+
+	if err := s.guard.RegisterFailure(email, address); err != nil {
+		return err
+	}
+
+	return errors.ErrInvalidCredentials
+}
+
+func (s *Service) startSession(user User, twoFactor bool) (*LogInResponse, error) {
+
+	session, err := s.repository.CreateSession(user.UserId, refreshTokenTTL(), twoFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, refreshToken, err := issueTokens(user, *session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LogInResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+func (s *Service) enabledTOTP(userId []uint8) (*TOTP, error) {
+
+	secret, err := s.repository.GetTOTP(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret.ConfirmedAt == nil {
+		return nil, errors.ErrTwoFactorNotEnabled
+	}
+
+	return secret, nil
+}
+
+// verifyTwoFactorCode accepts a code from the app, each at most once, or an unused recovery code if allowRecovery
+func (s *Service) verifyTwoFactorCode(secret TOTP, code string, allowRecovery bool) error {
+
+	if step, ok := totp.Validate(secret.Secret, code, time.Now(), totpSkew); ok {
+		used, err := s.repository.UseTOTPStep(secret.UserId, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errors.ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	if !allowRecovery {
+		return errors.ErrInvalidTwoFactorCode
+	}
+
+	used, err := s.repository.UseRecoveryCode(secret.UserId, auth.HashOpaqueToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errors.ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// generateRecoveryCodes returns the codes to show the user, formatted as xxxxx-xxxxx, and the hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+
+	for i := range codes {
+		buffer := make([]byte, 10)
+		if _, err := rand.Read(buffer); err != nil {
+			return nil, nil, errors.ErrCantUploadTwoFactor(err.Error())
+		}
+
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(buffer))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = auth.HashOpaqueToken(raw)
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func (s *Service) sendEmailVerification(user User) error {
+
+	ttl := time.Duration(conf.AccountConfig.EmailVerificationTokenTTLInHours) * time.Hour
+	token, err := s.createUserToken(user.UserId, TokenPurposeEmailVerification, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := buildLink("/verify-email", token)
+	body := fmt.Sprintf(i18n.T(user.LanguageCode, emailVerificationBody), user.UserName, link, conf.AccountConfig.EmailVerificationTokenTTLInHours)
+
+	return s.sendMail(user.Email, i18n.T(user.LanguageCode, emailVerificationSubject), body)
+}
+
+// createUserToken stores the hash of a new token and returns the token itself, which is only ever sent by email
+func (s *Service) createUserToken(userId []uint8, purpose string, ttl time.Duration) (string, error) {
+
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", errors.ErrCantUploadUserToken(err.Error())
+	}
+
+	if err := s.repository.CreateUserToken(userId, tokenHash, purpose, ttl); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *Service) sendMail(to string, subject string, body string) error {
+
+	err := s.mailer.Send(mail.Message{To: to, Subject: subject, Body: body})
+	if err != nil {
+		return errors.ErrCantSendMail(err.Error())
+	}
+
+	return nil
+}
+
+// buildLink points to a frontend page that receives the token as a query parameter
+func buildLink(path string, token string) string {
+	return conf.AccountConfig.AppBaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func createJWTPayload(user User, session Session) auth.UserJWT {
 
 	var userJWT auth.UserJWT
 
 	userJWT.UserId = string(user.UserId)
 	userJWT.Email = user.Email
 	userJWT.UserName = user.UserName
+	userJWT.SessionId = session.SessionId
+	userJWT.TokenId = session.TokenId
+	userJWT.TwoFactor = session.TwoFactor
 
 	return userJWT
 
 }
+
+func issueTokens(user User, session Session) (string, string, error) {
+
+	userJWT := createJWTPayload(user, session)
+
+	token, err := auth.CreateJWT(userJWT, false)
+	if err != nil {
+		return "", "", errors.ErrJWTCreation
+	}
+
+	refreshToken, err := auth.CreateJWT(userJWT, true)
+	if err != nil {
+		return "", "", errors.ErrJWTCreation
+	}
+
+	return token, refreshToken, nil
+}
+
+func refreshTokenTTL() time.Duration {
+	return time.Duration(conf.ServerConfig.RefreshTokenExpirationInHours) * time.Hour
+}
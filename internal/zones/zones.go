@@ -0,0 +1,51 @@
+package zones
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Zone is a district work is split by. Role assignments limited to zones only apply inside them.
+type Zone struct {
+	ZoneId      []uint8         `json:"zoneId"`
+	ZoneName    string          `json:"zoneName"`
+	Description string          `json:"description"`
+	Area        json.RawMessage `json:"area"` // GeoJSON MultiPolygon
+	CreatedBy   []uint8         `json:"createdBy"`
+	UpdatedBy   []uint8         `json:"updatedBy"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+type ZoneRepository interface {
+	CreateZone(zone Zone) error
+	GetZones() ([]Zone, error)
+	GetZoneById(zoneId []uint8) (*Zone, error)
+	GetZoneByName(zoneName string) (*Zone, error)
+	UpdateZone(zone Zone) error
+	DeleteZone(zoneId []uint8) error
+	IsZoneInUse(zoneId []uint8) (bool, error)
+	IsValidArea(area string) (bool, error)
+	ZonesContain(zoneIds []string, latitude float64, longitude float64) (bool, error)
+}
+
+type ZoneService interface {
+	CreateZone(payload createZonePayload, userId []uint8, zones []string) error
+	GetZones() ([]Zone, error)
+	GetZone(zoneId []uint8) (*Zone, error)
+	UpdateZone(zoneId []uint8, payload updateZonePayload, userId []uint8, zones []string) error
+	DeleteZone(zoneId []uint8, zones []string) error
+	ZonesContain(zoneIds []string, latitude float64, longitude float64) (bool, error)
+}
+
+type createZonePayload struct {
+	ZoneName    string          `json:"zoneName" validate:"required,max=100"`
+	Description string          `json:"description"`
+	Area        json.RawMessage `json:"area" validate:"required"` // GeoJSON Polygon or MultiPolygon
+}
+
+type updateZonePayload struct {
+	ZoneName    *string          `json:"zoneName" validate:"omitempty,min=1,max=100"`
+	Description *string          `json:"description"`
+	Area        *json.RawMessage `json:"area"` // replaces the whole boundary when present
+}
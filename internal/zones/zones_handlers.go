@@ -0,0 +1,137 @@
+package zones
+
+import (
+	"net/http"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/middlewares"
+	"github.com/PabloPei/TreeSense-Backend/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	service ZoneService
+}
+
+func NewHandler(service ZoneService) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middlewares.Middleware) {
+
+	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"READ"}, false)(h.handleGetZones)).Methods("GET")
+	router.HandleFunc("", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleCreateZone)).Methods("POST")
+	router.HandleFunc("/{zoneId}", middleware.RequireAuthAndPermission([]string{"READ"}, false)(h.handleGetZone)).Methods("GET")
+	router.HandleFunc("/{zoneId}", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleUpdateZone)).Methods("PUT", "PATCH")
+	router.HandleFunc("/{zoneId}", middleware.RequireAuthAndPermission([]string{"CONFIG"}, false)(h.handleDeleteZone)).Methods("DELETE")
+}
+
+func (h *Handler) handleGetZones(w http.ResponseWriter, r *http.Request) {
+
+	zones, err := h.service.GetZones()
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, zones)
+}
+
+func (h *Handler) handleGetZone(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	zoneId, ok := vars["zoneId"]
+	if !ok || !utils.IsUUID(zoneId) {
+		utils.WriteError(w, errors.ErrZoneNotFound)
+		return
+	}
+
+	zone, err := h.service.GetZone([]uint8(zoneId))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, zone)
+}
+
+func (h *Handler) handleCreateZone(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	var zone createZonePayload
+	if err := utils.ParseJSON(r, &zone); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(zone); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err = h.service.CreateZone(zone, userId, middlewares.GetZonesFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusCreated, "Zone created successfully")
+}
+
+func (h *Handler) handleUpdateZone(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := middlewares.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.WriteError(w, errors.ErrJWTInvalidToken)
+		return
+	}
+
+	vars := mux.Vars(r)
+	zoneId, ok := vars["zoneId"]
+	if !ok || !utils.IsUUID(zoneId) {
+		utils.WriteError(w, errors.ErrZoneNotFound)
+		return
+	}
+
+	var zone updateZonePayload
+	if err := utils.ParseJSON(r, &zone); err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(zone); err != nil {
+		utils.WriteError(w, utils.ValidationError(err))
+		return
+	}
+
+	err = h.service.UpdateZone([]uint8(zoneId), zone, userId, middlewares.GetZonesFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Zone updated successfully")
+}
+
+func (h *Handler) handleDeleteZone(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	zoneId, ok := vars["zoneId"]
+	if !ok || !utils.IsUUID(zoneId) {
+		utils.WriteError(w, errors.ErrZoneNotFound)
+		return
+	}
+
+	err := h.service.DeleteZone([]uint8(zoneId), middlewares.GetZonesFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteMessage(w, http.StatusOK, "Zone deleted successfully")
+}
@@ -0,0 +1,169 @@
+package zones
+
+import (
+	"database/sql"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/lib/pq"
+)
+
+// Postgres SQL Repository
+type SQLRepository struct {
+	db *sql.DB
+}
+
+func NewSQLRepository(db *sql.DB) *SQLRepository {
+	return &SQLRepository{db: db}
+}
+
+type scannable interface {
+	Scan(dest ...interface{}) error
+}
+
+// Zone columns in scanRowIntoZone order, with the area encoded as GeoJSON
+const zoneColumns = "zone_id, zone_name, COALESCE(description, ''), ST_AsGeoJSON(area), created_by, updated_by, created_at, updated_at"
+
+// Polygons are stored as MultiPolygon so both GeoJSON types fit the column
+func (s *SQLRepository) CreateZone(zone Zone) error {
+
+	_, err := s.db.Exec(
+		`INSERT INTO treesense."zone" (zone_name, description, area, created_by, updated_by)
+		VALUES ($1, NULLIF($2, ''), ST_Multi(ST_SetSRID(ST_GeomFromGeoJSON($3), 4326)), $4, $4)`,
+		zone.ZoneName, zone.Description, string(zone.Area), zone.CreatedBy,
+	)
+	if err != nil {
+		return errors.ErrCantUploadZone(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) GetZones() ([]Zone, error) {
+
+	rows, err := s.db.Query("SELECT " + zoneColumns + " FROM treesense.\"zone\" ORDER BY zone_name")
+	if err != nil {
+		return nil, errors.ErrReadingZone(err.Error())
+	}
+	defer rows.Close()
+
+	zones := []Zone{}
+	for rows.Next() {
+		zone, err := scanRowIntoZone(rows)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, *zone)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.ErrReadingZone(err.Error())
+	}
+
+	return zones, nil
+}
+
+func (s *SQLRepository) GetZoneById(zoneId []uint8) (*Zone, error) {
+	row := s.db.QueryRow("SELECT "+zoneColumns+" FROM treesense.\"zone\" WHERE zone_id = $1", zoneId)
+	return scanRowIntoZone(row)
+}
+
+func (s *SQLRepository) GetZoneByName(zoneName string) (*Zone, error) {
+	row := s.db.QueryRow("SELECT "+zoneColumns+" FROM treesense.\"zone\" WHERE zone_name = $1", zoneName)
+	return scanRowIntoZone(row)
+}
+
+func (s *SQLRepository) UpdateZone(zone Zone) error {
+
+	_, err := s.db.Exec(
+		`UPDATE treesense."zone" SET zone_name = $1, description = NULLIF($2, ''), area = ST_Multi(ST_SetSRID(ST_GeomFromGeoJSON($3), 4326)),
+		updated_by = $4, updated_at = CURRENT_TIMESTAMP WHERE zone_id = $5`,
+		zone.ZoneName, zone.Description, string(zone.Area), zone.UpdatedBy, zone.ZoneId,
+	)
+	if err != nil {
+		return errors.ErrCantUploadZone(err.Error())
+	}
+
+	return nil
+}
+
+func (s *SQLRepository) DeleteZone(zoneId []uint8) error {
+
+	_, err := s.db.Exec("DELETE FROM treesense.\"zone\" WHERE zone_id = $1", zoneId)
+	if err != nil {
+		return errors.ErrCantUploadZone(err.Error())
+	}
+
+	return nil
+}
+
+// IsZoneInUse tells whether any role assignment, active or lapsed, is limited to the zone
+func (s *SQLRepository) IsZoneInUse(zoneId []uint8) (bool, error) {
+
+	var inUse bool
+	err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM auth.user_role_zone WHERE zone_id = $1)", zoneId).Scan(&inUse)
+	if err != nil {
+		return false, errors.ErrReadingZone(err.Error())
+	}
+
+	return inUse, nil
+}
+
+// IsValidArea checks the GeoJSON geometry is well formed and its polygons don't self-intersect
+func (s *SQLRepository) IsValidArea(area string) (bool, error) {
+
+	var valid bool
+	err := s.db.QueryRow("SELECT ST_IsValid(ST_GeomFromGeoJSON($1))", area).Scan(&valid)
+	if err != nil {
+		// PostGIS rejects malformed GeoJSON with an error rather than false
+		return false, errors.ErrInvalidZoneArea(err.Error())
+	}
+
+	return valid, nil
+}
+
+// ZonesContain tells whether the point lies inside, or on the border of, any of the zones
+func (s *SQLRepository) ZonesContain(zoneIds []string, latitude float64, longitude float64) (bool, error) {
+
+	var inside bool
+	err := s.db.QueryRow(
+		`SELECT EXISTS (
+			SELECT 1 FROM treesense."zone"
+			WHERE zone_id = ANY($1::uuid[]) AND ST_Covers(area, ST_SetSRID(ST_MakePoint($2, $3), 4326))
+		)`,
+		pq.Array(zoneIds), longitude, latitude,
+	).Scan(&inside)
+	if err != nil {
+		return false, errors.ErrReadingZone(err.Error())
+	}
+
+	return inside, nil
+}
+
+// Aux Functions
+
+func scanRowIntoZone(row scannable) (*Zone, error) {
+
+	zone := new(Zone)
+	var area string
+	err := row.Scan(
+		&zone.ZoneId,
+		&zone.ZoneName,
+		&zone.Description,
+		&area,
+		&zone.CreatedBy,
+		&zone.UpdatedBy,
+		&zone.CreatedAt,
+		&zone.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrZoneNotFound
+		}
+		return nil, errors.ErrReadingZone(err.Error())
+	}
+
+	zone.Area = []byte(area)
+
+	return zone, nil
+}
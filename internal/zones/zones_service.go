@@ -0,0 +1,166 @@
+package zones
+
+import (
+	"encoding/json"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/pkg/geo"
+	"github.com/PabloPei/TreeSense-Backend/utils"
+)
+
+type Service struct {
+	repository ZoneRepository
+}
+
+func NewService(repository ZoneRepository) *Service {
+	return &Service{repository: repository}
+}
+
+func (s *Service) CreateZone(payload createZonePayload, userId []uint8, zones []string) error {
+
+	if err := checkUnscoped(zones); err != nil {
+		return err
+	}
+
+	if _, err := s.repository.GetZoneByName(payload.ZoneName); err == nil {
+		return errors.ErrZoneAlreadyExist(payload.ZoneName)
+	}
+
+	if err := s.validateArea(payload.Area); err != nil {
+		return err
+	}
+
+	return s.repository.CreateZone(Zone{
+		ZoneName:    payload.ZoneName,
+		Description: payload.Description,
+		Area:        payload.Area,
+		CreatedBy:   userId,
+	})
+}
+
+func (s *Service) GetZones() ([]Zone, error) {
+
+	zones, err := s.repository.GetZones()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range zones {
+		zones[i].CreatedAt = utils.ConvertUTCToArgentina(zones[i].CreatedAt)
+		zones[i].UpdatedAt = utils.ConvertUTCToArgentina(zones[i].UpdatedAt)
+	}
+
+	return zones, nil
+}
+
+func (s *Service) GetZone(zoneId []uint8) (*Zone, error) {
+
+	zone, err := s.repository.GetZoneById(zoneId)
+	if err != nil {
+		return nil, err
+	}
+
+	zone.CreatedAt = utils.ConvertUTCToArgentina(zone.CreatedAt)
+	zone.UpdatedAt = utils.ConvertUTCToArgentina(zone.UpdatedAt)
+
+	return zone, nil
+}
+
+func (s *Service) UpdateZone(zoneId []uint8, payload updateZonePayload, userId []uint8, zones []string) error {
+
+	if err := checkUnscoped(zones); err != nil {
+		return err
+	}
+
+	zone, err := s.repository.GetZoneById(zoneId)
+	if err != nil {
+		return err
+	}
+
+	if payload.ZoneName != nil && *payload.ZoneName != zone.ZoneName {
+		if _, err := s.repository.GetZoneByName(*payload.ZoneName); err == nil {
+			return errors.ErrZoneAlreadyExist(*payload.ZoneName)
+		}
+		zone.ZoneName = *payload.ZoneName
+	}
+
+	if payload.Description != nil {
+		zone.Description = *payload.Description
+	}
+
+	if payload.Area != nil {
+		if err := s.validateArea(*payload.Area); err != nil {
+			return err
+		}
+		zone.Area = *payload.Area
+	}
+
+	zone.UpdatedBy = userId
+
+	return s.repository.UpdateZone(*zone)
+}
+
+// DeleteZone refuses zones role assignments are limited to, since dropping the limit would widen those assignments
+func (s *Service) DeleteZone(zoneId []uint8, zones []string) error {
+
+	if err := checkUnscoped(zones); err != nil {
+		return err
+	}
+
+	if _, err := s.repository.GetZoneById(zoneId); err != nil {
+		return err
+	}
+
+	inUse, err := s.repository.IsZoneInUse(zoneId)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return errors.ErrZoneInUse
+	}
+
+	return s.repository.DeleteZone(zoneId)
+}
+
+func (s *Service) ZonesContain(zoneIds []string, latitude float64, longitude float64) (bool, error) {
+
+	if len(zoneIds) == 0 {
+		return false, nil
+	}
+
+	return s.repository.ZonesContain(zoneIds, latitude, longitude)
+}
+
+// Aux Functions
+
+// checkUnscoped keeps zone-limited CONFIG holders from moving or dropping the zones that limit them
+func checkUnscoped(zones []string) error {
+
+	if zones != nil {
+		return errors.ErrZoneScopedConfig
+	}
+
+	return nil
+}
+
+func (s *Service) validateArea(area json.RawMessage) error {
+
+	var geometry geo.Geometry
+	if err := json.Unmarshal(area, &geometry); err != nil {
+		return errors.ErrInvalidZoneArea("area must be a GeoJSON Polygon or MultiPolygon")
+	}
+
+	if geometry.Type != "Polygon" && geometry.Type != "MultiPolygon" {
+		return errors.ErrInvalidZoneArea("area must be a GeoJSON Polygon or MultiPolygon")
+	}
+
+	valid, err := s.repository.IsValidArea(string(area))
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.ErrInvalidZoneArea("area polygons must be closed and must not self-intersect")
+	}
+
+	return nil
+}
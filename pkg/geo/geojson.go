@@ -0,0 +1,51 @@
+package geo
+
+// GeoJSON objects as defined by RFC 7946. Coordinates are always [longitude, latitude] in WGS 84.
+
+const GeoJSONContentType = "application/geo+json"
+
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string                 `json:"type"`
+	Id         string                 `json:"id,omitempty"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+func NewFeatureCollection(features []Feature) FeatureCollection {
+
+	if features == nil {
+		features = []Feature{}
+	}
+
+	return FeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	}
+}
+
+func NewPointFeature(id string, longitude float64, latitude float64, properties map[string]interface{}) Feature {
+
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+
+	return Feature{
+		Type: "Feature",
+		Id:   id,
+		Geometry: Geometry{
+			Type:        "Point",
+			Coordinates: []float64{longitude, latitude},
+		},
+		Properties: properties,
+	}
+}
@@ -0,0 +1,91 @@
+package geo
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// GPX 1.1 documents (https://www.topografix.com/GPX/1/1/). Only the elements TreeSense reads or writes are mapped.
+
+const (
+	GPXContentType = "application/gpx+xml"
+	gpxNamespace   = "http://www.topografix.com/GPX/1/1"
+)
+
+type GPX struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Xmlns     string        `xml:"xmlns,attr,omitempty"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Waypoints []GPXWaypoint `xml:"wpt"`
+	Routes    []GPXRoute    `xml:"rte"`
+	Tracks    []GPXTrack    `xml:"trk"`
+}
+
+// GPXWaypoint is shared by wpt, rtept and trkpt elements
+type GPXWaypoint struct {
+	Latitude    float64    `xml:"lat,attr"`
+	Longitude   float64    `xml:"lon,attr"`
+	Elevation   *float64   `xml:"ele,omitempty"`
+	Time        *time.Time `xml:"time,omitempty"`
+	Name        string     `xml:"name,omitempty"`
+	Description string     `xml:"desc,omitempty"`
+}
+
+type GPXRoute struct {
+	Name   string        `xml:"name,omitempty"`
+	Points []GPXWaypoint `xml:"rtept"`
+}
+
+type GPXTrack struct {
+	Name     string            `xml:"name,omitempty"`
+	Segments []GPXTrackSegment `xml:"trkseg"`
+}
+
+type GPXTrackSegment struct {
+	Points []GPXWaypoint `xml:"trkpt"`
+}
+
+func NewGPX(creator string) *GPX {
+	return &GPX{
+		Xmlns:   gpxNamespace,
+		Version: "1.1",
+		Creator: creator,
+	}
+}
+
+// TrackPoints flattens every segment of every track in document order
+func (g *GPX) TrackPoints() []GPXWaypoint {
+
+	var points []GPXWaypoint
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			points = append(points, segment.Points...)
+		}
+	}
+
+	return points
+}
+
+func DecodeGPX(r io.Reader) (*GPX, error) {
+
+	gpx := new(GPX)
+	if err := xml.NewDecoder(r).Decode(gpx); err != nil {
+		return nil, err
+	}
+
+	return gpx, nil
+}
+
+func EncodeGPX(w io.Writer, gpx *GPX) error {
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	return encoder.Encode(gpx)
+}
@@ -0,0 +1,97 @@
+package geo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// KML 2.2 documents (OGC 07-147r2) as read by Google Earth. Only placemarks with points and line strings are mapped.
+
+const (
+	KMLContentType = "application/vnd.google-earth.kml+xml"
+	kmlNamespace   = "http://www.opengis.net/kml/2.2"
+)
+
+type KML struct {
+	XMLName  xml.Name    `xml:"kml"`
+	Xmlns    string      `xml:"xmlns,attr,omitempty"`
+	Document KMLDocument `xml:"Document"`
+}
+
+type KMLDocument struct {
+	Name        string         `xml:"name,omitempty"`
+	Description string         `xml:"description,omitempty"`
+	Placemarks  []KMLPlacemark `xml:"Placemark"`
+}
+
+type KMLPlacemark struct {
+	Name        string         `xml:"name,omitempty"`
+	Description string         `xml:"description,omitempty"`
+	TimeStamp   *KMLTimeStamp  `xml:"TimeStamp,omitempty"`
+	Point       *KMLPoint      `xml:"Point,omitempty"`
+	LineString  *KMLLineString `xml:"LineString,omitempty"`
+}
+
+type KMLTimeStamp struct {
+	When time.Time `xml:"when"`
+}
+
+type KMLPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type KMLLineString struct {
+	Tessellate  int    `xml:"tessellate,omitempty"`
+	Coordinates string `xml:"coordinates"`
+}
+
+func NewKML(name string) *KML {
+	return &KML{
+		Xmlns:    kmlNamespace,
+		Document: KMLDocument{Name: name},
+	}
+}
+
+func NewKMLPointPlacemark(name string, description string, longitude float64, latitude float64) KMLPlacemark {
+	return KMLPlacemark{
+		Name:        name,
+		Description: description,
+		Point:       &KMLPoint{Coordinates: kmlCoordinate(longitude, latitude)},
+	}
+}
+
+// coordinates are [longitude, latitude] pairs, like GeoJSON
+func NewKMLLineStringPlacemark(name string, coordinates [][2]float64) KMLPlacemark {
+
+	tuples := make([]string, 0, len(coordinates))
+	for _, coordinate := range coordinates {
+		tuples = append(tuples, kmlCoordinate(coordinate[0], coordinate[1]))
+	}
+
+	return KMLPlacemark{
+		Name: name,
+		LineString: &KMLLineString{
+			Tessellate:  1,
+			Coordinates: strings.Join(tuples, " "),
+		},
+	}
+}
+
+func EncodeKML(w io.Writer, kml *KML) error {
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	return encoder.Encode(kml)
+}
+
+func kmlCoordinate(longitude float64, latitude float64) string {
+	return fmt.Sprintf("%g,%g", longitude, latitude)
+}
@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes every message as an .eml file instead of sending it, for local development
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir string, from string) (*FileMailer, error) {
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("can't create mail directory: %w", err)
+	}
+
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(message Message) error {
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), filepath.Base(message.To))
+	target := filepath.Join(m.dir, name)
+
+	if err := os.WriteFile(target, encode(m.from, message), 0o640); err != nil {
+		return fmt.Errorf("can't write email to %s: %w", target, err)
+	}
+
+	log.Printf("Email %q to %s written to %s", message.Subject, message.To, target)
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages, e.g. through an SMTP relay or to local files during development
+type Mailer interface {
+	Send(message Message) error
+}
+
+// encode renders message as an RFC 5322 email with a UTF-8 plain text body
+func encode(from string, message Message) []byte {
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "From: %s\r\n", from)
+	fmt.Fprintf(&buffer, "To: %s\r\n", message.To)
+	fmt.Fprintf(&buffer, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buffer, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	buffer.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buffer.WriteString("\r\n")
+	buffer.WriteString(message.Body)
+
+	return buffer.Bytes()
+}
@@ -0,0 +1,33 @@
+package mail
+
+import (
+	"fmt"
+	"net/smtp"
+)
+
+// SMTPMailer sends messages through an SMTP server. Authentication is skipped when username is empty.
+type SMTPMailer struct {
+	address  string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(host string, port string, username string, password string, from string) *SMTPMailer {
+	return &SMTPMailer{address: host + ":" + port, host: host, username: username, password: password, from: from}
+}
+
+func (m *SMTPMailer) Send(message Message) error {
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	if err := smtp.SendMail(m.address, auth, m.from, []string{message.To}, encode(m.from, message)); err != nil {
+		return fmt.Errorf("can't send email to %s: %w", message.To, err)
+	}
+
+	return nil
+}
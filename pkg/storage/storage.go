@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps opaque binary objects under slash separated keys (e.g. trees/<treeId>/<name>.jpg)
+type BlobStore interface {
+	Put(key string, data io.Reader, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
@@ -3,30 +3,89 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 
-	"github.com/go-playground/validator/v10"
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/PabloPei/TreeSense-Backend/internal/i18n"
 )
 
-var Validate = validator.New()
-
 func WriteJSON(w http.ResponseWriter, status int, v any) error {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return WriteJSONAs(w, "application/json; charset=utf-8", status, v)
+}
+
+// WriteJSONAs encodes v as JSON under a JSON-based media type (e.g. application/geo+json)
+func WriteJSONAs(w http.ResponseWriter, contentType string, status int, v any) error {
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
 	return json.NewEncoder(w).Encode(v)
 }
 
-func WriteError(w http.ResponseWriter, status int, err error) {
-	WriteJSON(w, status, map[string]string{"error": err.Error()})
+// WriteExportHeaders starts a file download; the caller writes the file as the body
+func WriteExportHeaders(w http.ResponseWriter, contentType string, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+}
+
+type errorResponse struct {
+	Error   string      `json:"error"`
+	Code    string      `json:"code"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// WriteError writes err with the status and code it carries, translated to the language set on w by the language middleware
+func WriteError(w http.ResponseWriter, err error) {
+	e := errors.AsError(err)
+	language := i18n.LanguageFromWriter(w)
+
+	details := e.Details
+	if fields, ok := details.([]errors.FieldError); ok {
+		details = i18n.TranslateFieldErrors(language, fields)
+	}
+
+	WriteJSON(w, e.Status, errorResponse{
+		Error:   i18n.TranslateError(language, e),
+		Code:    e.Code,
+		Details: details,
+	})
+}
+
+// WriteMessage writes a translated {"message": ...} body
+func WriteMessage(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, map[string]string{"message": i18n.T(i18n.LanguageFromWriter(w), message)})
 }
 
 func ParseJSON(r *http.Request, v any) error {
 	if r.Body == nil {
-		return fmt.Errorf("missing request body")
+		return errors.ErrInvalidaPayload("missing request body")
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return errors.ErrInvalidaPayload(err.Error())
+	}
+
+	return nil
+}
+
+// GetClientIP returns the address of the client. X-Forwarded-For is only honored when trustProxy is set, since
+// clients can send it themselves.
+func GetClientIP(r *http.Request, trustProxy bool) string {
+
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			client, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(client)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
 
-	return json.NewDecoder(r.Body).Decode(v)
+	return host
 }
 
 func GetTokenFromRequest(r *http.Request) string {
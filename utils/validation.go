@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PabloPei/TreeSense-Backend/internal/errors"
+	"github.com/go-playground/validator/v10"
+)
+
+// Plausible bounds for tree measurements: height in meters, trunk diameter in centimeters
+const (
+	MaxTreeHeight   = 130.0
+	MaxTreeDiameter = 1200.0
+)
+
+var Validate = newValidator()
+
+// Message for each validation rule, %s is the rule parameter. They are translated through the i18n catalog.
+var fieldMessages = map[string]string{
+	"required":         "is required",
+	"required_with":    "is required when %s is present",
+	"required_without": "is required when %s is missing",
+	"min":              "must be at least %s",
+	"max":              "must be at most %s",
+	"gt":               "must be greater than %s",
+	"gte":              "must be at least %s",
+	"lt":               "must be less than %s",
+	"lte":              "must be at most %s",
+	"len":              "must have length %s",
+	"gtfield":          "must be greater than %s",
+	"oneof":            "must be one of: %s",
+	"email":            "must be a valid email",
+	"uuid":             "must be a valid UUID",
+	"uri":              "must be a valid URI",
+	"base64":           "must be valid base64",
+	"ip":               "must be a valid IP address",
+	"unique":           "must not contain duplicates",
+	"hexcolor":         "must be a hex color like #2E7D32",
+	"lat":              "must be a latitude between -90 and 90",
+	"lon":              "must be a longitude between -180 and 180",
+	"tree_height":      "must be a tree height greater than 0 and up to 130 meters",
+	"tree_diameter":    "must be a trunk diameter greater than 0 and up to 1200 centimeters",
+}
+
+// IsUUID tells whether value is a UUID, so malformed path ids can be reported as not found before reaching the database
+func IsUUID(value string) bool {
+	return Validate.Var(value, "uuid") == nil
+}
+
+func newValidator() *validator.Validate {
+
+	validate := validator.New()
+	validate.RegisterTagNameFunc(jsonFieldName)
+
+	validate.RegisterValidation("lat", floatInRange(-90, 90, true))
+	validate.RegisterValidation("lon", floatInRange(-180, 180, true))
+	validate.RegisterValidation("tree_height", floatInRange(0, MaxTreeHeight, false))
+	validate.RegisterValidation("tree_diameter", floatInRange(0, MaxTreeDiameter, false))
+
+	return validate
+}
+
+// ValidationError turns the error of Validate.Struct into an error listing every invalid field
+func ValidationError(err error) error {
+
+	fields := FieldErrors(err)
+	if fields == nil {
+		return errors.ErrInvalidaPayload(err.Error())
+	}
+
+	return errors.ErrInvalidFields(fields)
+}
+
+// FieldErrors describes each failed rule of a Validate.Struct error, or returns nil for any other error
+func FieldErrors(err error) []errors.FieldError {
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return nil
+	}
+
+	fields := make([]errors.FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		template, ok := fieldMessages[fieldError.Tag()]
+		if !ok {
+			template = "is not valid"
+		}
+
+		param := fieldError.Param()
+		if fieldError.Tag() == "gtfield" || fieldError.Tag() == "required_with" || fieldError.Tag() == "required_without" {
+			param = lowerFirst(param)
+		}
+
+		// The namespace starts with the struct name, e.g. createTreePayload.latitude
+		_, field, _ := strings.Cut(fieldError.Namespace(), ".")
+
+		fields = append(fields, errors.NewFieldError(field, fieldError.Tag(), param, template))
+	}
+
+	return fields
+}
+
+// Aux Functions
+
+// floatInRange accepts numbers between min and max. The lower bound is excluded unless includeMin is set.
+func floatInRange(min float64, max float64, includeMin bool) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+
+		var value float64
+		field := fl.Field()
+		switch field.Kind() {
+		case reflect.Float32, reflect.Float64:
+			value = field.Float()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			value = float64(field.Int())
+		default:
+			return false
+		}
+
+		if value < min || (value == min && !includeMin) {
+			return false
+		}
+		return value <= max
+	}
+}
+
+// jsonFieldName names fields as clients send them. Query structs without json tags use the lower camel case field name.
+func jsonFieldName(field reflect.StructField) string {
+
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return lowerFirst(field.Name)
+	}
+
+	return name
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}